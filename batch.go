@@ -5,6 +5,7 @@ import (
 	"bitcask-go/storage"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type WriteBatch struct {
@@ -15,10 +16,15 @@ type WriteBatch struct {
 }
 
 func (db *DB) NewWriteBatch(config WriteBatchConfig) *WriteBatch {
-	if !db.isOpen {
+	if !db.isOpen.Load() {
 		panic(ErrDBClosed)
 	}
 
+	// sequence number file is loaded in background for lazy loading
+	if db.config.IndexerType == index.BPlusTreeIndexType {
+		_ = db.waitIndexReady()
+	}
+
 	if db.config.IndexerType == index.BPlusTreeIndexType && !db.sequenceNumberFileExist && !db.isInitial {
 		panic("failed to open write batch: sequence number file not exist")
 	}
@@ -71,6 +77,10 @@ func (batch *WriteBatch) Delete(key []byte) error {
 // Commit transaction, write data from cache to file, the key for transaction log record should be different,
 // in which includes original key and global incremented transaction key, after succeed we update index in memory
 func (batch *WriteBatch) Commit() error {
+	if batch.db.metrics != nil {
+		defer batch.db.metrics.observe(metricsCommit, time.Now())
+	}
+
 	batch.mu.Lock()
 	defer batch.mu.Unlock()
 
@@ -82,9 +92,57 @@ func (batch *WriteBatch) Commit() error {
 		return ErrExceedMaxBatchSize
 	}
 
-	// Flush cache to file in serialization
-	batch.db.mu.Lock()
-	defer batch.db.mu.Unlock()
+	if batch.db.config.ReadOnly {
+		return ErrReadOnlyDatabase
+	}
+
+	// sequence number is only known after all the data files are loaded
+	if err := batch.db.waitIndexReady(); err != nil {
+		return err
+	}
+
+	// lock the keys of batch, so index is updated in the same order as log records of the keys
+	keys := make([][]byte, 0, len(batch.pendingWrites))
+	for _, logRecord := range batch.pendingWrites {
+		keys = append(keys, logRecord.Key)
+	}
+	unlock := batch.db.keyLocks.lockKeys(keys)
+	defer unlock()
+
+	positionMap, err := batch.appendPendingWrites()
+	if err != nil {
+		return err
+	}
+
+	// update index for log record
+	for key, logRecord := range batch.pendingWrites {
+		pos := positionMap[key]
+		var oldPos *storage.LogRecordPos
+		if logRecord.Type == storage.LogRecordNormal {
+			oldPos, err = index.CheckedPut(batch.db.index, logRecord.Key, pos)
+		} else if logRecord.Type == storage.LogRecordDeleted {
+			oldPos, _, err = index.CheckedDelete(batch.db.index, logRecord.Key)
+			batch.db.reclaim(pos)
+		}
+		if err != nil {
+			return err
+		}
+		if oldPos != nil {
+			batch.db.reclaim(oldPos)
+		}
+	}
+	batch.db.stats.batchCommits.Add(1)
+
+	// clean up cache
+	batch.pendingWrites = make(map[string]*storage.LogRecord)
+
+	return nil
+}
+
+// appendPendingWrites flush cache to file in serialization, return the position of each key
+func (batch *WriteBatch) appendPendingWrites() (map[string]*storage.LogRecordPos, error) {
+	batch.db.appendMu.Lock()
+	defer batch.db.appendMu.Unlock()
 
 	// Generate global transaction sequence number
 	sequenceNumber := batch.generateGlobalIncrementSequenceNumber()
@@ -101,43 +159,31 @@ func (batch *WriteBatch) Commit() error {
 		})
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		positionMap[string(logRecord.Key)] = pos
 	}
 
-	// append finish key
-	_, err := batch.db.appendLogRecord(&storage.LogRecord{
+	// append finish key, it's not needed once the transaction is loaded
+	finishPos, err := batch.db.appendLogRecord(&storage.LogRecord{
 		Key:            transactionFinishKey,
 		Type:           storage.LogRecordTransactionFinished,
 		SequenceNumber: sequenceNumber,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	batch.db.reclaim(finishPos)
 
 	// sync data
-	if batch.config.SyncWrites && batch.db.activeFile != nil {
-		if err = batch.db.activeFile.Sync(); err != nil {
-			return err
+	if batch.config.SyncWrites {
+		if err = batch.db.syncActiveFile(); err != nil {
+			return nil, err
 		}
 	}
 
-	// update index for log record
-	for key, logRecord := range batch.pendingWrites {
-		pos := positionMap[key]
-		if logRecord.Type == storage.LogRecordNormal {
-			batch.db.index.Put(logRecord.Key, pos)
-		} else if logRecord.Type == storage.LogRecordDeleted {
-			batch.db.index.Delete(logRecord.Key)
-		}
-	}
-
-	// clean up cache
-	batch.pendingWrites = make(map[string]*storage.LogRecord)
-
-	return nil
+	return positionMap, nil
 }
 
 func (batch *WriteBatch) generateGlobalIncrementSequenceNumber() uint64 {
@@ -0,0 +1,600 @@
+package bitcask_go
+
+import (
+	"bitcask-go/storage"
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// max bytes of log records exported before writing events and checkpoint
+	cdcChunkSize          = 1024 * 1024
+	cdcCheckpointFileName = "cdc-checkpoint"
+)
+
+type ChangeOp = string
+
+const (
+	ChangeOpPut         ChangeOp = "put"
+	ChangeOpDelete      ChangeOp = "delete"
+	ChangeOpDeleteRange ChangeOp = "deleteRange" // Key is inclusive start and Value is exclusive end of range
+)
+
+// ChangeEvent committed mutation, events of a write batch are exported together once the batch is finished
+type ChangeEvent struct {
+	Op        ChangeOp `json:"op"`
+	Key       []byte   `json:"key"`
+	Value     []byte   `json:"value,omitempty"`
+	SeqNo     uint64   `json:"seq"`       // sequence number of write batch, 0 for single write
+	BatchSize int      `json:"batchSize"` // number of events committed in the same batch, 0 for single write
+	Fid       uint32   `json:"fid"`       // position of record in data files, increasing in write order
+	Offset    int64    `json:"offset"`
+}
+
+// cdcCheckpoint exported position of log, and end of event file written up to it
+type cdcCheckpoint struct {
+	Fid      uint32 `json:"fid"`
+	Offset   int64  `json:"offset"`
+	File     uint32 `json:"file"`
+	FileSize int64  `json:"fileSize"`
+}
+
+// CDCExporter tail the records appended to data files and export committed mutations as change events.
+// Events written to files are truncated back to the checkpoint on restart, so each of them is exported once,
+// events written to Writer after the last checkpoint are exported again on restart.
+// Data files not exported yet are left out of merge while exporter is running
+type CDCExporter struct {
+	db     *DB
+	config CDCConfig
+
+	mu            *sync.Mutex
+	checkpoint    cdcCheckpoint // guarded by mu, read by merge
+	mergeBoundary uint32        // records of files before it are rewritten by merge, they have no transaction finish record
+	readPos       *storage.LogRecordPos
+	pending       []*ChangeEvent // records of unfinished write batch
+	pendingStart  *storage.LogRecordPos
+
+	writer io.Writer
+	file   *os.File // current event file, nil if events go to config.Writer
+	buf    *bytes.Buffer
+	err    error
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// StartCDCExporter resume exporting from checkpoint, or from the start of log if there is no checkpoint
+func (db *DB) StartCDCExporter(config CDCConfig) (*CDCExporter, error) {
+	if config.Writer == nil && config.DirPath == "" {
+		return nil, ErrInvalidCDCConfig
+	}
+	if config.CheckpointPath == "" && config.DirPath != "" {
+		config.CheckpointPath = filepath.Join(config.DirPath, cdcCheckpointFileName)
+	}
+	if err := db.waitIndexReady(); err != nil {
+		return nil, err
+	}
+
+	mergeBoundary, err := db.replicationMergeBoundary()
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, found, err := loadCDCCheckpoint(config.CheckpointPath)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		checkpoint = cdcCheckpoint{Fid: initialDataFileId}
+	} else if checkpoint.Fid < mergeBoundary {
+		return nil, ErrCDCCheckpointMerged
+	}
+
+	e := &CDCExporter{
+		db:            db,
+		config:        config,
+		mu:            new(sync.Mutex),
+		checkpoint:    checkpoint,
+		mergeBoundary: mergeBoundary,
+		readPos:       &storage.LogRecordPos{Fid: checkpoint.Fid, Offset: checkpoint.Offset},
+		writer:        config.Writer,
+		buf:           new(bytes.Buffer),
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+	if config.Writer == nil {
+		if err := e.openEventFile(checkpoint.File, checkpoint.FileSize); err != nil {
+			return nil, err
+		}
+	}
+
+	db.mu.Lock()
+	if db.cdc != nil {
+		db.mu.Unlock()
+		e.closeEventFile()
+		return nil, ErrCDCStarted
+	}
+	db.cdc = e
+	db.mu.Unlock()
+
+	go e.run()
+	return e, nil
+}
+
+// Close stop exporting, the error stopped exporter is returned
+func (e *CDCExporter) Close() error {
+	e.stop()
+
+	e.db.mu.Lock()
+	if e.db.cdc == e {
+		e.db.cdc = nil
+	}
+	e.db.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Err error stopped exporter, nil if it's running
+func (e *CDCExporter) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Position of the log exported, the next record to export
+func (e *CDCExporter) Position() (uint32, int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.checkpoint.Fid, e.checkpoint.Offset
+}
+
+func (e *CDCExporter) stop() {
+	select {
+	case <-e.stopCh:
+	default:
+		close(e.stopCh)
+	}
+	<-e.doneCh
+}
+
+func (e *CDCExporter) run() {
+	defer close(e.doneCh)
+	defer e.closeEventFile()
+
+	for {
+		end, appended := e.db.replicationEnd()
+		progressed, err := e.export(end)
+		if err != nil {
+			e.mu.Lock()
+			e.err = err
+			e.mu.Unlock()
+			return
+		}
+		if progressed {
+			select {
+			case <-e.stopCh:
+				return
+			default:
+			}
+			continue
+		}
+
+		select {
+		case <-e.stopCh:
+			return
+		case <-appended:
+		}
+	}
+}
+
+// export a chunk of log before end, return false if there is nothing to export
+func (e *CDCExporter) export(end *storage.LogRecordPos) (bool, error) {
+	pos := e.readPos
+
+	var pairs []*storage.LogRecordPositionPair
+	var err error
+	switch {
+	case end == nil:
+		return false, nil
+	case pos.Fid < end.Fid:
+		pairs, err = e.db.readLogRecords(pos.Fid, pos.Offset, -1, cdcChunkSize)
+		if err == ErrDataFileNotFound || (err == nil && len(pairs) == 0) {
+			// file is finished or removed by merge, go on with next one
+			e.readPos = &storage.LogRecordPos{Fid: pos.Fid + 1, Offset: 0}
+			return true, nil
+		}
+	case pos.Fid == end.Fid && pos.Offset < end.Offset:
+		pairs, err = e.db.readLogRecords(pos.Fid, pos.Offset, end.Offset, cdcChunkSize)
+	case pos.Fid == end.Fid && pos.Offset == end.Offset:
+		return false, nil
+	default:
+		return false, ErrInvalidCDCCheckpoint
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, pair := range pairs {
+		if err := e.handleRecord(pair.Record, pair.Pos); err != nil {
+			return false, err
+		}
+	}
+	return true, e.flush()
+}
+
+// handleRecord encode record as event, records of write batch are held until the batch is finished
+func (e *CDCExporter) handleRecord(logRecord *storage.LogRecord, pos *storage.LogRecordPos) error {
+	e.readPos = &storage.LogRecordPos{Fid: pos.Fid, Offset: pos.Offset + int64(pos.LogRecordSize)}
+
+	if logRecord.Type == storage.LogRecordTransactionFinished {
+		if len(e.pending) > 0 && e.pending[0].SeqNo == logRecord.SequenceNumber {
+			for _, event := range e.pending {
+				event.BatchSize = len(e.pending)
+				if err := e.encode(event); err != nil {
+					return err
+				}
+			}
+		}
+		e.pending = nil
+		return nil
+	}
+
+	event := &ChangeEvent{
+		Op:     ChangeOpPut,
+		Key:    logRecord.Key,
+		Value:  logRecord.Value,
+		SeqNo:  logRecord.SequenceNumber,
+		Fid:    pos.Fid,
+		Offset: pos.Offset,
+	}
+	switch logRecord.Type {
+	case storage.LogRecordDeleted:
+		event.Op, event.Value = ChangeOpDelete, nil
+	case storage.LogRecordRangeDeleted:
+		event.Op = ChangeOpDeleteRange
+	}
+
+	// records of merged files are committed, they have no transaction finish record
+	if logRecord.SequenceNumber == nonTransactionSequenceNumber || pos.Fid < e.mergeBoundary {
+		// batch is written at once while holding appendMu, records of unfinished batch before are never committed
+		e.pending = nil
+		return e.encode(event)
+	}
+
+	if len(e.pending) > 0 && e.pending[0].SeqNo != logRecord.SequenceNumber {
+		e.pending = nil
+	}
+	if len(e.pending) == 0 {
+		e.pendingStart = &storage.LogRecordPos{Fid: pos.Fid, Offset: pos.Offset}
+	}
+	e.pending = append(e.pending, event)
+	return nil
+}
+
+func (e *CDCExporter) encode(event *ChangeEvent) error {
+	if e.config.Format == CDCFormatBinary {
+		e.buf.Write(EncodeChangeEvent(event))
+		return nil
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	e.buf.Write(data)
+	e.buf.WriteByte('\n')
+	return nil
+}
+
+// flush write encoded events, then move checkpoint to the start of unfinished batch or the end of records read
+func (e *CDCExporter) flush() error {
+	checkpoint := e.checkpoint
+	if e.buf.Len() > 0 {
+		if _, err := e.writer.Write(e.buf.Bytes()); err != nil {
+			return err
+		}
+		checkpoint.FileSize += int64(e.buf.Len())
+		e.buf.Reset()
+
+		if syncer, ok := e.writer.(interface{ Sync() error }); ok {
+			if err := syncer.Sync(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(e.pending) > 0 {
+		checkpoint.Fid, checkpoint.Offset = e.pendingStart.Fid, e.pendingStart.Offset
+	} else {
+		checkpoint.Fid, checkpoint.Offset = e.readPos.Fid, e.readPos.Offset
+	}
+
+	if e.file != nil && e.config.MaxFileSize > 0 && checkpoint.FileSize >= e.config.MaxFileSize {
+		e.closeEventFile()
+		checkpoint.File, checkpoint.FileSize = checkpoint.File+1, 0
+		if err := e.openEventFile(checkpoint.File, 0); err != nil {
+			return err
+		}
+	}
+
+	if err := saveCDCCheckpoint(e.config.CheckpointPath, checkpoint); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.checkpoint = checkpoint
+	e.mu.Unlock()
+	return nil
+}
+
+// mergeLimit first file merge must keep, files not exported yet are not merged.
+// Nothing is merged while exporter is reading files rewritten by the last merge
+func (e *CDCExporter) mergeLimit() uint32 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.checkpoint.Fid < e.mergeBoundary {
+		return 0
+	}
+	return e.checkpoint.Fid
+}
+
+// openEventFile open event file truncated to size, event files after it are written after checkpoint and removed
+func (e *CDCExporter) openEventFile(fileId uint32, size int64) error {
+	if err := os.MkdirAll(e.config.DirPath, os.ModePerm); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(e.config.DirPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		id, ok := parseCDCEventFileName(entry.Name())
+		if ok && id > fileId {
+			if err := os.Remove(filepath.Join(e.config.DirPath, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	file, err := os.OpenFile(e.eventFileName(fileId), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := file.Truncate(size); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		_ = file.Close()
+		return err
+	}
+	e.file, e.writer = file, file
+	return nil
+}
+
+func (e *CDCExporter) closeEventFile() {
+	if e.file != nil {
+		_ = e.file.Close()
+		e.file = nil
+	}
+}
+
+func (e *CDCExporter) eventFileName(fileId uint32) string {
+	ext := ".jsonl"
+	if e.config.Format == CDCFormatBinary {
+		ext = ".cdc"
+	}
+	return filepath.Join(e.config.DirPath, fmt.Sprintf("%09d%s", fileId, ext))
+}
+
+func parseCDCEventFileName(name string) (uint32, bool) {
+	ext := filepath.Ext(name)
+	if ext != ".jsonl" && ext != ".cdc" {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSuffix(name, ext), 10, 32)
+	return uint32(id), err == nil
+}
+
+func loadCDCCheckpoint(path string) (cdcCheckpoint, bool, error) {
+	var checkpoint cdcCheckpoint
+	if path == "" {
+		return checkpoint, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return checkpoint, false, nil
+		}
+		return checkpoint, false, err
+	}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return checkpoint, false, err
+	}
+	return checkpoint, true, nil
+}
+
+// saveCDCCheckpoint replace checkpoint file by rename, so it's never partially written
+func saveCDCCheckpoint(path string, checkpoint cdcCheckpoint) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// stopCDC stop exporter before closing files
+func (db *DB) stopCDC() {
+	db.mu.RLock()
+	cdc := db.cdc
+	db.mu.RUnlock()
+
+	if cdc != nil {
+		cdc.stop()
+	}
+}
+
+// readLogRecords read records of data file from offset until end, or the end of file if end is negative,
+// reading stops once maxBytes are read
+func (db *DB) readLogRecords(fid uint32, offset, end int64, maxBytes int64) ([]*storage.LogRecordPositionPair, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dataFile, release, err := db.acquireDataFile(fid)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var pairs []*storage.LogRecordPositionPair
+	var read int64
+	for (end < 0 || offset < end) && read < maxBytes {
+		logRecord, size, err := dataFile.ReadLogRecord(offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, &storage.LogRecordPositionPair{
+			Record: logRecord,
+			Pos:    &storage.LogRecordPos{Fid: fid, Offset: offset, LogRecordSize: uint32(size)},
+		})
+		offset += size
+		read += size
+	}
+	return pairs, nil
+}
+
+// EncodeChangeEvent encode event in binary format:
+// uvarint size | op | uvarint seq | uvarint batchSize | uvarint fid | varint offset | uvarint keySize | key | uvarint valueSize | value | crc
+func EncodeChangeEvent(event *ChangeEvent) []byte {
+	payload := make([]byte, 1+6*binary.MaxVarintLen64+len(event.Key)+len(event.Value))
+	switch event.Op {
+	case ChangeOpDelete:
+		payload[0] = storage.LogRecordDeleted
+	case ChangeOpDeleteRange:
+		payload[0] = storage.LogRecordRangeDeleted
+	default:
+		payload[0] = storage.LogRecordNormal
+	}
+	index := 1
+	index += binary.PutUvarint(payload[index:], event.SeqNo)
+	index += binary.PutUvarint(payload[index:], uint64(event.BatchSize))
+	index += binary.PutUvarint(payload[index:], uint64(event.Fid))
+	index += binary.PutVarint(payload[index:], event.Offset)
+	index += binary.PutUvarint(payload[index:], uint64(len(event.Key)))
+	index += copy(payload[index:], event.Key)
+	index += binary.PutUvarint(payload[index:], uint64(len(event.Value)))
+	index += copy(payload[index:], event.Value)
+	payload = payload[:index]
+
+	buf := binary.AppendUvarint(nil, uint64(len(payload)))
+	buf = append(buf, payload...)
+	return binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(payload))
+}
+
+// ReadChangeEvent read next event in binary format, io.EOF is returned at the end of events
+func ReadChangeEvent(reader *bufio.Reader) (*ChangeEvent, error) {
+	size, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size+crc32.Size)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	payload := buf[:size]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(buf[size:]) {
+		return nil, ErrInvalidChangeEvent
+	}
+	return decodeChangeEventPayload(payload)
+}
+
+func decodeChangeEventPayload(payload []byte) (*ChangeEvent, error) {
+	if len(payload) == 0 {
+		return nil, ErrInvalidChangeEvent
+	}
+	event := &ChangeEvent{Op: ChangeOpPut}
+	switch payload[0] {
+	case storage.LogRecordDeleted:
+		event.Op = ChangeOpDelete
+	case storage.LogRecordRangeDeleted:
+		event.Op = ChangeOpDeleteRange
+	}
+
+	index := 1
+	var errInvalid error
+	readUvarint := func() uint64 {
+		v, n := binary.Uvarint(payload[index:])
+		if n <= 0 {
+			errInvalid = ErrInvalidChangeEvent
+			return 0
+		}
+		index += n
+		return v
+	}
+	readBytes := func() []byte {
+		size := readUvarint()
+		if errInvalid != nil || uint64(len(payload)-index) < size {
+			errInvalid = ErrInvalidChangeEvent
+			return nil
+		}
+		b := payload[index : index+int(size)]
+		index += int(size)
+		return b
+	}
+
+	event.SeqNo = readUvarint()
+	event.BatchSize = int(readUvarint())
+	event.Fid = uint32(readUvarint())
+	offset, n := binary.Varint(payload[min(index, len(payload)):])
+	if n <= 0 {
+		return nil, ErrInvalidChangeEvent
+	}
+	index += n
+	event.Offset = offset
+	event.Key = readBytes()
+	event.Value = readBytes()
+	if errInvalid != nil {
+		return nil, errInvalid
+	}
+	if event.Op == ChangeOpDelete {
+		event.Value = nil
+	}
+	return event, nil
+}
@@ -0,0 +1,297 @@
+package bitcask_go
+
+import (
+	"bitcask-go/utils"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// eventBuffer collect events written by exporter, Write blocks until gate is closed
+type eventBuffer struct {
+	mu   *sync.Mutex
+	buf  *bytes.Buffer
+	gate chan struct{}
+}
+
+func newEventBuffer() *eventBuffer {
+	gate := make(chan struct{})
+	close(gate)
+	return &eventBuffer{mu: new(sync.Mutex), buf: new(bytes.Buffer), gate: gate}
+}
+
+func (b *eventBuffer) Write(p []byte) (int, error) {
+	<-b.gate
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *eventBuffer) jsonEvents(t *testing.T) []*ChangeEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var events []*ChangeEvent
+	decoder := json.NewDecoder(bytes.NewReader(b.buf.Bytes()))
+	for {
+		event := new(ChangeEvent)
+		if err := decoder.Decode(event); err == io.EOF {
+			return events
+		} else {
+			assert.Nil(t, err)
+		}
+		events = append(events, event)
+	}
+}
+
+// readEventFiles read binary events of all the event files in order
+func readEventFiles(t *testing.T, dirPath string) []*ChangeEvent {
+	names, err := filepath.Glob(filepath.Join(dirPath, "*.cdc"))
+	assert.Nil(t, err)
+	sort.Strings(names)
+
+	var events []*ChangeEvent
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		assert.Nil(t, err)
+		reader := bufio.NewReader(bytes.NewReader(data))
+		for {
+			event, err := ReadChangeEvent(reader)
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(t, err)
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestCDCExporter_JSONLines(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-cdc-json")
+	opts.DirPath = dir
+	db, err := OpenDatabase(opts)
+	defer destroyDatabase(db)
+	assert.Nil(t, err)
+
+	// writes before exporter starts are exported from the start of log
+	assert.Nil(t, db.Put([]byte("key-1"), []byte("value-1")))
+
+	buf := newEventBuffer()
+	config := DefaultCDCConfig
+	config.Writer = buf
+	exporter, err := db.StartCDCExporter(config)
+	assert.Nil(t, err)
+	defer exporter.Close()
+
+	_, err = db.StartCDCExporter(config)
+	assert.Equal(t, ErrCDCStarted, err)
+
+	assert.Nil(t, db.Delete([]byte("key-1")))
+	batch := db.NewWriteBatch(DefaultWriteBatchConfig)
+	assert.Nil(t, batch.Put([]byte("key-2"), []byte("value-2")))
+	assert.Nil(t, batch.Put([]byte("key-3"), []byte("value-3")))
+	assert.Nil(t, batch.Commit())
+	assert.Nil(t, db.DeleteRange([]byte("key-2"), []byte("key-3")))
+
+	var events []*ChangeEvent
+	assert.Eventually(t, func() bool {
+		events = buf.jsonEvents(t)
+		return len(events) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, ChangeOpPut, events[0].Op)
+	assert.Equal(t, []byte("key-1"), events[0].Key)
+	assert.Equal(t, []byte("value-1"), events[0].Value)
+	assert.Equal(t, 0, events[0].BatchSize)
+
+	assert.Equal(t, ChangeOpDelete, events[1].Op)
+	assert.Equal(t, []byte("key-1"), events[1].Key)
+	assert.Nil(t, events[1].Value)
+
+	for _, event := range events[2:4] {
+		assert.Equal(t, ChangeOpPut, event.Op)
+		assert.Equal(t, 2, event.BatchSize)
+		assert.NotZero(t, event.SeqNo)
+		assert.Equal(t, events[2].SeqNo, event.SeqNo)
+	}
+	assert.ElementsMatch(t, [][]byte{[]byte("key-2"), []byte("key-3")}, [][]byte{events[2].Key, events[3].Key})
+
+	assert.Equal(t, ChangeOpDeleteRange, events[4].Op)
+	assert.Equal(t, []byte("key-2"), events[4].Key)
+	assert.Equal(t, []byte("key-3"), events[4].Value)
+
+	// events are in the order of log
+	for i := 1; i < len(events); i++ {
+		assert.True(t, events[i].Fid > events[i-1].Fid ||
+			(events[i].Fid == events[i-1].Fid && events[i].Offset > events[i-1].Offset))
+	}
+}
+
+func TestCDCExporter_ResumeFromCheckpoint(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-cdc-resume")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	db, err := OpenDatabase(opts)
+	assert.Nil(t, err)
+
+	cdcDir, _ := os.MkdirTemp("", "bitcask-go-cdc-events")
+	defer func() {
+		_ = os.RemoveAll(cdcDir)
+	}()
+	config := DefaultCDCConfig
+	config.Format = CDCFormatBinary
+	config.DirPath = cdcDir
+	config.MaxFileSize = 16 * 1024
+
+	exporter, err := db.StartCDCExporter(config)
+	assert.Nil(t, err)
+	for i := 0; i < 500; i++ {
+		assert.Nil(t, db.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+	assert.Eventually(t, func() bool {
+		return len(readEventFiles(t, cdcDir)) == 500
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, exporter.Close())
+
+	// writes while exporter is stopped are exported after restart
+	for i := 500; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+	assert.Nil(t, db.Close())
+
+	db, err = OpenDatabase(opts)
+	defer destroyDatabase(db)
+	assert.Nil(t, err)
+	exporter, err = db.StartCDCExporter(config)
+	assert.Nil(t, err)
+	defer exporter.Close()
+
+	var events []*ChangeEvent
+	assert.Eventually(t, func() bool {
+		events = readEventFiles(t, cdcDir)
+		return len(events) >= 1000
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1000, len(events))
+	for i, event := range events {
+		assert.Equal(t, utils.GenerateTestKey(i), event.Key)
+	}
+
+	// event files are rotated
+	names, _ := filepath.Glob(filepath.Join(cdcDir, "*.cdc"))
+	assert.Greater(t, len(names), 1)
+}
+
+func TestCDCExporter_HoldMerge(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-cdc-merge")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	opts.MergeRatio = 0
+	db, err := OpenDatabase(opts)
+	defer destroyDatabase(db)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GenerateTestKey(i%100), utils.GenerateRandomValue(64)))
+	}
+
+	// exporter is blocked before exporting anything
+	buf := newEventBuffer()
+	buf.gate = make(chan struct{})
+	config := DefaultCDCConfig
+	config.Writer = buf
+	exporter, err := db.StartCDCExporter(config)
+	assert.Nil(t, err)
+	defer exporter.Close()
+
+	assert.Nil(t, db.Merge())
+	_, err = os.Stat(db.getMergeDirPath())
+	assert.True(t, os.IsNotExist(err))
+
+	close(buf.gate)
+	assert.Eventually(t, func() bool {
+		return len(buf.jsonEvents(t)) == 1000
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// exported files are merged
+	assert.Nil(t, db.Merge())
+	_, err = os.Stat(db.getMergeDirPath())
+	assert.Nil(t, err)
+}
+
+func TestCDCExporter_CheckpointMerged(t *testing.T) {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-cdc-merged")
+	opts.DirPath = dir
+	opts.DataFileSize = 32 * 1024
+	opts.MergeRatio = 0
+	db, err := OpenDatabase(opts)
+	assert.Nil(t, err)
+
+	cdcDir, _ := os.MkdirTemp("", "bitcask-go-cdc-events")
+	defer func() {
+		_ = os.RemoveAll(cdcDir)
+	}()
+	config := DefaultCDCConfig
+	config.DirPath = cdcDir
+
+	exporter, err := db.StartCDCExporter(config)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Put([]byte("key"), []byte("value")))
+	assert.Eventually(t, func() bool {
+		_, offset := exporter.Position()
+		return offset > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, exporter.Close())
+
+	// data files after checkpoint are merged while exporter is stopped
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, db.Put(utils.GenerateTestKey(i%100), utils.GenerateRandomValue(64)))
+	}
+	assert.Nil(t, db.Merge())
+	assert.Nil(t, db.Close())
+
+	db, err = OpenDatabase(opts)
+	defer destroyDatabase(db)
+	assert.Nil(t, err)
+	_, err = db.StartCDCExporter(config)
+	assert.Equal(t, ErrCDCCheckpointMerged, err)
+}
+
+func TestChangeEvent_EncodeDecode(t *testing.T) {
+	events := []*ChangeEvent{
+		{Op: ChangeOpPut, Key: []byte("key"), Value: []byte("value"), SeqNo: 3, BatchSize: 2, Fid: 1, Offset: 100},
+		{Op: ChangeOpDelete, Key: []byte("key"), Fid: 2, Offset: 0},
+		{Op: ChangeOpDeleteRange, Key: []byte("a"), Value: []byte("b"), Fid: 2, Offset: 20},
+	}
+	var buf []byte
+	for _, event := range events {
+		buf = append(buf, EncodeChangeEvent(event)...)
+	}
+
+	reader := bufio.NewReader(bytes.NewReader(buf))
+	for _, expected := range events {
+		event, err := ReadChangeEvent(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, event)
+	}
+	_, err := ReadChangeEvent(reader)
+	assert.Equal(t, io.EOF, err)
+
+	// corrupted event
+	buf[3] ^= 0xff
+	_, err = ReadChangeEvent(bufio.NewReader(bytes.NewReader(buf)))
+	assert.Equal(t, ErrInvalidChangeEvent, err)
+}
@@ -0,0 +1,158 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+
+	bitcask "bitcask-go"
+	"bitcask-go/raft"
+)
+
+// suffix of directory of raft state next to db directory
+const raftDirSuffix = ".raft"
+
+var (
+	ErrUnsupportedConfig = errors.New("in memory or read only database can't be used by cluster")
+	ErrInvalidSnapshot   = errors.New("invalid snapshot")
+)
+
+// Node of cluster, writes are proposed to raft and applied to db of every node once committed on quorum.
+// A restarted node restores the saved snapshot and applies the saved log again, then catches up from leader
+type Node struct {
+	raft    *raft.Node
+	sm      *stateMachine
+	storage *raft.FileStorage // raft state opened by cluster, nil if it's given by raftConfig.Storage
+}
+
+// NewNode open db and start raft node, raftConfig.StateMachine is set by cluster.
+// Raft state is saved in directory DirPath + ".raft" next to db if raftConfig.Storage is nil
+func NewNode(dbConfig bitcask.Config, raftConfig raft.Config) (*Node, error) {
+	if dbConfig.InMemory || dbConfig.ReadOnly {
+		return nil, ErrUnsupportedConfig
+	}
+
+	db, err := bitcask.OpenDatabase(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// db directory is replaced by snapshot, so raft state is kept out of it
+	var storage *raft.FileStorage
+	if raftConfig.Storage == nil {
+		if storage, err = raft.OpenFileStorage(filepath.Clean(dbConfig.DirPath) + raftDirSuffix); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+		raftConfig.Storage = storage
+	}
+
+	sm := &stateMachine{mu: new(sync.RWMutex), db: db, dbConfig: dbConfig}
+	raftConfig.StateMachine = sm
+	raftNode, err := raft.NewNode(raftConfig)
+	if err != nil {
+		_ = sm.close()
+		if storage != nil {
+			_ = storage.Close()
+		}
+		return nil, err
+	}
+	return &Node{raft: raftNode, sm: sm, storage: storage}, nil
+}
+
+// Put key/value through leader, return after it's applied on this node
+func (n *Node) Put(ctx context.Context, key []byte, value []byte) error {
+	if len(key) == 0 {
+		return bitcask.ErrKeyIsEmpty
+	}
+	return n.raft.Propose(ctx, encodeCommand([]operation{{op: opPut, key: key, value: value}}))
+}
+
+// Delete key through leader, return after it's applied on this node
+func (n *Node) Delete(ctx context.Context, key []byte) error {
+	if len(key) == 0 {
+		return bitcask.ErrKeyIsEmpty
+	}
+	return n.raft.Propose(ctx, encodeCommand([]operation{{op: opDelete, key: key}}))
+}
+
+// Get value from local db, it may be stale on follower
+func (n *Node) Get(key []byte) ([]byte, error) {
+	return n.sm.get(key)
+}
+
+// Raft node of cluster node, it receives messages from transport
+func (n *Node) Raft() *raft.Node {
+	return n.raft
+}
+
+// IsLeader check if this node accepts writes
+func (n *Node) IsLeader() bool {
+	return n.raft.Status().State == raft.StateLeader
+}
+
+// Leader id of leader known by this node, 0 if unknown
+func (n *Node) Leader() uint64 {
+	return n.raft.Status().Leader
+}
+
+// Close stop raft node and close db
+func (n *Node) Close() error {
+	n.raft.Stop()
+	err := n.sm.close()
+	if n.storage != nil {
+		if storageErr := n.storage.Close(); err == nil {
+			err = storageErr
+		}
+	}
+	return err
+}
+
+// WriteBatch collect writes and commit them as one command, applied atomically on every node
+type WriteBatch struct {
+	node *Node
+	mu   *sync.Mutex
+	ops  []operation
+}
+
+func (n *Node) NewWriteBatch() *WriteBatch {
+	return &WriteBatch{node: n, mu: new(sync.Mutex)}
+}
+
+func (batch *WriteBatch) Put(key, value []byte) error {
+	if len(key) == 0 {
+		return bitcask.ErrKeyIsEmpty
+	}
+
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	batch.ops = append(batch.ops, operation{op: opPut, key: key, value: value})
+	return nil
+}
+
+func (batch *WriteBatch) Delete(key []byte) error {
+	if len(key) == 0 {
+		return bitcask.ErrKeyIsEmpty
+	}
+
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	batch.ops = append(batch.ops, operation{op: opDelete, key: key})
+	return nil
+}
+
+// Commit propose the writes through leader, batch is reset once they are applied
+func (batch *WriteBatch) Commit(ctx context.Context) error {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+
+	if len(batch.ops) == 0 {
+		return nil
+	}
+	if err := batch.node.raft.Propose(ctx, encodeCommand(batch.ops)); err != nil {
+		return err
+	}
+	batch.ops = nil
+	return nil
+}
@@ -0,0 +1,258 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bitcask "bitcask-go"
+	"bitcask-go/raft"
+	"bitcask-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCluster struct {
+	dir       string
+	transport *raft.InMemoryTransport
+	peers     []uint64
+	nodes     map[uint64]*Node
+	threshold uint64
+}
+
+func newTestCluster(t *testing.T, nodeNum int, snapshotThreshold uint64) *testCluster {
+	dir, _ := os.MkdirTemp("", "bitcask-go-cluster")
+	c := &testCluster{
+		dir:       dir,
+		transport: raft.NewInMemoryTransport(),
+		nodes:     make(map[uint64]*Node),
+		threshold: snapshotThreshold,
+	}
+	for i := 1; i <= nodeNum; i++ {
+		c.peers = append(c.peers, uint64(i))
+	}
+	for _, id := range c.peers {
+		c.start(t, id)
+	}
+	t.Cleanup(func() {
+		for id := range c.nodes {
+			c.stop(id)
+		}
+		_ = os.RemoveAll(dir)
+	})
+	return c
+}
+
+func (c *testCluster) start(t *testing.T, id uint64) {
+	dbConfig := bitcask.DefaultConfig
+	dbConfig.DirPath = filepath.Join(c.dir, fmt.Sprintf("node-%d", id))
+	dbConfig.DataFileSize = 64 * 1024
+
+	raftConfig := raft.DefaultConfig
+	raftConfig.ID = id
+	raftConfig.Peers = c.peers
+	raftConfig.Transport = c.transport
+	raftConfig.SnapshotThreshold = c.threshold
+
+	node, err := NewNode(dbConfig, raftConfig)
+	assert.Nil(t, err)
+	c.nodes[id] = node
+	c.transport.Register(id, node.Raft())
+}
+
+func (c *testCluster) stop(id uint64) {
+	c.transport.Unregister(id)
+	_ = c.nodes[id].Close()
+	delete(c.nodes, id)
+}
+
+// waitLeader wait until the given nodes agree on a leader among them
+func (c *testCluster) waitLeader(t *testing.T, ids ...uint64) *Node {
+	var leader uint64
+	assert.Eventually(t, func() bool {
+		leader = 0
+		for _, id := range ids {
+			known := c.nodes[id].Leader()
+			if known == 0 || (leader != 0 && known != leader) {
+				return false
+			}
+			leader = known
+		}
+		node, ok := c.nodes[leader]
+		return ok && node.IsLeader()
+	}, 5*time.Second, 10*time.Millisecond)
+	return c.nodes[leader]
+}
+
+func (c *testCluster) waitValue(t *testing.T, key, value []byte, ids ...uint64) {
+	for _, id := range ids {
+		assert.Eventually(t, func() bool {
+			val, err := c.nodes[id].Get(key)
+			if value == nil {
+				return err == bitcask.ErrKeyNotFound
+			}
+			return err == nil && string(val) == string(value)
+		}, 5*time.Second, 10*time.Millisecond, "node %d", id)
+	}
+}
+
+func TestNode_PutGetDelete(t *testing.T) {
+	c := newTestCluster(t, 3, 0)
+	leader := c.waitLeader(t, c.peers...)
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, leader.Put(ctx, utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, leader.Delete(ctx, utils.GenerateTestKey(0)))
+	assert.Equal(t, bitcask.ErrKeyIsEmpty, leader.Put(ctx, nil, []byte("v")))
+
+	c.waitValue(t, utils.GenerateTestKey(99), utils.GenerateTestKey(99), c.peers...)
+	c.waitValue(t, utils.GenerateTestKey(0), nil, c.peers...)
+
+	for _, id := range c.peers {
+		if node := c.nodes[id]; node != leader {
+			assert.Equal(t, raft.ErrNotLeader, node.Put(ctx, []byte("key"), []byte("value")))
+		}
+	}
+}
+
+func TestNode_WriteBatch(t *testing.T) {
+	c := newTestCluster(t, 3, 0)
+	leader := c.waitLeader(t, c.peers...)
+	ctx := context.Background()
+
+	assert.Nil(t, leader.Put(ctx, []byte("deleted"), []byte("value")))
+
+	batch := leader.NewWriteBatch()
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, batch.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, batch.Delete([]byte("deleted")))
+
+	// nothing is applied before commit
+	_, err := leader.Get(utils.GenerateTestKey(0))
+	assert.Equal(t, bitcask.ErrKeyNotFound, err)
+
+	assert.Nil(t, batch.Commit(ctx))
+	for i := 0; i < 10; i++ {
+		c.waitValue(t, utils.GenerateTestKey(i), utils.GenerateTestKey(i), c.peers...)
+	}
+	c.waitValue(t, []byte("deleted"), nil, c.peers...)
+}
+
+func TestNode_Failover(t *testing.T) {
+	c := newTestCluster(t, 3, 0)
+	leader := c.waitLeader(t, c.peers...)
+	ctx := context.Background()
+	assert.Nil(t, leader.Put(ctx, []byte("key"), []byte("value-1")))
+
+	var oldLeaderId uint64
+	var rest []uint64
+	for id, node := range c.nodes {
+		if node == leader {
+			oldLeaderId = id
+		} else {
+			rest = append(rest, id)
+		}
+	}
+	c.stop(oldLeaderId)
+
+	newLeader := c.waitLeader(t, rest...)
+	val, err := newLeader.Get([]byte("key"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-1"), val)
+
+	assert.Nil(t, newLeader.Put(ctx, []byte("key"), []byte("value-2")))
+	c.waitValue(t, []byte("key"), []byte("value-2"), rest...)
+
+	// old leader rejoins with its db, and catches up by log of new leader
+	c.start(t, oldLeaderId)
+	c.waitValue(t, []byte("key"), []byte("value-2"), c.peers...)
+}
+
+func TestNode_SnapshotCatchUp(t *testing.T) {
+	c := newTestCluster(t, 3, 50)
+	leader := c.waitLeader(t, c.peers...)
+	ctx := context.Background()
+
+	var lagging uint64
+	for id, node := range c.nodes {
+		if node != leader {
+			lagging = id
+			break
+		}
+	}
+	c.stop(lagging)
+	// data of lagging node is lost, it can only catch up by snapshot since log is compacted
+	_ = os.RemoveAll(filepath.Join(c.dir, fmt.Sprintf("node-%d", lagging)))
+	_ = os.RemoveAll(filepath.Join(c.dir, fmt.Sprintf("node-%d", lagging)+raftDirSuffix))
+
+	for i := 0; i < 200; i++ {
+		assert.Nil(t, leader.Put(ctx, utils.GenerateTestKey(i), utils.GenerateRandomValue(128)))
+	}
+	assert.Nil(t, leader.Delete(ctx, utils.GenerateTestKey(0)))
+	assert.Greater(t, leader.Raft().Status().AppliedIndex, uint64(200))
+
+	c.start(t, lagging)
+	for i := 1; i < 200; i++ {
+		val, err := leader.Get(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+		c.waitValue(t, utils.GenerateTestKey(i), val, lagging)
+	}
+	c.waitValue(t, utils.GenerateTestKey(0), nil, lagging)
+
+	// restored node keeps applying new writes
+	assert.Nil(t, leader.Put(ctx, []byte("after-snapshot"), []byte("value")))
+	c.waitValue(t, []byte("after-snapshot"), []byte("value"), c.peers...)
+}
+
+func TestNode_Restart(t *testing.T) {
+	c := newTestCluster(t, 3, 50)
+	leader := c.waitLeader(t, c.peers...)
+	ctx := context.Background()
+
+	for i := 0; i < 120; i++ {
+		assert.Nil(t, leader.Put(ctx, utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, leader.Delete(ctx, utils.GenerateTestKey(0)))
+	c.waitValue(t, utils.GenerateTestKey(119), utils.GenerateTestKey(119), c.peers...)
+
+	// every node restarts from its saved snapshot and log
+	for _, id := range c.peers {
+		c.stop(id)
+	}
+	for _, id := range c.peers {
+		c.start(t, id)
+	}
+	leader = c.waitLeader(t, c.peers...)
+	assert.Nil(t, leader.Put(ctx, []byte("after-restart"), []byte("value")))
+	c.waitValue(t, []byte("after-restart"), []byte("value"), c.peers...)
+	for i := 1; i < 120; i++ {
+		c.waitValue(t, utils.GenerateTestKey(i), utils.GenerateTestKey(i), c.peers...)
+	}
+	c.waitValue(t, utils.GenerateTestKey(0), nil, c.peers...)
+}
+
+func TestNewNode_UnsupportedConfig(t *testing.T) {
+	dbConfig := bitcask.DefaultConfig
+	dbConfig.InMemory = true
+	_, err := NewNode(dbConfig, raft.DefaultConfig)
+	assert.Equal(t, ErrUnsupportedConfig, err)
+}
+
+func TestCommand_EncodeDecode(t *testing.T) {
+	ops := []operation{
+		{op: opPut, key: []byte("key-1"), value: []byte("value")},
+		{op: opDelete, key: []byte("key-2"), value: []byte{}},
+		{op: opPut, key: []byte("key-3"), value: []byte{}},
+	}
+	decoded, err := decodeCommand(encodeCommand(ops))
+	assert.Nil(t, err)
+	assert.Equal(t, ops, decoded)
+
+	_, err = decodeCommand(encodeCommand(ops)[:10])
+	assert.Equal(t, ErrInvalidCommand, err)
+}
@@ -0,0 +1,84 @@
+package cluster
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var ErrInvalidCommand = errors.New("invalid command")
+
+type opType = byte
+
+const (
+	opPut opType = iota + 1
+	opDelete
+)
+
+// operation of command, value is nil for delete
+type operation struct {
+	op    opType
+	key   []byte
+	value []byte
+}
+
+// encodeCommand encode operations applied atomically as:
+// uvarint count | op | uvarint keySize | key | uvarint valueSize | value | ...
+func encodeCommand(ops []operation) []byte {
+	size := binary.MaxVarintLen64
+	for _, op := range ops {
+		size += 1 + 2*binary.MaxVarintLen64 + len(op.key) + len(op.value)
+	}
+
+	buf := make([]byte, size)
+	index := binary.PutUvarint(buf, uint64(len(ops)))
+	for _, op := range ops {
+		buf[index] = op.op
+		index++
+		index += binary.PutUvarint(buf[index:], uint64(len(op.key)))
+		index += copy(buf[index:], op.key)
+		index += binary.PutUvarint(buf[index:], uint64(len(op.value)))
+		index += copy(buf[index:], op.value)
+	}
+	return buf[:index]
+}
+
+func decodeCommand(data []byte) ([]operation, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, ErrInvalidCommand
+	}
+	index := n
+
+	readBytes := func() ([]byte, error) {
+		size, n := binary.Uvarint(data[index:])
+		if n <= 0 || uint64(len(data)-index-n) < size {
+			return nil, ErrInvalidCommand
+		}
+		index += n
+		b := data[index : index+int(size)]
+		index += int(size)
+		return b, nil
+	}
+
+	var ops []operation
+	for i := uint64(0); i < count; i++ {
+		if index >= len(data) {
+			return nil, ErrInvalidCommand
+		}
+		op := operation{op: data[index]}
+		index++
+		if op.op != opPut && op.op != opDelete {
+			return nil, ErrInvalidCommand
+		}
+
+		var err error
+		if op.key, err = readBytes(); err != nil {
+			return nil, err
+		}
+		if op.value, err = readBytes(); err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
@@ -0,0 +1,183 @@
+package cluster
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	bitcask "bitcask-go"
+)
+
+// stateMachine apply committed commands to db, the db is replaced as a whole when restoring snapshot
+type stateMachine struct {
+	mu       *sync.RWMutex
+	db       *bitcask.DB
+	dbConfig bitcask.Config
+}
+
+func (sm *stateMachine) Apply(data []byte) error {
+	ops, err := decodeCommand(data)
+	if err != nil {
+		return err
+	}
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if len(ops) == 1 {
+		return applyOperation(sm.db, ops[0])
+	}
+
+	batch := sm.db.NewWriteBatch(bitcask.WriteBatchConfig{MaxBatchSize: len(ops)})
+	for _, op := range ops {
+		if err := applyOperation(batch, op); err != nil {
+			return err
+		}
+	}
+	return batch.Commit()
+}
+
+// Snapshot stream the db files as tar. Db is backed up while the snapshot is read, so it may include commands
+// applied later, applying them again gives the same state since puts and deletes are idempotent
+func (sm *stateMachine) Snapshot() (io.ReadCloser, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(sm.writeSnapshot(writer))
+	}()
+	return reader, nil
+}
+
+// writeSnapshot backup db into a temporary directory, and pack the files as tar
+func (sm *stateMachine) writeSnapshot(w io.Writer) error {
+	backupDir, err := os.MkdirTemp("", "bitcask-cluster-snapshot")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(backupDir)
+	}()
+
+	sm.mu.RLock()
+	err = sm.db.Backup(backupDir)
+	sm.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tarWriter := tar.NewWriter(w)
+	err = filepath.Walk(backupDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		name, err := filepath.Rel(backupDir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tarWriter.Close()
+}
+
+// Restore close db, replace its directory by the files in snapshot and open it again
+func (sm *stateMachine) Restore(r io.Reader) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if err := sm.db.Close(); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(sm.dbConfig.DirPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(sm.dbConfig.DirPath, os.ModePerm); err != nil {
+		return err
+	}
+
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := restoreFile(sm.dbConfig.DirPath, header, reader); err != nil {
+			return err
+		}
+	}
+
+	db, err := bitcask.OpenDatabase(sm.dbConfig)
+	if err != nil {
+		return err
+	}
+	sm.db = db
+	return nil
+}
+
+func (sm *stateMachine) get(key []byte) ([]byte, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.db.Get(key)
+}
+
+func (sm *stateMachine) close() error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.db.Close()
+}
+
+func restoreFile(dirPath string, header *tar.Header, reader io.Reader) error {
+	path := filepath.Join(dirPath, filepath.FromSlash(header.Name))
+	if !filepath.IsLocal(header.Name) {
+		return ErrInvalidSnapshot
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writer is implemented by DB and WriteBatch
+type writer interface {
+	Put(key, value []byte) error
+	Delete(key []byte) error
+}
+
+func applyOperation(w writer, op operation) error {
+	if op.op == opDelete {
+		return w.Delete(op.key)
+	}
+	return w.Put(op.key, op.value)
+}
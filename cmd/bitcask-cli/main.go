@@ -0,0 +1,413 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"bitcask-go/cmd/internal/cmdutil"
+	"bitcask-go/storage"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+const usage = `usage: bitcask-cli -dir DIR [-index TYPE] [-output text|json] COMMAND [ARGS]
+
+commands:
+  get KEY                 print value of key
+  put KEY VALUE           set value of key
+  delete KEY              delete key
+  scan [-prefix P] [-start S] [-end E] [-reverse] [-limit N] [-keys-only]
+                          print key/value pairs in key order
+  keys [-count]           print all the keys, or the number of keys
+  stats                   print stats of database
+  merge                   merge data files
+  backup DEST             copy data files to directory DEST
+  inspect [-max-value N] FILE
+                          decode data, hint-index, merge-finish or sequence-number file record by record,
+                          -dir is not needed
+`
+
+// cli database shared by commands, and where to print the result
+type cli struct {
+	config bitcask.Config
+	output string
+	stdout io.Writer
+}
+
+func main() {
+	flags := flag.NewFlagSet("bitcask-cli", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		fmt.Fprintln(os.Stderr, "\nflags:")
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", "", "directory of database")
+	indexType := flags.String("index", "btree", "index type of database, "+cmdutil.IndexTypeNames)
+	output := flags.String("output", "text", "output format, text or json")
+	_ = flags.Parse(os.Args[1:])
+
+	if err := run(os.Stdout, *dir, *indexType, *output, flags.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "bitcask-cli:", err)
+		if errors.Is(err, errUsage) {
+			flags.Usage()
+		}
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("invalid usage")
+
+// run command of args and print the result to stdout
+func run(stdout io.Writer, dir, indexType, output string, args []string) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q, expect text or json", output)
+	}
+	// inspect reads a single file without opening database
+	if len(args) > 0 && args[0] == "inspect" {
+		c := &cli{output: output, stdout: stdout}
+		return c.inspect(args[1:])
+	}
+	if dir == "" || len(args) == 0 {
+		return errUsage
+	}
+	indexerType, err := cmdutil.ParseIndexType(indexType)
+	if err != nil {
+		return err
+	}
+	// opening database creates missing directory
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+
+	config := bitcask.DefaultConfig
+	config.DirPath = dir
+	config.IndexerType = indexerType
+	c := &cli{config: config, output: output, stdout: stdout}
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "get":
+		return c.get(args)
+	case "put":
+		return c.put(args)
+	case "delete":
+		return c.delete(args)
+	case "scan":
+		return c.scan(args)
+	case "keys":
+		return c.keys(args)
+	case "stats":
+		return c.stats(args)
+	case "merge":
+		return c.merge(args)
+	case "backup":
+		return c.backup(args)
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, command)
+	}
+}
+
+func (c *cli) get(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: get KEY", errUsage)
+	}
+	return c.withDatabase(true, func(db *bitcask.DB) error {
+		value, err := db.Get([]byte(args[0]))
+		if err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return c.printJSON(map[string]string{"key": args[0], "value": string(value)})
+		}
+		_, err = fmt.Fprintln(c.stdout, string(value))
+		return err
+	})
+}
+
+func (c *cli) put(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w: put KEY VALUE", errUsage)
+	}
+	return c.withDatabase(false, func(db *bitcask.DB) error {
+		if err := db.PutWithOptions([]byte(args[0]), []byte(args[1]), bitcask.WriteOptions{Sync: true}); err != nil {
+			return err
+		}
+		return c.printOK()
+	})
+}
+
+func (c *cli) delete(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: delete KEY", errUsage)
+	}
+	return c.withDatabase(false, func(db *bitcask.DB) error {
+		if err := db.DeleteWithOptions([]byte(args[0]), bitcask.WriteOptions{Sync: true}); err != nil {
+			return err
+		}
+		return c.printOK()
+	})
+}
+
+func (c *cli) scan(args []string) error {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	prefix := flags.String("prefix", "", "only scan keys with prefix")
+	start := flags.String("start", "", "inclusive lower bound of key")
+	end := flags.String("end", "", "exclusive upper bound of key")
+	reverse := flags.Bool("reverse", false, "scan in reverse key order")
+	limit := flags.Int("limit", 0, "max number of keys, 0 means no limit")
+	keysOnly := flags.Bool("keys-only", false, "only print keys")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	iteratorConfig := bitcask.DefaultIteratorConfig
+	iteratorConfig.Prefix = bytesOrNil(*prefix)
+	iteratorConfig.Start = bytesOrNil(*start)
+	iteratorConfig.End = bytesOrNil(*end)
+	iteratorConfig.Reverse = *reverse
+	iteratorConfig.Limit = *limit
+	iteratorConfig.KeyOnly = *keysOnly
+
+	return c.withDatabase(true, func(db *bitcask.DB) error {
+		iter := db.NewIterator(iteratorConfig)
+		defer iter.Close()
+
+		encoder := json.NewEncoder(c.stdout)
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			key := string(iter.Key())
+			var value string
+			if !*keysOnly {
+				v, err := iter.Value()
+				if err != nil {
+					return err
+				}
+				value = string(v)
+			}
+
+			var err error
+			switch {
+			case c.output == "json" && *keysOnly:
+				err = encoder.Encode(map[string]string{"key": key})
+			case c.output == "json":
+				err = encoder.Encode(map[string]string{"key": key, "value": value})
+			case *keysOnly:
+				_, err = fmt.Fprintln(c.stdout, key)
+			default:
+				_, err = fmt.Fprintf(c.stdout, "%s\t%s\n", key, value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *cli) keys(args []string) error {
+	flags := flag.NewFlagSet("keys", flag.ContinueOnError)
+	count := flags.Bool("count", false, "only print the number of keys")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	return c.withDatabase(true, func(db *bitcask.DB) error {
+		keys := db.ListKeys()
+		if *count {
+			if c.output == "json" {
+				return c.printJSON(map[string]int{"count": len(keys)})
+			}
+			_, err := fmt.Fprintln(c.stdout, len(keys))
+			return err
+		}
+
+		if c.output == "json" {
+			names := make([]string, len(keys))
+			for i, key := range keys {
+				names[i] = string(key)
+			}
+			return c.printJSON(names)
+		}
+		for _, key := range keys {
+			if _, err := fmt.Fprintln(c.stdout, string(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *cli) stats(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: stats", errUsage)
+	}
+	return c.withDatabase(true, func(db *bitcask.DB) error {
+		stats, err := db.Stats()
+		if err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return c.printJSON(stats)
+		}
+
+		// fields are printed by json names in text output as well
+		buf, err := json.Marshal(stats)
+		if err != nil {
+			return err
+		}
+		var fields map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(buf))
+		decoder.UseNumber()
+		if err := decoder.Decode(&fields); err != nil {
+			return err
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			// data files are printed as a table below
+			if name != "dataFiles" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := fmt.Fprintf(c.stdout, "%s: %v\n", name, fields[name]); err != nil {
+				return err
+			}
+		}
+
+		if len(stats.DataFiles) == 0 {
+			return nil
+		}
+		writer := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(writer, "fileId\tliveBytes\tdeadBytes\tliveRecords\tdeadRecords\t")
+		for _, file := range stats.DataFiles {
+			fmt.Fprintf(writer, "%d\t%d\t%d\t%d\t%d\t\n", file.FileId, file.LiveBytes, file.DeadBytes, file.LiveRecords, file.DeadRecords)
+		}
+		return writer.Flush()
+	})
+}
+
+func (c *cli) merge(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: merge", errUsage)
+	}
+	return c.withDatabase(false, func(db *bitcask.DB) error {
+		if err := db.Merge(); err != nil {
+			return err
+		}
+		return c.printOK()
+	})
+}
+
+func (c *cli) backup(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: backup DEST", errUsage)
+	}
+	return c.withDatabase(true, func(db *bitcask.DB) error {
+		if err := db.Backup(args[0]); err != nil {
+			return err
+		}
+		return c.printOK()
+	})
+}
+
+func (c *cli) inspect(args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	maxValue := flags.Int("max-value", 32, "max bytes of value to print, 0 means no limit")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: inspect [-max-value N] FILE", errUsage)
+	}
+
+	encoder := json.NewEncoder(c.stdout)
+	var printErr error
+	err := storage.InspectFile(flags.Arg(0), func(record *storage.InspectedRecord) bool {
+		value := record.Value
+		truncated := *maxValue > 0 && len(value) > *maxValue
+		if truncated {
+			value = value[:*maxValue]
+		}
+
+		if c.output == "json" {
+			fields := map[string]any{
+				"offset":   record.Offset,
+				"size":     record.Size,
+				"crcValid": record.CRCValid,
+				"type":     storage.RecordTypeName(record.Type),
+				"seq":      record.SequenceNumber,
+				"key":      string(record.Key),
+				"value":    string(value),
+			}
+			if truncated {
+				fields["valueSize"] = len(record.Value)
+			}
+			if record.Position != nil {
+				fields["position"] = record.Position
+			}
+			printErr = encoder.Encode(fields)
+			return printErr == nil
+		}
+
+		crc := "ok"
+		if !record.CRCValid {
+			crc = "invalid"
+		}
+		valueText := fmt.Sprintf("%q", value)
+		if record.Position != nil {
+			valueText = fmt.Sprintf("fid=%d offset=%d size=%d", record.Position.Fid, record.Position.Offset, record.Position.LogRecordSize)
+		} else if truncated {
+			valueText += fmt.Sprintf("...(%d bytes)", len(record.Value))
+		}
+		_, printErr = fmt.Fprintf(c.stdout, "offset=%d size=%d crc=%s type=%s seq=%d key=%q value=%s\n",
+			record.Offset, record.Size, crc, storage.RecordTypeName(record.Type), record.SequenceNumber, record.Key, valueText)
+		return printErr == nil
+	})
+	if printErr != nil {
+		return printErr
+	}
+	return err
+}
+
+// withDatabase open database for fn, read only database is used by commands not writing
+func (c *cli) withDatabase(readOnly bool, fn func(db *bitcask.DB) error) error {
+	config := c.config
+	config.ReadOnly = readOnly
+	db, err := bitcask.OpenDatabase(config)
+	if err != nil {
+		return err
+	}
+
+	err = fn(db)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (c *cli) printOK() error {
+	if c.output == "json" {
+		return c.printJSON(map[string]bool{"ok": true})
+	}
+	_, err := fmt.Fprintln(c.stdout, "OK")
+	return err
+}
+
+func (c *cli) printJSON(v any) error {
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// bytesOrNil empty flag means no bound
+func bytesOrNil(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	return []byte(s)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDir(t *testing.T) string {
+	dir, _ := os.MkdirTemp("", "bitcask-cli")
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+	return dir
+}
+
+func runCommand(dir, output string, args ...string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := run(stdout, dir, "btree", output, args)
+	return stdout.String(), err
+}
+
+func TestRun_PutGetDelete(t *testing.T) {
+	dir := newTestDir(t)
+
+	out, err := runCommand(dir, "text", "put", "key", "value")
+	assert.Nil(t, err)
+	assert.Equal(t, "OK\n", out)
+
+	out, err = runCommand(dir, "text", "get", "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "value\n", out)
+
+	out, err = runCommand(dir, "text", "delete", "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "OK\n", out)
+
+	_, err = runCommand(dir, "text", "get", "key")
+	assert.ErrorIs(t, err, bitcask.ErrKeyNotFound)
+}
+
+func TestRun_ScanKeys(t *testing.T) {
+	dir := newTestDir(t)
+	for _, key := range []string{"a-1", "a-2", "b-1"} {
+		_, err := runCommand(dir, "text", "put", key, "value-"+key)
+		assert.Nil(t, err)
+	}
+
+	out, err := runCommand(dir, "text", "scan", "-prefix", "a-")
+	assert.Nil(t, err)
+	assert.Equal(t, "a-1\tvalue-a-1\na-2\tvalue-a-2\n", out)
+
+	out, err = runCommand(dir, "text", "scan", "-reverse", "-limit", "2", "-keys-only")
+	assert.Nil(t, err)
+	assert.Equal(t, "b-1\na-2\n", out)
+
+	out, err = runCommand(dir, "text", "scan", "-start", "a-2", "-end", "b-1")
+	assert.Nil(t, err)
+	assert.Equal(t, "a-2\tvalue-a-2\n", out)
+
+	out, err = runCommand(dir, "text", "keys")
+	assert.Nil(t, err)
+	assert.Equal(t, "a-1\na-2\nb-1\n", out)
+
+	out, err = runCommand(dir, "text", "keys", "-count")
+	assert.Nil(t, err)
+	assert.Equal(t, "3\n", out)
+}
+
+func TestRun_Stats(t *testing.T) {
+	dir := newTestDir(t)
+	_, err := runCommand(dir, "text", "put", "key", "value")
+	assert.Nil(t, err)
+
+	out, err := runCommand(dir, "text", "stats")
+	assert.Nil(t, err)
+	assert.Contains(t, out, "keyNumber: 1\n")
+	assert.Contains(t, out, "fileId")
+	assert.NotContains(t, out, "dataFiles")
+}
+
+func TestRun_JSONOutput(t *testing.T) {
+	dir := newTestDir(t)
+
+	out, err := runCommand(dir, "json", "put", "key", "value")
+	assert.Nil(t, err)
+	var ok map[string]bool
+	assert.Nil(t, json.Unmarshal([]byte(out), &ok))
+	assert.Equal(t, map[string]bool{"ok": true}, ok)
+
+	out, err = runCommand(dir, "json", "get", "key")
+	assert.Nil(t, err)
+	var pair map[string]string
+	assert.Nil(t, json.Unmarshal([]byte(out), &pair))
+	assert.Equal(t, map[string]string{"key": "key", "value": "value"}, pair)
+
+	out, err = runCommand(dir, "json", "scan")
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, 1, len(lines))
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &pair))
+	assert.Equal(t, map[string]string{"key": "key", "value": "value"}, pair)
+
+	out, err = runCommand(dir, "json", "keys")
+	assert.Nil(t, err)
+	var keys []string
+	assert.Nil(t, json.Unmarshal([]byte(out), &keys))
+	assert.Equal(t, []string{"key"}, keys)
+
+	out, err = runCommand(dir, "json", "keys", "-count")
+	assert.Nil(t, err)
+	var count map[string]int
+	assert.Nil(t, json.Unmarshal([]byte(out), &count))
+	assert.Equal(t, map[string]int{"count": 1}, count)
+
+	out, err = runCommand(dir, "json", "stats")
+	assert.Nil(t, err)
+	var stats bitcask.Stats
+	assert.Nil(t, json.Unmarshal([]byte(out), &stats))
+	assert.Equal(t, uint(1), stats.KeyNum)
+}
+
+func TestRun_InvalidUsage(t *testing.T) {
+	dir := newTestDir(t)
+
+	_, err := runCommand(dir, "text")
+	assert.ErrorIs(t, err, errUsage)
+	_, err = runCommand("", "text", "get", "key")
+	assert.ErrorIs(t, err, errUsage)
+	_, err = runCommand(dir, "text", "unknown")
+	assert.ErrorIs(t, err, errUsage)
+	_, err = runCommand(dir, "text", "put", "key")
+	assert.ErrorIs(t, err, errUsage)
+	_, err = runCommand(dir, "text", "scan", "-unknown")
+	assert.ErrorIs(t, err, errUsage)
+
+	_, err = runCommand(dir, "yaml", "get", "key")
+	assert.NotNil(t, err)
+	_, err = runCommand(dir+"-missing", "text", "get", "key")
+	assert.True(t, os.IsNotExist(err))
+}
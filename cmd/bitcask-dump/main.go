@@ -0,0 +1,78 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"bitcask-go/cmd/internal/cmdutil"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// bitcask-dump -dir /tmp/data -format json -out dump.jsonl
+func main() {
+	dir := flag.String("dir", "", "directory of database to dump")
+	format := flag.String("format", "json", "format of dump, json or binary")
+	out := flag.String("out", "-", "file to write dump, - for stdout")
+	indexType := flag.String("index", "btree", "index type of database, "+cmdutil.IndexTypeNames)
+	flag.Parse()
+
+	if err := dump(*dir, *format, *out, *indexType); err != nil {
+		fmt.Fprintln(os.Stderr, "bitcask-dump:", err)
+		os.Exit(1)
+	}
+}
+
+func dump(dir, format, out, indexType string) error {
+	if dir == "" {
+		return fmt.Errorf("-dir is required")
+	}
+	// opening database creates missing directory
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	dumpFormat, err := cmdutil.ParseDumpFormat(format)
+	if err != nil {
+		return err
+	}
+	indexerType, err := cmdutil.ParseIndexType(indexType)
+	if err != nil {
+		return err
+	}
+
+	config := bitcask.DefaultConfig
+	config.DirPath = dir
+	config.IndexerType = indexerType
+	config.ReadOnly = true
+	db, err := bitcask.OpenDatabase(config)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	var writer io.Writer = os.Stdout
+	if out != "-" {
+		file, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		writer = file
+	}
+
+	count, err := db.Dump(writer, dumpFormat)
+	if err != nil {
+		return err
+	}
+	if file, ok := writer.(*os.File); ok && file != os.Stdout {
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "dumped %d keys\n", count)
+	return nil
+}
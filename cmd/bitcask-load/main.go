@@ -0,0 +1,80 @@
+package main
+
+import (
+	bitcask "bitcask-go"
+	"bitcask-go/cmd/internal/cmdutil"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// bitcask-load -dir /tmp/new-data -format json -in dump.jsonl
+func main() {
+	dir := flag.String("dir", "", "directory of new database, it must be empty or not exist")
+	format := flag.String("format", "json", "format of dump, json or binary")
+	in := flag.String("in", "-", "file to read dump, - for stdin")
+	indexType := flag.String("index", "btree", "index type of new database, "+cmdutil.IndexTypeNames)
+	dataFileSize := flag.Int64("data-file-size", bitcask.DefaultConfig.DataFileSize, "size of data file in bytes")
+	flag.Parse()
+
+	if err := load(*dir, *format, *in, *indexType, *dataFileSize); err != nil {
+		fmt.Fprintln(os.Stderr, "bitcask-load:", err)
+		os.Exit(1)
+	}
+}
+
+func load(dir, format, in, indexType string, dataFileSize int64) error {
+	if dir == "" {
+		return fmt.Errorf("-dir is required")
+	}
+	dumpFormat, err := cmdutil.ParseDumpFormat(format)
+	if err != nil {
+		return err
+	}
+	indexerType, err := cmdutil.ParseIndexType(indexType)
+	if err != nil {
+		return err
+	}
+
+	// loading into existing database would mix the data
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("directory %s is not empty", dir)
+	}
+
+	var reader io.Reader = os.Stdin
+	if in != "-" {
+		file, err := os.Open(in)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		reader = file
+	}
+
+	config := bitcask.DefaultConfig
+	config.DirPath = dir
+	config.IndexerType = indexerType
+	config.DataFileSize = dataFileSize
+	db, err := bitcask.OpenDatabase(config)
+	if err != nil {
+		return err
+	}
+
+	count, err := db.Load(reader, dumpFormat)
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "loaded %d keys\n", count)
+	return nil
+}
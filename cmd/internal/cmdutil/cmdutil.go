@@ -0,0 +1,40 @@
+package cmdutil
+
+import (
+	bitcask "bitcask-go"
+	"bitcask-go/index"
+	"fmt"
+)
+
+// IndexTypeNames names of index types accepted by -index flag
+const IndexTypeNames = "btree, art, bptree, compact, compact-hash or sharded-hash"
+
+func ParseIndexType(name string) (index.IndexerType, error) {
+	switch name {
+	case "btree":
+		return index.BTreeIndexType, nil
+	case "art":
+		return index.ARTIndexType, nil
+	case "bptree":
+		return index.BPlusTreeIndexType, nil
+	case "compact":
+		return index.CompactIndexType, nil
+	case "compact-hash":
+		return index.CompactHashIndexType, nil
+	case "sharded-hash":
+		return index.ShardedHashIndexType, nil
+	default:
+		return 0, fmt.Errorf("unknown index type %q, expect %s", name, IndexTypeNames)
+	}
+}
+
+func ParseDumpFormat(name string) (bitcask.DumpFormat, error) {
+	switch name {
+	case "json":
+		return bitcask.DumpFormatJSON, nil
+	case "binary":
+		return bitcask.DumpFormatBinary, nil
+	default:
+		return 0, fmt.Errorf("unknown format %q, expect json or binary", name)
+	}
+}
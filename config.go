@@ -1,8 +1,11 @@
 package bitcask_go
 
 import (
+	"bitcask-go/fio"
 	"bitcask-go/index"
+	"io"
 	"os"
+	"time"
 )
 
 type Config struct {
@@ -10,20 +13,78 @@ type Config struct {
 
 	DataFileSize int64 // size of Data file
 
-	SyncWrites bool // To flush storage to disk after each write
-
-	BytesToSync uint
+	SyncPolicy SyncPolicy // when to flush active data file to disk
 
 	IndexerType index.IndexerType // index type to indicate which index to use
 
 	EnableMMapAtStart bool // mmap to boost start time
 
 	MergeRatio float32 // ratio to define in which threshold should start merging
+
+	LazyIndexLoad bool // build index in background while opening, reads of keys not indexed yet block until ready
+
+	MMapInactiveFiles bool // keep inactive data files memory mapped after start, for zero copy reads by GetView
+
+	EnableMMapWrites bool // write active data file through a writable memory mapping instead of file system calls
+
+	InMemory bool // keep all the files in memory without touching disk, DirPath only names the database in process
+
+	MaxOpenFiles int // max number of open inactive data files, others are closed and reopened on demand, 0 means no limit
+
+	ReadOnly bool // reject writes, used by replica which only applies the log shipped from primary
+
+	EnableMetrics bool // record latency histograms of operations, exposed by MetricsHandler
+
+	FileSystem fio.FileSystem // files of database are opened by it, nil means os file system, or a new in memory one freed on close if InMemory
 }
 
 type IteratorConfig struct {
-	reverse bool   // order to iterate
-	prefix  []byte // search key's prefix, default is empty
+	Prefix  []byte // search key's prefix, default is empty
+	Start   []byte // inclusive lower bound of key, default is empty
+	End     []byte // exclusive upper bound of key, default is empty
+	Reverse bool   // order to iterate
+	Limit   int    // max number of keys to iterate, 0 means no limit
+	KeyOnly bool   // only iterate keys, Value always returns nil without reading data file
+}
+
+type CDCFormat = byte
+
+const (
+	// CDCFormatJSON one JSON object per line
+	CDCFormatJSON CDCFormat = iota
+	// CDCFormatBinary length prefixed events with crc, read by ReadChangeEvent
+	CDCFormatBinary
+)
+
+type CDCConfig struct {
+	Format         CDCFormat // encoding of change events
+	Writer         io.Writer // write events to Writer instead of rotating files in DirPath
+	DirPath        string    // directory of event files owned by exporter, also keeps checkpoint if CheckpointPath is empty
+	MaxFileSize    int64     // rotate event file once it's larger than this, 0 means no rotation
+	CheckpointPath string    // file of exported position to resume after restart, no checkpoint if both it and DirPath are empty
+}
+
+type SyncPolicyType = byte
+
+const (
+	// SyncNever leave flushing to operating system
+	SyncNever SyncPolicyType = iota
+	// SyncEveryWrite flush after each write
+	SyncEveryWrite
+	// SyncEveryNBytes flush once Bytes are written since last flush
+	SyncEveryNBytes
+	// SyncEveryInterval flush in background every Interval if there are writes
+	SyncEveryInterval
+)
+
+type SyncPolicy struct {
+	Type     SyncPolicyType
+	Bytes    uint          // used by SyncEveryNBytes
+	Interval time.Duration // used by SyncEveryInterval
+}
+
+type WriteOptions struct {
+	Sync bool // flush to disk before return regardless of sync policy
 }
 
 type WriteBatchConfig struct {
@@ -34,15 +95,35 @@ type WriteBatchConfig struct {
 var DefaultConfig = Config{
 	DirPath:           os.TempDir(),
 	DataFileSize:      64 * 1024 * 1024, // 64MB
-	SyncWrites:        false,
+	SyncPolicy:        SyncPolicy{Type: SyncNever},
 	IndexerType:       index.BTreeIndexType,
 	EnableMMapAtStart: true,
 	MergeRatio:        0.5,
+	MMapInactiveFiles: false,
+	EnableMMapWrites:  false,
+	InMemory:          false,
+	MaxOpenFiles:      0,
+	ReadOnly:          false,
+	EnableMetrics:     false,
+	FileSystem:        nil,
 }
 
 var DefaultIteratorConfig = IteratorConfig{
-	reverse: false,
-	prefix:  nil,
+	Prefix:  nil,
+	Start:   nil,
+	End:     nil,
+	Reverse: false,
+	Limit:   0,
+	KeyOnly: false,
+}
+
+var DefaultCDCConfig = CDCConfig{
+	Format:      CDCFormatJSON,
+	MaxFileSize: 64 * 1024 * 1024, // 64MB
+}
+
+var DefaultWriteOptions = WriteOptions{
+	Sync: false,
 }
 
 var DefaultWriteBatchConfig = WriteBatchConfig{
@@ -4,6 +4,8 @@ var transactionFinishKey = []byte("transaction_finish_key")
 
 var sequenceNumberKey = []byte("sequence_number_key")
 
+var indexSnapshotKey = []byte("index_snapshot_key")
+
 const nonTransactionSequenceNumber uint64 = 0
 
 const initialDataFileId uint32 = 1
@@ -0,0 +1,184 @@
+package bitcask_go
+
+import (
+	"bitcask-go/fio"
+	"bitcask-go/index"
+	"bitcask-go/utils"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+// crashDatabase simulate process crash, unsynced bytes are dropped and database is abandoned without closing
+func crashDatabase(t *testing.T, db *DB, injector *fio.FaultInjector) {
+	assert.Nil(t, injector.Crash())
+	db.stopBackgroundSync()
+
+	if db.fileLock != nil {
+		_ = db.fileLock.Unlock()
+	}
+	if db.config.IndexerType == index.BPlusTreeIndexType {
+		_ = db.index.Close()
+	}
+	if db.activeFile != nil {
+		_ = db.activeFile.Close()
+	}
+	for _, dataFile := range db.inactiveFiles {
+		_ = dataFile.Close()
+	}
+
+	injector.Reset()
+}
+
+// checkDurableValues check all the acknowledged values are in database
+func checkDurableValues(t *testing.T, db *DB, values map[string][]byte) {
+	for key, value := range values {
+		val, err := db.Get([]byte(key))
+		if value == nil {
+			assert.Equal(t, ErrKeyNotFound, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, value, val)
+	}
+}
+
+func newCrashTestConfig(t *testing.T) Config {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_crash")
+	configs.DirPath = dir
+	configs.DataFileSize = 4 * 1024
+	configs.SyncPolicy = SyncPolicy{Type: SyncEveryWrite}
+	configs.MergeRatio = 0
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+		_ = os.RemoveAll(dir + mergeDirNameSuffix)
+	})
+	return configs
+}
+
+func TestCrash_Put(t *testing.T) {
+	for budget := int64(0); budget < 6*1024; budget += 331 {
+		configs := newCrashTestConfig(t)
+		injector := fio.NewFaultInjector(configs.DirPath)
+		configs.FileSystem = injector.FileSystem(fio.NewFileSystem(fio.StandardFileIOType))
+
+		database, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+
+		// put until write fails
+		values := make(map[string][]byte)
+		injector.FailWriteAfter(budget)
+		var i int
+		for ; ; i++ {
+			value := utils.GenerateRandomValue(32)
+			if err := database.Put(utils.GenerateTestKey(i), value); err != nil {
+				break
+			}
+			values[string(utils.GenerateTestKey(i))] = value
+		}
+
+		// database keeps working after the failed write
+		injector.Reset()
+		for j := i; j < i+10; j++ {
+			value := utils.GenerateRandomValue(32)
+			err = database.Put(utils.GenerateTestKey(j), value)
+			assert.Nil(t, err)
+			values[string(utils.GenerateTestKey(j))] = value
+		}
+
+		crashDatabase(t, database, injector)
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+		checkDurableValues(t, database, values)
+
+		assert.Nil(t, database.Close())
+	}
+}
+
+func TestCrash_WriteBatchCommit(t *testing.T) {
+	for budget := int64(0); budget < 1024; budget += 97 {
+		configs := newCrashTestConfig(t)
+		injector := fio.NewFaultInjector(configs.DirPath)
+		configs.FileSystem = injector.FileSystem(fio.NewFileSystem(fio.StandardFileIOType))
+
+		database, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+		for i := 0; i < 10; i++ {
+			err = database.Put(utils.GenerateTestKey(i), []byte("old"))
+			assert.Nil(t, err)
+		}
+
+		batch := database.NewWriteBatch(DefaultWriteBatchConfig)
+		for i := 0; i < 10; i++ {
+			assert.Nil(t, batch.Put(utils.GenerateTestKey(i), []byte("new")))
+		}
+		assert.Nil(t, batch.Put(utils.GenerateTestKey(10), []byte("new")))
+
+		injector.FailWriteAfter(budget)
+		commitErr := batch.Commit()
+
+		crashDatabase(t, database, injector)
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+
+		// batch is applied as a whole or not at all
+		expected := []byte("old")
+		val, err := database.Get(utils.GenerateTestKey(10))
+		if err == nil {
+			expected = []byte("new")
+			assert.Equal(t, expected, val)
+		} else {
+			assert.Equal(t, ErrKeyNotFound, err)
+			assert.NotNil(t, commitErr)
+		}
+		for i := 0; i < 10; i++ {
+			val, err := database.Get(utils.GenerateTestKey(i))
+			assert.Nil(t, err)
+			assert.Equal(t, expected, val)
+		}
+
+		assert.Nil(t, database.Close())
+	}
+}
+
+func TestCrash_Merge(t *testing.T) {
+	// negative budget means merge succeeds and crashes afterwards
+	for budget := int64(-1); budget < 8*1024; budget += 1021 {
+		configs := newCrashTestConfig(t)
+		injector := fio.NewFaultInjector(configs.DirPath)
+		configs.FileSystem = injector.FileSystem(fio.NewFileSystem(fio.StandardFileIOType))
+
+		database, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+
+		values := make(map[string][]byte)
+		for i := 0; i < 300; i++ {
+			value := utils.GenerateRandomValue(32)
+			err = database.Put(utils.GenerateTestKey(i%100), value)
+			assert.Nil(t, err)
+			values[string(utils.GenerateTestKey(i%100))] = value
+		}
+		for i := 0; i < 20; i++ {
+			err = database.Delete(utils.GenerateTestKey(i))
+			assert.Nil(t, err)
+			values[string(utils.GenerateTestKey(i))] = nil
+		}
+
+		injector.FailWriteAfter(budget)
+		_ = database.Merge()
+
+		crashDatabase(t, database, injector)
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+		checkDurableValues(t, database, values)
+
+		// reopen again after the merged files are installed
+		assert.Nil(t, database.Close())
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+		checkDurableValues(t, database, values)
+
+		assert.Nil(t, database.Close())
+	}
+}
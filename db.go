@@ -15,31 +15,68 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type DB struct {
 	config                  Config
-	mu                      *sync.RWMutex
+	mu                      *sync.RWMutex                // guard data file set, held exclusively while rotating or closing files
+	appendMu                *sync.Mutex                  // serialize appending to active file
+	keyLocks                *keyLocks                    // order appending and index update of the same key
 	activeFile              *storage.DataFile            // active file to write storage
 	inactiveFiles           map[uint32]*storage.DataFile // inactive file to read storage only, <fid, *file>
 	index                   index.Indexer
-	fileIds                 []int  // only use for loading index
-	sequenceNumber          uint64 // transaction number, increment by 1
-	isMerging               bool   // use for merging files
+	fileIds                 []int       // only use for loading index
+	sequenceNumber          uint64      // transaction number, increment by 1
+	isMerging               atomic.Bool // use for merging files
 	sequenceNumberFileExist bool
-	fileLock                *flock.Flock
-	totalBytesWritten       uint
-	isOpen                  bool
-	isInitial               bool  // indicate if Db was used before loading
-	reclaimSize             int64 // total size could be reclaimed for merging
+	fileLock                *flock.Flock   // nil for in memory database
+	fs                      fio.FileSystem // file system of data directory
+	fileCache               *dataFileCache // bound the open inactive data files, nil if there is no limit
+	totalBytesWritten       uint           // bytes written to active file since last sync
+	syncStopCh              chan struct{}  // stop background sync of SyncEveryInterval
+	syncWg                  *sync.WaitGroup
+	isOpen                  atomic.Bool
+	isInitial               bool                  // indicate if Db was used before loading
+	reclaimSize             atomic.Int64          // total size could be reclaimed for merging
+	indexSnapshotPos        *storage.LogRecordPos // high-water mark of loaded index snapshot, replay data files after it
+	indexReady              atomic.Bool           // index is fully loaded
+	indexReadyCh            chan struct{}         // closed once index is fully loaded
+	indexLoadErr            error                 // error of loading index in background
+	indexLoadEndPos         *storage.LogRecordPos // end of active file while opening, records after it are pended for lazy loading
+	pendingMu               *sync.Mutex
+	pendingIndexRecords     []*storage.LogRecordPositionPair          // index updates written while index is loading
+	pendingIndexKeys        map[string]*storage.LogRecordPositionPair // latest pending index update of each key
+	appendedCh              chan struct{}                             // closed and replaced after each append to wake up replication, nil if not replicated
+	replication             replicationRole                           // primary or replica side of replication, guarded by mu
+	cdc                     *CDCExporter                              // change data capture exporter, guarded by mu
+	stats                   *statsCollector                           // operation counters and space usage of data files
+	metrics                 *metrics                                  // latency of operations, nil if metrics is disabled
 }
 
 // Stats Database meta stats
 type Stats struct {
-	KeyNum                 uint  `json:"keyNumber"`      // number of valid key
-	DataFileNum            uint  `json:"dataFileNumber"` // number of valid data files
-	ReclaimableSizeInBytes int64 `json:"reclaimSize"`    // size of reclaimable space on disk, only count the data file size
-	TotalFileSizeInBytes   int64 `json:"diskSize"`       // total size of files on disk
+	KeyNum                 uint            `json:"keyNumber"`        // number of valid key
+	DataFileNum            uint            `json:"dataFileNumber"`   // number of valid data files
+	ReclaimableSizeInBytes int64           `json:"reclaimSize"`      // size of reclaimable space on disk, only count the data file size
+	TotalFileSizeInBytes   int64           `json:"diskSize"`         // total size of data files, hint file and index file on disk
+	IndexReady             bool            `json:"indexReady"`       // index is fully loaded
+	IndexMemoryInBytes     int64           `json:"indexMemory"`      // estimated memory of index, only reported by compact index
+	IndexBytesPerKey       float64         `json:"indexBytesPerKey"` // estimated memory of index per key
+	ReplicationLagInBytes  int64           `json:"replicationLag"`   // bytes of log not applied by replica, max of all the replicas on primary
+	DataFiles              []DataFileStats `json:"dataFiles"`        // space usage of each data file ordered by file id
+
+	// counters since database is opened
+	PutCount         uint64    `json:"puts"`          // puts, including streamed ones
+	GetCount         uint64    `json:"gets"`          // gets, including views, readers and keys of multi get
+	DeleteCount      uint64    `json:"deletes"`       // deletes, a range deletion is counted once
+	BatchCommitCount uint64    `json:"batchCommits"`  // write batches committed
+	MergeCount       uint64    `json:"merges"`        // merges finished
+	SyncCount        uint64    `json:"syncs"`         // active file flushed to disk
+	BytesRead        uint64    `json:"bytesRead"`     // bytes of log records read for values
+	BytesWritten     uint64    `json:"bytesWritten"`  // bytes of log records appended
+	LastMergeTime    time.Time `json:"lastMergeTime"` // when last merge finished, zero if never merged
 }
 
 func OpenDatabase(config Config) (*DB, error) {
@@ -47,82 +84,145 @@ func OpenDatabase(config Config) (*DB, error) {
 		return nil, err
 	}
 
+	fs := config.FileSystem
+	if fs == nil {
+		fs = fio.NewFileSystem(fio.StandardFileIOType)
+		// every in memory database has its own files, they are freed on close
+		if config.InMemory {
+			fs = fio.NewMemoryFileSystem()
+		}
+	}
+
 	// check if dir path exist
-	if _, err := os.Stat(config.DirPath); os.IsNotExist(err) {
+	if _, err := fs.Stat(config.DirPath); os.IsNotExist(err) {
 		// create dir path for user
-		if err = os.Mkdir(config.DirPath, os.ModePerm); err != nil {
+		if err = fs.MkdirAll(config.DirPath, os.ModePerm); err != nil {
 			return nil, err
 		}
 	}
 
-	fileLock, err := acquireFileLock(config)
-	if err != nil {
-		return nil, err
+	// in memory database is not shared with other processes
+	var fileLock *flock.Flock
+	if !config.InMemory {
+		var err error
+		if fileLock, err = acquireFileLock(config); err != nil {
+			return nil, err
+		}
 	}
 
 	// init db instance
 	db := &DB{
 		config:        config,
 		mu:            new(sync.RWMutex),
+		appendMu:      new(sync.Mutex),
+		keyLocks:      newKeyLocks(keyLockStripeNum),
 		inactiveFiles: make(map[uint32]*storage.DataFile),
-		index:         index.NewIndexer(config.IndexerType, config.DirPath, config.SyncWrites),
 		fileLock:      fileLock,
+		fs:            fs,
+		indexReadyCh:  make(chan struct{}),
+		pendingMu:     new(sync.Mutex),
+		syncStopCh:    make(chan struct{}),
+		syncWg:        new(sync.WaitGroup),
+		stats:         newStatsCollector(),
+	}
+	if config.EnableMetrics {
+		db.metrics = new(metrics)
 	}
+	db.index = db.newIndexer()
 
 	// load merge file
 	if err := db.loadMergeFile(); err != nil {
 		return nil, err
 	}
+	db.loadLastMergeTime()
 
 	// load storage file
 	if err := db.loadDataFiles(); err != nil {
 		return nil, err
 	}
 
-	if db.config.IndexerType == index.BPlusTreeIndexType {
-		if err := db.loadSequenceNumberFile(); err != nil {
+	if db.config.LazyIndexLoad {
+		// build index in background, db is available for writes immediately
+		if err := db.startLazyIndexLoad(); err != nil {
 			return nil, err
 		}
-		// update write offset for bplus tree
-		if db.activeFile != nil {
-			size, err := db.activeFile.IOManager.Size()
-			if err != nil {
-				return nil, err
-			}
-			db.activeFile.WriteOffset = size
-		}
 	} else {
-		// load hint file
-		if err := db.loadHintFile(); err != nil {
+		if err := db.loadIndex(db.dataFilesForLoading()); err != nil {
 			return nil, err
 		}
-	}
 
-	// load index for log records
-	if err := db.loadIndexFromDataFiles(); err != nil {
-		return nil, err
-	}
-
-	// finish loading, set back io type
-	if err := db.setDateFileIOType(fio.StandardFileIOType); err != nil {
-		return nil, err
+		// finish loading, set back io type
+		if err := db.setDateFileIOType(db.activeFileIOType(), db.inactiveFileIOType()); err != nil {
+			return nil, err
+		}
+		// drop the pre-sized tail of active file left by crash, it's after the last record
+		if err := db.truncateActiveDataFile(); err != nil {
+			return nil, err
+		}
+		db.finishIndexLoading(nil)
 	}
 
 	// set db state
-	db.isOpen = true
+	db.isOpen.Store(true)
 	if db.activeFile == nil {
 		db.isInitial = true
 	}
 
+	db.startBackgroundSync()
+
 	return db, nil
 }
 
+// loadIndex load sequence number, index snapshot or hint file, and then replay the log records of data files
+func (db *DB) loadIndex(dataFiles map[uint32]*storage.DataFile) error {
+	if db.config.IndexerType == index.BPlusTreeIndexType {
+		if err := db.loadSequenceNumberFile(); err != nil {
+			return err
+		}
+		// update write offset for bplus tree
+		if db.activeFile != nil && !db.config.LazyIndexLoad {
+			size, err := db.activeFile.IOManager.Size()
+			if err != nil {
+				return err
+			}
+			db.activeFile.WriteOffset = size
+		}
+	} else {
+		// load index snapshot from last clean close, otherwise load hint file
+		loaded, err := db.loadIndexSnapshot(dataFiles)
+		if err != nil {
+			return err
+		}
+		if !loaded {
+			if err := db.loadHintFile(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// load index for log records
+	return db.loadIndexFromDataFiles(dataFiles)
+}
+
 // Put To write key/value storage, key could not be empty
 func (db *DB) Put(key []byte, value []byte) error {
-	if !db.isOpen {
+	return db.PutWithOptions(key, value, DefaultWriteOptions)
+}
+
+// PutWithOptions put key value, sync option flushes active file before return
+func (db *DB) PutWithOptions(key []byte, value []byte, opts WriteOptions) error {
+	if db.metrics != nil {
+		defer db.metrics.observe(metricsPut, time.Now())
+	}
+
+	if !db.isOpen.Load() {
 		return ErrDBClosed
 	}
 
+	if db.config.ReadOnly {
+		return ErrReadOnlyDatabase
+	}
+
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
@@ -135,17 +235,26 @@ func (db *DB) Put(key []byte, value []byte) error {
 		SequenceNumber: nonTransactionSequenceNumber,
 	}
 
+	unlock := db.keyLocks.lock(key)
+	defer unlock()
+
 	// 1. append log record on disk if got inactive file
-	pos, err := db.appendLogRecord(logRecord)
+	pos, err := db.appendLogRecordWithLock(logRecord, opts)
 	if err != nil {
 		return err
 	}
+	db.stats.puts.Add(1)
 
-	// 2. update index
-	oldPos := db.index.Put(key, pos)
-
+	// 2. update index, it's pended while index is loading in background
+	if db.pendIndexUpdate(logRecord, pos) {
+		return nil
+	}
+	oldPos, err := index.CheckedPut(db.index, key, pos)
+	if err != nil {
+		return err
+	}
 	if oldPos != nil {
-		db.reclaimSize += int64(oldPos.LogRecordSize)
+		db.reclaim(oldPos)
 	}
 
 	return nil
@@ -153,7 +262,11 @@ func (db *DB) Put(key []byte, value []byte) error {
 
 // Get to get storage from key
 func (db *DB) Get(key []byte) ([]byte, error) {
-	if !db.isOpen {
+	if db.metrics != nil {
+		defer db.metrics.observe(metricsGet, time.Now())
+	}
+
+	if !db.isOpen.Load() {
 		return nil, ErrDBClosed
 	}
 
@@ -161,20 +274,51 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 		return nil, ErrKeyIsEmpty
 	}
 
-	logRecordPos := db.index.Get(key)
+	logRecordPos, err := db.getIndexPosition(key)
+	if err != nil {
+		return nil, err
+	}
 	if logRecordPos == nil {
 		return nil, ErrKeyNotFound
 	}
 
-	return db.getValueByLogPosition(logRecordPos)
+	value, err := db.getValueByLogPosition(logRecordPos)
+	if err != nil {
+		return nil, err
+	}
+	db.stats.gets.Add(1)
+	return value, nil
 }
 
 func (db *DB) Delete(key []byte) error {
+	return db.DeleteWithOptions(key, DefaultWriteOptions)
+}
+
+// DeleteWithOptions delete key, sync option flushes active file before return
+func (db *DB) DeleteWithOptions(key []byte, opts WriteOptions) error {
+	if db.metrics != nil {
+		defer db.metrics.observe(metricsDelete, time.Now())
+	}
+
+	if !db.isOpen.Load() {
+		return ErrDBClosed
+	}
+
+	if db.config.ReadOnly {
+		return ErrReadOnlyDatabase
+	}
+
 	if len(key) == 0 {
 		return ErrKeyIsEmpty
 	}
 
-	logRecordPos := db.index.Get(key)
+	unlock := db.keyLocks.lock(key)
+	defer unlock()
+
+	logRecordPos, err := db.getIndexPosition(key)
+	if err != nil {
+		return err
+	}
 	if logRecordPos == nil {
 		return ErrKeyNotFound
 	}
@@ -186,26 +330,34 @@ func (db *DB) Delete(key []byte) error {
 	}
 
 	// write to storage file
-	pos, err := db.appendLogRecord(logRecord)
+	pos, err := db.appendLogRecordWithLock(logRecord, opts)
 	if err != nil {
 		return err
 	}
+	db.stats.deletes.Add(1)
 
-	// delete key in index
-	oldPos, ok := db.index.Delete(logRecord.Key)
-
+	// delete key in index, it's pended while index is loading in background
+	if db.pendIndexUpdate(logRecord, pos) {
+		return nil
+	}
+	oldPos, ok, err := index.CheckedDelete(db.index, logRecord.Key)
+	if err != nil {
+		return err
+	}
 	if !ok {
 		return ErrIndexDeleteFailed
 	}
 	if oldPos != nil {
-		db.reclaimSize += int64(oldPos.LogRecordSize)
+		db.reclaim(oldPos)
 	}
-	db.reclaimSize += int64(pos.LogRecordSize)
+	db.reclaim(pos)
 
 	return nil
 }
 
 func (db *DB) ListKeys() [][]byte {
+	_ = db.waitIndexReady()
+
 	keys := make([][]byte, db.index.Size())
 	iter := db.index.Iterator(false)
 	defer iter.Close()
@@ -219,10 +371,14 @@ func (db *DB) ListKeys() [][]byte {
 }
 
 func (db *DB) Fold(fn func(k []byte, v []byte) bool) error {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	if err := db.waitIndexReady(); err != nil {
+		return err
+	}
 
-	iter := db.index.Iterator(false)
+	iter, err := index.CheckedIterator(db.index, false)
+	if err != nil {
+		return err
+	}
 	defer iter.Close()
 	for iter.Rewind(); iter.Valid(); iter.Next() {
 		value, err := db.getValueByLogPosition(iter.Value())
@@ -239,10 +395,18 @@ func (db *DB) Fold(fn func(k []byte, v []byte) bool) error {
 
 // Close active and inactive files
 func (db *DB) Close() error {
+	// background loading is still reading data files
+	_ = db.waitIndexReady()
+	db.stopBackgroundSync()
+	db.stopReplication()
+	db.stopCDC()
+
 	// To release file lock in any condition and release bplus tree lock
 	defer func() {
-		if err := db.fileLock.Unlock(); err != nil {
-			panic(err)
+		if db.fileLock != nil {
+			if err := db.fileLock.Unlock(); err != nil {
+				panic(err)
+			}
 		}
 
 		if db.config.IndexerType == index.BPlusTreeIndexType {
@@ -250,28 +414,54 @@ func (db *DB) Close() error {
 				panic(err)
 			}
 		}
+
+		// files of ephemeral database are dropped, file system given by caller keeps them for reopening
+		if db.config.InMemory && db.config.FileSystem == nil {
+			_ = db.fs.RemoveAll(db.config.DirPath)
+		}
 	}()
 
-	db.isOpen = false
+	db.isOpen.Store(false)
+
+	// wait for the writes in progress to update index, so index snapshot covers all the records before its high-water mark,
+	// writes after it find database closed under appendMu
+	unlockKeys := db.keyLocks.lockAll()
+	defer unlockKeys()
+
+	// wait for the appends in progress, data file set can't be changed without appendMu
+	db.appendMu.Lock()
+	defer db.appendMu.Unlock()
+
 	if db.activeFile == nil {
 		return nil
 	}
 
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
 	if err := db.writeSequenceNumber(); err != nil {
 		return err
 	}
 
+	// index snapshot may read keys from data files, so readers are not blocked yet
+	if err := db.writeIndexSnapshot(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	if err := db.activeFile.Close(); err != nil {
 		return err
 	}
 
-	for _, inactiveFile := range db.inactiveFiles {
-		if err := inactiveFile.Close(); err != nil {
+	if db.fileCache != nil {
+		if err := db.fileCache.closeAll(); err != nil {
 			return err
 		}
+	} else {
+		for _, inactiveFile := range db.inactiveFiles {
+			if err := inactiveFile.Close(); err != nil {
+				return err
+			}
+		}
 	}
 
 	db.activeFile = nil
@@ -282,94 +472,148 @@ func (db *DB) Close() error {
 
 // Sync active file for data persistence, ensure the data is flushed to disk
 func (db *DB) Sync() error {
+	db.appendMu.Lock()
+	defer db.appendMu.Unlock()
+
 	if db.activeFile == nil {
 		return nil
 	}
 
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	if err := db.activeFile.Sync(); err != nil {
+	if err := db.syncDataFile(db.activeFile); err != nil {
 		return err
 	}
+	db.totalBytesWritten = 0
 
 	return nil
 }
 
+// Stats stats of database, they're maintained as data is written, so it's cheap to call frequently
 func (db *DB) Stats() (Stats, error) {
-	var fileNum int
-	fileNum += len(db.inactiveFiles)
+	db.mu.RLock()
+	fileNum := len(db.inactiveFiles)
 	if db.activeFile != nil {
 		fileNum++
 	}
+	replication := db.replication
+	db.mu.RUnlock()
 
-	size, err := utils.DirSize(db.config.DirPath)
-	if err != nil {
-		return Stats{}, err
-	}
+	dataFiles, dataFileSize := db.stats.dataFileStats()
 
-	return Stats{
+	stats := Stats{
 		KeyNum:                 uint(db.index.Size()),
 		DataFileNum:            uint(fileNum),
-		ReclaimableSizeInBytes: db.reclaimSize,
-		TotalFileSizeInBytes:   size,
-	}, nil
+		ReclaimableSizeInBytes: db.reclaimSize.Load(),
+		TotalFileSizeInBytes:   dataFileSize + db.auxiliaryFileSize(),
+		IndexReady:             db.Ready(),
+		DataFiles:              dataFiles,
+		PutCount:               db.stats.puts.Load(),
+		GetCount:               db.stats.gets.Load(),
+		DeleteCount:            db.stats.deletes.Load(),
+		BatchCommitCount:       db.stats.batchCommits.Load(),
+		MergeCount:             db.stats.merges.Load(),
+		SyncCount:              db.stats.syncs.Load(),
+		BytesRead:              db.stats.bytesRead.Load(),
+		BytesWritten:           db.stats.bytesWritten.Load(),
+		LastMergeTime:          db.stats.lastMerge(),
+	}
+	if replication != nil {
+		stats.ReplicationLagInBytes = replication.lagInBytes()
+	}
+	if reporter, ok := db.index.(index.MemoryReporter); ok {
+		stats.IndexMemoryInBytes = reporter.MemoryUsage()
+		if stats.KeyNum > 0 {
+			stats.IndexBytesPerKey = float64(stats.IndexMemoryInBytes) / float64(stats.KeyNum)
+		}
+	}
+
+	return stats, nil
 }
 
 func (db *DB) Backup(path string) error {
+	if err := db.waitIndexReady(); err != nil {
+		return err
+	}
+
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
+	if db.config.InMemory {
+		return db.copyMemoryDir(path)
+	}
 	return utils.CopyDirWithFiles(db.config.DirPath, path, []string{lockFileName})
 }
 
-func (db *DB) appendLogRecordWithLock(logRecord *storage.LogRecord) (*storage.LogRecordPos, error) {
+// appendLogRecordWithLock append log record and sync it if opts requires
+func (db *DB) appendLogRecordWithLock(logRecord *storage.LogRecord, opts WriteOptions) (*storage.LogRecordPos, error) {
 	// lock the properties like writeOffset for active file
-	db.mu.Lock()
-	defer db.mu.Unlock()
+	db.appendMu.Lock()
+	defer db.appendMu.Unlock()
 
-	return db.appendLogRecord(logRecord)
+	pos, err := db.appendLogRecord(logRecord)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Sync {
+		if err := db.syncActiveFile(); err != nil {
+			return nil, err
+		}
+	}
+	return pos, nil
 }
 
+// appendLogRecord append log record to active file, appendMu must be held
 func (db *DB) appendLogRecord(logRecord *storage.LogRecord) (*storage.LogRecordPos, error) {
+	// encode log record
+	encodeLogRecord, size := storage.EncodeLogRecord(logRecord)
+	return db.appendRawLogRecord(size, func(dataFile *storage.DataFile) error {
+		return dataFile.Write(encodeLogRecord)
+	})
+}
+
+// appendRawLogRecord append size bytes of encoded log record to active file by write, appendMu must be held
+func (db *DB) appendRawLogRecord(size int64, write func(dataFile *storage.DataFile) error) (*storage.LogRecordPos, error) {
+	// database may be closed after the write checked it, files are closed once Close holds appendMu
+	if !db.isOpen.Load() {
+		return nil, ErrDBClosed
+	}
+
 	// 1. set active file
 	// check if active file exist, otherwise initialize it
 	if db.activeFile == nil {
-		if err := db.setActiveDataFile(); err != nil {
+		if err := db.rotateActiveDataFile(false); err != nil {
 			return nil, err
 		}
 	}
 
 	// 2. write log record
-	// encode log record
-	encodeLogRecord, size := storage.EncodeLogRecord(logRecord)
 	// check size if beyond limit, then flush to disk
 	if db.activeFile.WriteOffset+size > db.config.DataFileSize {
-		if err := db.activeFile.Sync(); err != nil {
+		if err := db.syncDataFile(db.activeFile); err != nil {
 			return nil, err
 		}
 
-		// put current active file to inactive
-		db.inactiveFiles[db.activeFile.FileId] = db.activeFile
-
-		// open a new active file
-		if err := db.setActiveDataFile(); err != nil {
+		// put current active file to inactive and open a new one
+		if err := db.rotateActiveDataFile(true); err != nil {
 			return nil, err
 		}
 	}
 
 	writeOffset := db.activeFile.WriteOffset
-	if err := db.activeFile.Write(encodeLogRecord); err != nil {
+	if err := write(db.activeFile); err != nil {
+		// drop the partial record, otherwise the records appended after it can't be loaded
+		_ = db.activeFile.Truncate(writeOffset)
 		return nil, err
 	}
 
 	db.totalBytesWritten += uint(size)
-	// check if you need to flush to db based on configuration
-	if db.config.SyncWrites || (db.config.BytesToSync > 0 && db.config.BytesToSync < db.totalBytesWritten) {
-		if err := db.activeFile.Sync(); err != nil {
+	db.stats.addRecord(db.activeFile.FileId, size)
+	db.stats.bytesWritten.Add(uint64(size))
+	db.notifyAppended()
+	// check if you need to flush to db based on sync policy
+	if db.needSyncByPolicy() {
+		if err := db.syncActiveFile(); err != nil {
 			return nil, err
 		}
-		db.totalBytesWritten = 0
 	}
 
 	pos := &storage.LogRecordPos{
@@ -381,6 +625,34 @@ func (db *DB) appendLogRecord(logRecord *storage.LogRecord) (*storage.LogRecordP
 
 }
 
+// rotateActiveDataFile open a new active file, the current one is archived if archive is true.
+// Readers are blocked while the data file set is changed
+func (db *DB) rotateActiveDataFile(archive bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if archive {
+		if err := db.archiveActiveDataFile(); err != nil {
+			return err
+		}
+	}
+	return db.setActiveDataFile()
+}
+
+// archiveActiveDataFile put active file to inactive files, it's not written any more
+func (db *DB) archiveActiveDataFile() error {
+	if db.activeFileIOType() != db.inactiveFileIOType() {
+		if err := db.activeFile.SetIOType(db.fs, db.config.DirPath, db.inactiveFileIOType()); err != nil {
+			return err
+		}
+	}
+	db.inactiveFiles[db.activeFile.FileId] = db.activeFile
+	if db.fileCache != nil {
+		db.fileCache.add(db.activeFile)
+	}
+	return nil
+}
+
 // set current storage file, must set mutex lock
 func (db *DB) setActiveDataFile() error {
 	var initialFileId uint32 = initialDataFileId
@@ -390,7 +662,7 @@ func (db *DB) setActiveDataFile() error {
 	}
 
 	// open a new active file
-	dataFile, err := storage.OpenDataFile(db.config.DirPath, initialFileId, fio.StandardFileIOType)
+	dataFile, err := storage.OpenDataFile(db.fs, db.config.DirPath, initialFileId, db.activeFileIOType())
 	if err != nil {
 		return err
 	}
@@ -400,7 +672,7 @@ func (db *DB) setActiveDataFile() error {
 
 // open data files
 func (db *DB) loadDataFiles() error {
-	dirEntries, err := os.ReadDir(db.config.DirPath)
+	dirEntries, err := db.fs.ReadDir(db.config.DirPath)
 	if err != nil {
 		return err
 	}
@@ -426,8 +698,20 @@ func (db *DB) loadDataFiles() error {
 	if !db.config.EnableMMapAtStart {
 		ioType = fio.StandardFileIOType
 	}
+	if db.config.InMemory {
+		ioType = fio.MemoryIOType
+	}
+	if db.config.MaxOpenFiles > 0 {
+		db.fileCache = newDataFileCache(db.fs, db.config.DirPath, db.config.MaxOpenFiles, ioType)
+	}
 	for i, fid := range fileIds {
-		dataFile, err := storage.OpenDataFile(db.config.DirPath, uint32(fid), ioType)
+		// inactive files are opened on demand if the number of open files is limited
+		if db.fileCache != nil && i < len(fileIds)-1 {
+			db.inactiveFiles[uint32(fid)] = &storage.DataFile{FileId: uint32(fid)}
+			continue
+		}
+
+		dataFile, err := storage.OpenDataFile(db.fs, db.config.DirPath, uint32(fid), ioType)
 		if err != nil {
 			return err
 		}
@@ -447,7 +731,7 @@ func (db *DB) loadDataFiles() error {
 
 // traverse all the log records and put the log position in index
 // also get current sequence number and write offset for active file
-func (db *DB) loadIndexFromDataFiles() error {
+func (db *DB) loadIndexFromDataFiles(dataFiles map[uint32]*storage.DataFile) error {
 	// database is empty
 	if len(db.fileIds) == 0 {
 		return nil
@@ -455,14 +739,14 @@ func (db *DB) loadIndexFromDataFiles() error {
 
 	// store the whole log record for a transaction
 	var transactionLogRecordMap = make(map[uint64][]*storage.LogRecordPositionPair)
-	var currentSequenceNumber = nonTransactionSequenceNumber
+	var currentSequenceNumber = db.sequenceNumber
 
 	finishMergeFileName := path.Join(db.config.DirPath, storage.MergeFinishFileName)
 	var nonMergedFileId uint32 = 0
 	// Only update nonMergedFileId for not bplus tree index, otherwise reload all the index from data file
 	if db.config.IndexerType != index.BPlusTreeIndexType {
-		if _, err := os.Stat(finishMergeFileName); err == nil {
-			fileId, err := getNonMergedFileId(db.config.DirPath)
+		if _, err := db.fs.Stat(finishMergeFileName); err == nil {
+			fileId, err := db.getNonMergedFileId(db.config.DirPath)
 			if err != nil {
 				return err
 			}
@@ -470,29 +754,45 @@ func (db *DB) loadIndexFromDataFiles() error {
 		}
 	}
 
+	// the records before the high-water mark of index snapshot are already in index
+	var snapshotFileId uint32 = 0
+	var snapshotOffset int64 = 0
+	if db.indexSnapshotPos != nil {
+		snapshotFileId = db.indexSnapshotPos.Fid
+		snapshotOffset = db.indexSnapshotPos.Offset
+	}
+
 	// traverse file id to get file content
 	for i, fid := range db.fileIds {
-		var dataFile *storage.DataFile
 		var fileId = uint32(fid)
-
-		if fileId < nonMergedFileId {
+		if fileId < nonMergedFileId || fileId < snapshotFileId {
 			continue
 		}
+		dataFile := dataFiles[fileId]
 
-		if fileId == db.activeFile.FileId {
-			dataFile = db.activeFile
-		} else {
-			dataFile = db.inactiveFiles[fileId]
+		var offset int64 = 0
+		if fileId == snapshotFileId {
+			offset = snapshotOffset
+		}
+		// keep file open while it's loaded
+		release, err := db.openDataFile(dataFile)
+		if err != nil {
+			return err
 		}
 
-		var offset int64 = 0
 		// read each of log record on file until reach to eof
 		for {
+			// for lazy loading, the records appended after opening are pended
+			if db.indexLoadEndPos != nil && fileId == db.indexLoadEndPos.Fid && offset >= db.indexLoadEndPos.Offset {
+				break
+			}
+
 			logRecord, size, err := dataFile.ReadLogRecord(offset)
 			if err != nil {
 				if err == io.EOF {
 					break
 				}
+				release()
 				return err
 			}
 
@@ -501,17 +801,21 @@ func (db *DB) loadIndexFromDataFiles() error {
 				Offset:        offset,
 				LogRecordSize: uint32(size),
 			}
+			db.stats.addRecord(dataFile.FileId, size)
 
 			if logRecord.SequenceNumber == nonTransactionSequenceNumber {
 				if err = db.updateLogRecordIndex(logRecord, logRecordPos); err != nil {
+					release()
 					return err
 				}
 			} else {
 				// To update a transaction as a whole, keep atomicity
 				if logRecord.Type == storage.LogRecordTransactionFinished {
+					db.reclaim(logRecordPos)
 					// if we encounter transaction finish tag, update index at a time
 					for _, transactionLogRecord := range transactionLogRecordMap[logRecord.SequenceNumber] {
 						if err = db.updateLogRecordIndex(transactionLogRecord.Record, transactionLogRecord.Pos); err != nil {
+							release()
 							return err
 						}
 						delete(transactionLogRecordMap, logRecord.SequenceNumber)
@@ -531,10 +835,12 @@ func (db *DB) loadIndexFromDataFiles() error {
 
 			offset += size
 		}
+		release()
 
-		// if current file is active file, update WriteOffset from current offset
-		if i == len(db.fileIds)-1 {
-			db.activeFile.WriteOffset = offset
+		// if current file is active file, update WriteOffset from current offset,
+		// for lazy loading it's already set before writes are accepted
+		if i == len(db.fileIds)-1 && !db.config.LazyIndexLoad {
+			dataFile.WriteOffset = offset
 		}
 	}
 
@@ -543,6 +849,18 @@ func (db *DB) loadIndexFromDataFiles() error {
 	return nil
 }
 
+// dataFilesForLoading the data files found on open, <fid, *file>
+func (db *DB) dataFilesForLoading() map[uint32]*storage.DataFile {
+	dataFiles := make(map[uint32]*storage.DataFile, len(db.inactiveFiles)+1)
+	for fid, dataFile := range db.inactiveFiles {
+		dataFiles[fid] = dataFile
+	}
+	if db.activeFile != nil {
+		dataFiles[db.activeFile.FileId] = db.activeFile
+	}
+	return dataFiles
+}
+
 // load sequence number for bplus tree index
 func (db *DB) loadSequenceNumberFile() error {
 	if db.config.IndexerType != index.BPlusTreeIndexType {
@@ -550,14 +868,14 @@ func (db *DB) loadSequenceNumberFile() error {
 	}
 
 	fileName := path.Join(db.config.DirPath, storage.SequenceNumberFileName)
-	if _, err := os.Stat(fileName); err != nil {
+	if _, err := db.fs.Stat(fileName); err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
 		return err
 	}
 
-	seqNoFile, err := storage.OpenSequenceNumberFile(db.config.DirPath)
+	seqNoFile, err := storage.OpenSequenceNumberFile(db.fs, db.config.DirPath, db.fileIOType())
 	if err != nil {
 		return err
 	}
@@ -577,7 +895,7 @@ func (db *DB) writeSequenceNumber() error {
 	}
 
 	// store sequence number in file
-	seqNoFile, err := storage.OpenSequenceNumberFile(db.config.DirPath)
+	seqNoFile, err := storage.OpenSequenceNumberFile(db.fs, db.config.DirPath, db.fileIOType())
 	if err != nil {
 		return err
 	}
@@ -597,24 +915,22 @@ func (db *DB) writeSequenceNumber() error {
 }
 
 func (db *DB) getValueByLogPosition(logRecordPos *storage.LogRecordPos) ([]byte, error) {
-	// get storage file from file id
-	var dataFile *storage.DataFile
-	if db.activeFile.FileId == logRecordPos.Fid {
-		dataFile = db.activeFile
-	} else {
-		dataFile = db.inactiveFiles[logRecordPos.Fid]
-	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 
-	// storage file is empty
-	if dataFile == nil {
-		return nil, ErrDataFileNotFound
+	// get storage file from file id
+	dataFile, release, err := db.acquireDataFile(logRecordPos.Fid)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
 	// read storage based on offset
-	logRecord, _, err := dataFile.ReadLogRecord(logRecordPos.Offset)
+	logRecord, size, err := dataFile.ReadLogRecord(logRecordPos.Offset)
 	if err != nil {
 		return nil, err
 	}
+	db.stats.bytesRead.Add(uint64(size))
 
 	if logRecord.Type == storage.LogRecordDeleted {
 		return nil, ErrKeyNotFound
@@ -623,54 +939,193 @@ func (db *DB) getValueByLogPosition(logRecordPos *storage.LogRecordPos) ([]byte,
 	return logRecord.Value, nil
 }
 
+// readKeyByLogPosition read key on disk, used by compact index to verify key hash
+func (db *DB) readKeyByLogPosition(logRecordPos *storage.LogRecordPos) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dataFile, release, err := db.acquireDataFile(logRecordPos.Fid)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	logRecord, _, err := dataFile.ReadLogRecord(logRecordPos.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return logRecord.Key, nil
+}
+
+func (db *DB) newIndexer() index.Indexer {
+	syncWrites := db.config.SyncPolicy.Type == SyncEveryWrite
+	return index.NewIndexer(db.config.IndexerType, db.config.DirPath, syncWrites, db.readKeyByLogPosition)
+}
+
 func (db *DB) updateLogRecordIndex(logRecord *storage.LogRecord, logRecordPos *storage.LogRecordPos) error {
 	// build index
 	// 1,check if log record has been deleted, if did, then delete it from index (while it's not been merged for log records)
 	var oldPos *storage.LogRecordPos
+	if logRecord.Type == storage.LogRecordRangeDeleted {
+		// range tombstone only deletes the keys written before it
+		if err := db.deleteIndexRange(logRecord.Key, logRecord.Value, logRecordPos); err != nil {
+			return err
+		}
+		db.reclaim(logRecordPos)
+		return nil
+	}
 	if logRecord.Type == storage.LogRecordDeleted {
 
 		// it's possible key is not on index, but in the log record
 		// for example, two thread concurrently executes, one is deleting key and another is merging data file
 		// so the delete record will be put into a new active file if
-		maybePos := db.index.Get(logRecord.Key)
-		if maybePos == nil {
+		maybePos, err := index.CheckedGet(db.index, logRecord.Key)
+		if err != nil {
+			return err
+		}
+		// B+tree index is persisted, key written after the tombstone is kept while replaying data files
+		if maybePos == nil || !isWrittenBefore(maybePos, logRecordPos) {
+			db.reclaim(logRecordPos)
 			return nil
 		}
 
-		oldPos2, ok := db.index.Delete(logRecord.Key)
+		oldPos2, ok, err := index.CheckedDelete(db.index, logRecord.Key)
+		if err != nil {
+			return err
+		}
 		if !ok {
 			return ErrIndexDeleteFailed
 		}
 		oldPos = oldPos2
-		db.reclaimSize += int64(logRecordPos.LogRecordSize)
+		db.reclaim(logRecordPos)
 	} else {
-		oldPos = db.index.Put(logRecord.Key, logRecordPos)
+		var err error
+		if oldPos, err = index.CheckedPut(db.index, logRecord.Key, logRecordPos); err != nil {
+			return err
+		}
 	}
-	if oldPos != nil {
-		db.reclaimSize += int64(oldPos.LogRecordSize)
+	// position of persisted B+tree index may be not overwritten by the replayed record but the same or a later one,
+	// it's counted when the record of it is replayed
+	if oldPos != nil && isWrittenBefore(oldPos, logRecordPos) {
+		db.reclaim(oldPos)
 	}
 
 	return nil
 }
 
-func (db *DB) setDateFileIOType(ioType fio.IOType) error {
+// isWrittenBefore check if record at pos is appended before the one at other
+func isWrittenBefore(pos, other *storage.LogRecordPos) bool {
+	return pos.Fid < other.Fid || (pos.Fid == other.Fid && pos.Offset < other.Offset)
+}
+
+func (db *DB) setDateFileIOType(activeIOType, inactiveIOType fio.IOType) error {
 	if db.activeFile == nil {
 		return nil
 	}
 
-	if err := db.activeFile.SetIOType(db.config.DirPath, ioType); err != nil {
+	if err := db.activeFile.SetIOType(db.fs, db.config.DirPath, activeIOType); err != nil {
 		return err
 	}
 
+	if db.fileCache != nil {
+		return db.fileCache.setIOType(inactiveIOType)
+	}
 	for _, inactiveFile := range db.inactiveFiles {
-		if err := inactiveFile.SetIOType(db.config.DirPath, ioType); err != nil {
+		if err := inactiveFile.SetIOType(db.fs, db.config.DirPath, inactiveIOType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyMemoryDir copy files of in memory database to dest, which is in the same file system of database
+func (db *DB) copyMemoryDir(dest string) error {
+	entries, err := db.fs.ReadDir(db.config.DirPath)
+	if err != nil {
+		return err
+	}
+	if err := db.fs.MkdirAll(dest, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		src, err := db.fs.OpenFile(filepath.Join(db.config.DirPath, entry.Name()), fio.MemoryIOType)
+		if err != nil {
+			return err
+		}
+		size, err := src.Size()
+		if err != nil {
+			return err
+		}
+		buf := make([]byte, size)
+		if _, err := src.Read(buf, 0); err != nil {
+			return err
+		}
+
+		dst, err := db.fs.OpenFile(filepath.Join(dest, entry.Name()), fio.MemoryIOType)
+		if err != nil {
+			return err
+		}
+		if err := dst.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := dst.Write(buf); err != nil {
 			return err
 		}
 	}
+	return nil
+}
+
+// fileIOType io type of files other than data files
+func (db *DB) fileIOType() fio.IOType {
+	if db.config.InMemory {
+		return fio.MemoryIOType
+	}
+	return fio.StandardFileIOType
+}
+
+// activeFileIOType io type of data file which is written
+func (db *DB) activeFileIOType() fio.IOType {
+	if db.config.InMemory {
+		return fio.MemoryIOType
+	}
+	if db.config.EnableMMapWrites {
+		return fio.MMapRWIOType
+	}
+	return fio.StandardFileIOType
+}
+
+// truncateActiveDataFile truncate active file to write offset if there are bytes not loaded after it
+func (db *DB) truncateActiveDataFile() error {
+	if db.activeFile == nil {
+		return nil
+	}
 
+	size, err := db.activeFile.IOManager.Size()
+	if err != nil {
+		return err
+	}
+	if size > db.activeFile.WriteOffset {
+		return db.activeFile.Truncate(db.activeFile.WriteOffset)
+	}
 	return nil
 }
 
+// inactiveFileIOType io type of data files which are not written any more
+func (db *DB) inactiveFileIOType() fio.IOType {
+	if db.config.InMemory {
+		return fio.MemoryIOType
+	}
+	if db.config.MMapInactiveFiles {
+		return fio.MMapIOType
+	}
+	return fio.StandardFileIOType
+}
+
 func checkDbConfig(config Config) error {
 	if config.DirPath == "" {
 		return errors.New("database dir path is empty")
@@ -680,14 +1135,32 @@ func checkDbConfig(config Config) error {
 		return errors.New("database storage file size less than or equal to zero")
 	}
 
-	if config.BytesToSync < 0 {
-		return errors.New("database storage file bytes less than zero")
+	switch config.SyncPolicy.Type {
+	case SyncNever, SyncEveryWrite:
+	case SyncEveryNBytes:
+		if config.SyncPolicy.Bytes == 0 {
+			return errors.New("database sync bytes is zero")
+		}
+	case SyncEveryInterval:
+		if config.SyncPolicy.Interval <= 0 {
+			return errors.New("database sync interval less than or equal to zero")
+		}
+	default:
+		return errors.New("database sync policy is unknown")
 	}
 
 	if config.MergeRatio < 0 || config.MergeRatio > 1 {
 		return errors.New("database merge ratio less than 0 or greater than 1")
 	}
 
+	if config.MaxOpenFiles < 0 {
+		return errors.New("database max open files less than zero")
+	}
+
+	if config.InMemory && config.IndexerType == index.BPlusTreeIndexType {
+		return errors.New("database in memory doesn't support bplus tree index")
+	}
+
 	return nil
 }
 
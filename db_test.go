@@ -1,12 +1,16 @@
 package bitcask_go
 
 import (
+	"bitcask-go/fio"
 	"bitcask-go/index"
+	"bitcask-go/storage"
 	"bitcask-go/utils"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"path"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -596,3 +600,287 @@ func TestDB_Backup(t *testing.T) {
 	}
 
 }
+
+func TestDB_CompactIndex(t *testing.T) {
+	for _, indexType := range []index.IndexerType{index.CompactIndexType, index.CompactHashIndexType} {
+		configs := DefaultConfig
+		dir, _ := os.MkdirTemp("", "bitcask_test_compact")
+		configs.DirPath = dir
+		configs.DataFileSize = 64 * 1024
+		configs.IndexerType = indexType
+
+		database, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+
+		n := 1000
+		for i := 0; i < n; i++ {
+			err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+			assert.Nil(t, err)
+		}
+		err = database.Put(utils.GenerateTestKey(1), []byte("overwrite"))
+		assert.Nil(t, err)
+		err = database.Delete(utils.GenerateTestKey(2))
+		assert.Nil(t, err)
+
+		stats, err := database.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, uint(n-1), stats.KeyNum)
+		assert.Greater(t, stats.IndexMemoryInBytes, int64(0))
+		assert.Greater(t, stats.IndexBytesPerKey, float64(0))
+
+		// restart with data files only
+		err = database.Close()
+		assert.Nil(t, err)
+		err = os.Remove(storage.GetIndexSnapshotFileName(dir))
+		assert.Nil(t, err)
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+
+		val, err := database.Get(utils.GenerateTestKey(1))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("overwrite"), val)
+		_, err = database.Get(utils.GenerateTestKey(2))
+		assert.Equal(t, ErrKeyNotFound, err)
+		val, err = database.Get(utils.GenerateTestKey(n - 1))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GenerateTestKey(n-1), val)
+
+		keys := database.ListKeys()
+		assert.Equal(t, n-1, len(keys))
+		assert.Equal(t, utils.GenerateTestKey(0), keys[0])
+		assert.Equal(t, utils.GenerateTestKey(3), keys[2])
+
+		// restart with index snapshot
+		err = database.Close()
+		assert.Nil(t, err)
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+		assert.NotNil(t, database.indexSnapshotPos)
+		assert.Equal(t, n-1, len(database.ListKeys()))
+
+		destroyDatabase(database)
+	}
+}
+
+func TestDB_ShardedHashIndex(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_sharded_hash")
+	configs.DirPath = dir
+	configs.DataFileSize = 64 * 1024
+	configs.IndexerType = index.ShardedHashIndexType
+	configs.MergeRatio = 0
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	// keys are spread over shards by concurrent writers
+	n := 1000
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < n; i += 4 {
+				assert.Nil(t, database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+	err = database.Put(utils.GenerateTestKey(1), []byte("overwrite"))
+	assert.Nil(t, err)
+	err = database.Delete(utils.GenerateTestKey(2))
+	assert.Nil(t, err)
+
+	check := func(database *DB) {
+		val, err := database.Get(utils.GenerateTestKey(1))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("overwrite"), val)
+		_, err = database.Get(utils.GenerateTestKey(2))
+		assert.Equal(t, ErrKeyNotFound, err)
+		val, err = database.Get(utils.GenerateTestKey(n - 1))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GenerateTestKey(n-1), val)
+
+		// keys of all shards are listed in order
+		keys := database.ListKeys()
+		assert.Equal(t, n-1, len(keys))
+		assert.Equal(t, utils.GenerateTestKey(0), keys[0])
+		assert.Equal(t, utils.GenerateTestKey(3), keys[2])
+	}
+	check(database)
+
+	// restart by replaying data files
+	err = database.Close()
+	assert.Nil(t, err)
+	err = os.Remove(storage.GetIndexSnapshotFileName(dir))
+	assert.Nil(t, err)
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	check(database)
+
+	// merged files are installed on restart, then restart again by hint file
+	err = database.Merge()
+	assert.Nil(t, err)
+	check(database)
+	err = database.Close()
+	assert.Nil(t, err)
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	check(database)
+	err = database.Close()
+	assert.Nil(t, err)
+	err = os.Remove(storage.GetIndexSnapshotFileName(dir))
+	assert.Nil(t, err)
+	_, err = os.Stat(storage.GetHintFileName(dir))
+	assert.Nil(t, err)
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	check(database)
+
+	stats, err := database.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(n-1), stats.KeyNum)
+
+	destroyDatabase(database)
+}
+
+func TestDB_MMapWrites(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_mmap_writes")
+	configs.DirPath = dir
+	configs.DataFileSize = 2 * 1024 * 1024
+	configs.EnableMMapWrites = true
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	n := 20000
+	for i := 0; i < n; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64))
+		assert.Nil(t, err)
+	}
+	assert.Greater(t, len(database.inactiveFiles), 0)
+	err = database.Delete(utils.GenerateTestKey(0))
+	assert.Nil(t, err)
+	err = database.Sync()
+	assert.Nil(t, err)
+
+	// copy of the pre-sized active file is like a crashed database
+	dir2, _ := os.MkdirTemp("", "bitcask_test_mmap_writes2")
+	err = database.Backup(dir2)
+	assert.Nil(t, err)
+
+	configs2 := configs
+	configs2.DirPath = dir2
+	database2, err := OpenDatabase(configs2)
+	defer destroyDatabase(database2)
+	assert.Nil(t, err)
+
+	_, err = database2.Get(utils.GenerateTestKey(0))
+	assert.Equal(t, ErrKeyNotFound, err)
+	err = database2.Put(utils.GenerateTestKey(n), []byte("value"))
+	assert.Nil(t, err)
+
+	// reopen after close, the appended record follows the old records
+	err = database2.Close()
+	assert.Nil(t, err)
+	database2, err = OpenDatabase(configs2)
+	assert.Nil(t, err)
+
+	val, err := database2.Get(utils.GenerateTestKey(n))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+	assert.Equal(t, n, len(database2.ListKeys()))
+}
+
+func TestDB_InMemory(t *testing.T) {
+	configs := DefaultConfig
+	configs.DirPath = filepath.Join("/memory", "bitcask_test_in_memory")
+	configs.DataFileSize = 64 * 1024
+	configs.InMemory = true
+	configs.MergeRatio = 0
+	// files are kept in the given file system after close
+	configs.FileSystem = fio.NewMemoryFileSystem()
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	n := 5000
+	for i := 0; i < n; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < n/2; i++ {
+		err = database.Delete(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	assert.Greater(t, len(database.inactiveFiles), 0)
+
+	// nothing is written to disk
+	_, err = os.Stat(configs.DirPath)
+	assert.True(t, os.IsNotExist(err))
+
+	stats, err := database.Stats()
+	assert.Nil(t, err)
+	assert.Greater(t, stats.TotalFileSizeInBytes, int64(0))
+
+	err = database.Merge()
+	assert.Nil(t, err)
+
+	// reopen in the same process, merged files are installed
+	err = database.Close()
+	assert.Nil(t, err)
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	defer database.Close()
+
+	assert.Equal(t, n/2, len(database.ListKeys()))
+	_, err = database.Get(utils.GenerateTestKey(0))
+	assert.Equal(t, ErrKeyNotFound, err)
+	val, err := database.Get(utils.GenerateTestKey(n - 1))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GenerateTestKey(n-1), val)
+
+	configs.IndexerType = index.BPlusTreeIndexType
+	_, err = OpenDatabase(configs)
+	assert.NotNil(t, err)
+}
+
+func TestDB_InMemory_Ephemeral(t *testing.T) {
+	configs := DefaultConfig
+	configs.InMemory = true
+
+	// databases of the same default dir don't share files
+	database1, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+	database2, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	err = database1.Put(utils.GenerateTestKey(1), []byte("value-1"))
+	assert.Nil(t, err)
+	err = database2.Put(utils.GenerateTestKey(1), []byte("value-2"))
+	assert.Nil(t, err)
+	val, err := database1.Get(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-1"), val)
+	val, err = database2.Get(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), val)
+
+	// files are freed on close
+	err = database1.Close()
+	assert.Nil(t, err)
+	_, err = database1.fs.Stat(configs.DirPath)
+	assert.True(t, os.IsNotExist(err))
+	val, err = database2.Get(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), val)
+
+	err = database2.Close()
+	assert.Nil(t, err)
+	database2, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	defer database2.Close()
+	assert.Equal(t, 0, len(database2.ListKeys()))
+}
@@ -0,0 +1,100 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/storage"
+	"bytes"
+)
+
+// DeleteRange delete all the keys in [start, end) with a single range tombstone,
+// empty start means from the first key and empty end means to the last key
+func (db *DB) DeleteRange(start, end []byte) error {
+	if !db.isOpen.Load() {
+		return ErrDBClosed
+	}
+
+	if db.config.ReadOnly {
+		return ErrReadOnlyDatabase
+	}
+
+	if len(end) > 0 && bytes.Compare(start, end) >= 0 {
+		return ErrInvalidKeyRange
+	}
+
+	// the keys to delete are found from index
+	if err := db.waitIndexReady(); err != nil {
+		return err
+	}
+
+	logRecord := &storage.LogRecord{
+		Key:            start,
+		Value:          end,
+		Type:           storage.LogRecordRangeDeleted,
+		SequenceNumber: nonTransactionSequenceNumber,
+	}
+
+	// keep appending tombstone and deleting keys from index atomically, keys in range are unknown so all keys are locked
+	unlock := db.keyLocks.lockAll()
+	defer unlock()
+
+	pos, err := db.appendLogRecordWithLock(logRecord, DefaultWriteOptions)
+	if err != nil {
+		return err
+	}
+	db.stats.deletes.Add(1)
+
+	if err := db.deleteIndexRange(start, end, pos); err != nil {
+		return err
+	}
+	db.reclaim(pos)
+
+	return nil
+}
+
+// DeletePrefix delete all the keys with prefix with a single range tombstone
+func (db *DB) DeletePrefix(prefix []byte) error {
+	if len(prefix) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	// nil upper bound means prefix is all 0xff, which is not bounded
+	return db.DeleteRange(prefix, prefixUpperBound(prefix))
+}
+
+// deleteIndexRange remove the keys in [start, end) written before the tombstone at pos from index,
+// count the old records as reclaimable, nil pos means all the keys in range
+func (db *DB) deleteIndexRange(start, end []byte, pos *storage.LogRecordPos) error {
+	// collect keys first, iterator of bplus tree holds the transaction until closed
+	var keys [][]byte
+	iter, err := index.CheckedIterator(db.index, false)
+	if err != nil {
+		return err
+	}
+	if len(start) > 0 {
+		iter.Seek(start)
+	} else {
+		iter.Rewind()
+	}
+	for ; iter.Valid(); iter.Next() {
+		if len(end) > 0 && bytes.Compare(iter.Key(), end) >= 0 {
+			break
+		}
+		// B+tree index is persisted, key written after the tombstone is kept while replaying data files
+		if pos != nil && !isWrittenBefore(iter.Value(), pos) {
+			continue
+		}
+		keys = append(keys, bytes.Clone(iter.Key()))
+	}
+	iter.Close()
+
+	for _, key := range keys {
+		oldPos, ok, err := index.CheckedDelete(db.index, key)
+		if err != nil {
+			return err
+		}
+		if ok && oldPos != nil {
+			db.reclaim(oldPos)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/utils"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDB_DeleteRange(t *testing.T) {
+	for _, indexType := range []index.IndexerType{index.BTreeIndexType, index.ARTIndexType, index.BPlusTreeIndexType} {
+		configs := DefaultConfig
+		dir, _ := os.MkdirTemp("", "bitcask_test_delete_range")
+		configs.DirPath = dir
+		configs.IndexerType = indexType
+
+		database, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+
+		for i := 0; i < 100; i++ {
+			err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+			assert.Nil(t, err)
+		}
+
+		err = database.DeleteRange(utils.GenerateTestKey(20), utils.GenerateTestKey(10))
+		assert.Equal(t, ErrInvalidKeyRange, err)
+
+		err = database.DeleteRange(utils.GenerateTestKey(10), utils.GenerateTestKey(20))
+		assert.Nil(t, err)
+		_, err = database.Get(utils.GenerateTestKey(10))
+		assert.Equal(t, ErrKeyNotFound, err)
+		_, err = database.Get(utils.GenerateTestKey(19))
+		assert.Equal(t, ErrKeyNotFound, err)
+		_, err = database.Get(utils.GenerateTestKey(20))
+		assert.Nil(t, err)
+		assert.Equal(t, 90, len(database.ListKeys()))
+
+		// keys written after tombstone are kept
+		err = database.Put(utils.GenerateTestKey(15), []byte("new"))
+		assert.Nil(t, err)
+
+		stats, err := database.Stats()
+		assert.Nil(t, err)
+		assert.Greater(t, stats.ReclaimableSizeInBytes, int64(0))
+
+		// restart, tombstone is replayed from data file
+		err = database.Close()
+		assert.Nil(t, err)
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+
+		assert.Equal(t, 91, len(database.ListKeys()))
+		_, err = database.Get(utils.GenerateTestKey(10))
+		assert.Equal(t, ErrKeyNotFound, err)
+		val, err := database.Get(utils.GenerateTestKey(15))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("new"), val)
+
+		// delete to the end
+		err = database.DeleteRange(utils.GenerateTestKey(90), nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 81, len(database.ListKeys()))
+
+		destroyDatabase(database)
+	}
+}
+
+func TestDB_DeletePrefix(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_delete_prefix")
+	configs.DirPath = dir
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	for _, key := range []string{"a", "ab", "abc", "abd", "b", "ba"} {
+		err = database.Put([]byte(key), []byte(key))
+		assert.Nil(t, err)
+	}
+
+	err = database.DeletePrefix(nil)
+	assert.Equal(t, ErrKeyIsEmpty, err)
+
+	err = database.DeletePrefix([]byte("ab"))
+	assert.Nil(t, err)
+
+	keys := database.ListKeys()
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("ba")}, keys)
+
+	err = database.Put([]byte{0xff, 0x01}, []byte("x"))
+	assert.Nil(t, err)
+	err = database.DeletePrefix([]byte{0xff})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(database.ListKeys()))
+}
+
+func TestDB_DeleteRange_Merge(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_delete_range_merge")
+	configs.DirPath = dir
+	configs.MergeRatio = 0
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	for i := 0; i < 100; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = database.DeleteRange(nil, utils.GenerateTestKey(50))
+	assert.Nil(t, err)
+
+	err = database.Merge()
+	assert.Nil(t, err)
+	err = database.fileLock.Unlock()
+	assert.Nil(t, err)
+
+	// reopen without index snapshot, load from hint file
+	database, err = OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	keys := database.ListKeys()
+	assert.Equal(t, 50, len(keys))
+	assert.Equal(t, utils.GenerateTestKey(50), keys[0])
+	_, err = database.Get(utils.GenerateTestKey(0))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
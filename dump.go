@@ -0,0 +1,192 @@
+package bitcask_go
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+type DumpFormat = byte
+
+const (
+	// DumpFormatJSON one {"key": base64, "value": base64} object per line
+	DumpFormatJSON DumpFormat = iota
+	// DumpFormatBinary magic | uvarint keySize | key | uvarint valueSize | value | ... | 0 | crc of records
+	DumpFormatBinary
+)
+
+// dumpMagic start of binary dump, the last byte is version of format
+var dumpMagic = []byte("BCDUMP\x00\x01")
+
+type dumpEntry struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Dump write all the live key/value pairs in index order, return the number of pairs written.
+// Dump is independent of index type and data file layout, it's loaded by Load
+func (db *DB) Dump(w io.Writer, format DumpFormat) (int, error) {
+	writer := bufio.NewWriter(w)
+	crc := crc32.NewIEEE()
+	if format == DumpFormatBinary {
+		if _, err := writer.Write(dumpMagic); err != nil {
+			return 0, err
+		}
+	}
+
+	var count int
+	var writeErr error
+	encoder := json.NewEncoder(writer)
+	sizeBuf := make([]byte, binary.MaxVarintLen64)
+	err := db.Fold(func(key []byte, value []byte) bool {
+		if format == DumpFormatBinary {
+			// records are written to crc as well
+			out := io.MultiWriter(writer, crc)
+			n := binary.PutUvarint(sizeBuf, uint64(len(key)))
+			_, writeErr = out.Write(sizeBuf[:n])
+			if writeErr == nil {
+				_, writeErr = out.Write(key)
+			}
+			n = binary.PutUvarint(sizeBuf, uint64(len(value)))
+			if writeErr == nil {
+				_, writeErr = out.Write(sizeBuf[:n])
+			}
+			if writeErr == nil {
+				_, writeErr = out.Write(value)
+			}
+		} else {
+			writeErr = encoder.Encode(&dumpEntry{Key: key, Value: value})
+		}
+		if writeErr != nil {
+			return false
+		}
+		count++
+		return true
+	})
+	if err != nil {
+		return count, err
+	}
+	if writeErr != nil {
+		return count, writeErr
+	}
+
+	if format == DumpFormatBinary {
+		// empty key marks the end, keys can't be empty
+		trailer := binary.AppendUvarint(nil, 0)
+		trailer = binary.LittleEndian.AppendUint32(trailer, crc.Sum32())
+		if _, err := writer.Write(trailer); err != nil {
+			return count, err
+		}
+	}
+	return count, writer.Flush()
+}
+
+// Load put all the key/value pairs of dump, and sync once they are written, return the number of pairs loaded
+func (db *DB) Load(r io.Reader, format DumpFormat) (int, error) {
+	reader := bufio.NewReader(r)
+	var count int
+	put := func(key, value []byte) error {
+		if err := db.Put(key, value); err != nil {
+			return err
+		}
+		count++
+		return nil
+	}
+
+	var err error
+	if format == DumpFormatBinary {
+		err = loadBinaryDump(reader, db.config.DataFileSize, put)
+	} else {
+		err = loadJSONDump(reader, put)
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, db.Sync()
+}
+
+func loadJSONDump(reader *bufio.Reader, put func(key, value []byte) error) error {
+	decoder := json.NewDecoder(reader)
+	for {
+		var entry dumpEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return ErrInvalidDump
+		}
+		if len(entry.Key) == 0 {
+			return ErrInvalidDump
+		}
+		if entry.Value == nil {
+			entry.Value = []byte{}
+		}
+		if err := put(entry.Key, entry.Value); err != nil {
+			return err
+		}
+	}
+}
+
+// loadBinaryDump load records of binary dump, key or value larger than maxSize is rejected since it can't be put
+func loadBinaryDump(reader *bufio.Reader, maxSize int64, put func(key, value []byte) error) error {
+	magic := make([]byte, len(dumpMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || !bytes.Equal(magic, dumpMagic) {
+		return ErrInvalidDump
+	}
+
+	crc := crc32.NewIEEE()
+	readBytes := func(size uint64) ([]byte, error) {
+		// size of key and value in data file fits in uint32
+		if size > math.MaxUint32 || size > uint64(maxSize) {
+			return nil, ErrInvalidDump
+		}
+		// buffer grows as bytes are read, so size of a truncated dump doesn't allocate memory up front
+		buf, err := io.ReadAll(io.LimitReader(reader, int64(size)))
+		if err != nil || uint64(len(buf)) != size {
+			return nil, ErrInvalidDump
+		}
+		_, _ = crc.Write(binary.AppendUvarint(nil, size))
+		_, _ = crc.Write(buf)
+		return buf, nil
+	}
+
+	for {
+		keySize, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return ErrInvalidDump
+		}
+		// empty key marks the end of records
+		if keySize == 0 {
+			break
+		}
+		key, err := readBytes(keySize)
+		if err != nil {
+			return err
+		}
+		valueSize, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return ErrInvalidDump
+		}
+		value, err := readBytes(valueSize)
+		if err != nil {
+			return err
+		}
+		if err := put(key, value); err != nil {
+			return err
+		}
+	}
+
+	// pairs are loaded before crc is checked, the caller should drop the database if dump is corrupted
+	expected := make([]byte, crc32.Size)
+	if _, err := io.ReadFull(reader, expected); err != nil {
+		return ErrInvalidDump
+	}
+	if binary.LittleEndian.Uint32(expected) != crc.Sum32() {
+		return ErrInvalidDump
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/utils"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openDumpTestDatabase(t *testing.T, indexType index.IndexerType) *DB {
+	opts := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask-go-dump")
+	opts.DirPath = dir
+	opts.IndexerType = indexType
+	db, err := OpenDatabase(opts)
+	assert.Nil(t, err)
+	return db
+}
+
+func TestDB_DumpLoad(t *testing.T) {
+	for _, format := range []DumpFormat{DumpFormatJSON, DumpFormatBinary} {
+		src := openDumpTestDatabase(t, index.BTreeIndexType)
+		for i := 0; i < 1000; i++ {
+			assert.Nil(t, src.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+		}
+		for i := 0; i < 100; i++ {
+			assert.Nil(t, src.Delete(utils.GenerateTestKey(i)))
+		}
+		assert.Nil(t, src.Put([]byte("empty-value"), []byte{}))
+
+		buf := new(bytes.Buffer)
+		count, err := src.Dump(buf, format)
+		assert.Nil(t, err)
+		assert.Equal(t, 901, count)
+
+		// dump is loaded into database of another index type
+		dst := openDumpTestDatabase(t, index.ARTIndexType)
+		count, err = dst.Load(buf, format)
+		assert.Nil(t, err)
+		assert.Equal(t, 901, count)
+
+		assert.Equal(t, src.ListKeys(), dst.ListKeys())
+		err = src.Fold(func(key []byte, value []byte) bool {
+			loaded, err := dst.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, len(value), len(loaded))
+			assert.True(t, bytes.Equal(value, loaded))
+			return true
+		})
+		assert.Nil(t, err)
+
+		destroyDatabase(src)
+		destroyDatabase(dst)
+	}
+}
+
+func TestDB_LoadInvalidDump(t *testing.T) {
+	src := openDumpTestDatabase(t, index.BTreeIndexType)
+	defer destroyDatabase(src)
+	assert.Nil(t, src.Put([]byte("key"), []byte("value")))
+
+	buf := new(bytes.Buffer)
+	_, err := src.Dump(buf, DumpFormatBinary)
+	assert.Nil(t, err)
+	dump := buf.Bytes()
+
+	dst := openDumpTestDatabase(t, index.BTreeIndexType)
+	defer destroyDatabase(dst)
+
+	// corrupted value
+	corrupted := append([]byte(nil), dump...)
+	corrupted[len(dumpMagic)+5] ^= 0xff
+	_, err = dst.Load(bytes.NewReader(corrupted), DumpFormatBinary)
+	assert.Equal(t, ErrInvalidDump, err)
+
+	// truncated
+	_, err = dst.Load(bytes.NewReader(dump[:len(dump)-2]), DumpFormatBinary)
+	assert.Equal(t, ErrInvalidDump, err)
+
+	// huge size is rejected before reading, size of truncated value doesn't allocate memory up front
+	huge := binary.AppendUvarint(append([]byte(nil), dumpMagic...), math.MaxUint32)
+	_, err = dst.Load(bytes.NewReader(huge), DumpFormatBinary)
+	assert.Equal(t, ErrInvalidDump, err)
+	large := binary.AppendUvarint(append([]byte(nil), dumpMagic...), uint64(dst.config.DataFileSize))
+	_, err = dst.Load(bytes.NewReader(append(large, "key"...)), DumpFormatBinary)
+	assert.Equal(t, ErrInvalidDump, err)
+
+	// not a binary dump
+	_, err = dst.Load(bytes.NewReader([]byte(`{"key":"a2V5","value":""}`)), DumpFormatBinary)
+	assert.Equal(t, ErrInvalidDump, err)
+
+	_, err = dst.Load(bytes.NewReader([]byte(`{"key":"","value":""}`)), DumpFormatJSON)
+	assert.Equal(t, ErrInvalidDump, err)
+}
@@ -14,4 +14,17 @@ var (
 	ErrDBClosed                   = errors.New("database is closed")
 	ErrMergeRatioNotSatisfied     = errors.New("merge ratio not satisfied")
 	ErrNotEnoughDiskSpace         = errors.New("not enough disk space")
+	ErrInvalidKeyRange            = errors.New("invalid key range")
+	ErrValueTooLarge              = errors.New("value is too large")
+	ErrInvalidShardNum            = errors.New("shard number less than or equal to zero")
+	ErrShardNumMismatch           = errors.New("shard number doesn't match existing shards")
+	ErrReadOnlyDatabase           = errors.New("database is read only")
+	ErrReplicationStarted         = errors.New("replication is already started")
+	ErrInvalidReplicationMessage  = errors.New("invalid replication message")
+	ErrCDCStarted                 = errors.New("change data capture is already started")
+	ErrInvalidCDCConfig           = errors.New("change data capture needs writer or directory")
+	ErrCDCCheckpointMerged        = errors.New("data files after change data capture checkpoint are rewritten by merge")
+	ErrInvalidCDCCheckpoint       = errors.New("change data capture checkpoint is ahead of database")
+	ErrInvalidChangeEvent         = errors.New("invalid change event")
+	ErrInvalidDump                = errors.New("invalid dump")
 )
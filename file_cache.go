@@ -0,0 +1,167 @@
+package bitcask_go
+
+import (
+	"bitcask-go/fio"
+	"bitcask-go/storage"
+	"container/list"
+	"sync"
+)
+
+// dataFileCache bound the number of open inactive data files, the least recently used file is closed
+// once the limit is exceeded and reopened on demand. A file acquired by reader is never closed until released
+type dataFileCache struct {
+	mu       *sync.Mutex
+	fs       fio.FileSystem
+	dirPath  string
+	capacity int
+	ioType   fio.IOType
+	lru      *list.List                // open files, front is the most recently used
+	entries  map[uint32]*list.Element  // open files by file id
+	refs     map[*storage.DataFile]int // number of readers using the file
+}
+
+func newDataFileCache(fs fio.FileSystem, dirPath string, capacity int, ioType fio.IOType) *dataFileCache {
+	return &dataFileCache{
+		mu:       new(sync.Mutex),
+		fs:       fs,
+		dirPath:  dirPath,
+		capacity: capacity,
+		ioType:   ioType,
+		lru:      list.New(),
+		entries:  make(map[uint32]*list.Element),
+		refs:     make(map[*storage.DataFile]int),
+	}
+}
+
+// acquire open data file if it's closed and keep it open until release
+func (c *dataFileCache) acquire(dataFile *storage.DataFile) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[dataFile.FileId]; ok {
+		c.lru.MoveToFront(elem)
+	} else {
+		if err := c.openLocked(dataFile); err != nil {
+			return err
+		}
+	}
+	c.refs[dataFile]++
+	c.evictLocked()
+	return nil
+}
+
+func (c *dataFileCache) release(dataFile *storage.DataFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.refs[dataFile]--; c.refs[dataFile] <= 0 {
+		delete(c.refs, dataFile)
+	}
+	c.evictLocked()
+}
+
+// add data file which is already open, e.g. the active file becomes inactive
+func (c *dataFileCache) add(dataFile *storage.DataFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[dataFile.FileId] = c.lru.PushFront(dataFile)
+	c.evictLocked()
+}
+
+// setIOType close the open files not in use, they are reopened in io type
+func (c *dataFileCache) setIOType(ioType fio.IOType) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ioType = ioType
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if c.refs[elem.Value.(*storage.DataFile)] == 0 {
+			if err := c.closeLocked(elem); err != nil {
+				return err
+			}
+		}
+		elem = prev
+	}
+	return nil
+}
+
+// closeAll close all the open files
+func (c *dataFileCache) closeAll() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if err := c.closeLocked(elem); err != nil {
+			return err
+		}
+		elem = prev
+	}
+	return nil
+}
+
+func (c *dataFileCache) openLocked(dataFile *storage.DataFile) error {
+	ioManager, err := c.fs.OpenFile(storage.GetDataFileName(c.dirPath, dataFile.FileId), c.ioType)
+	if err != nil {
+		return err
+	}
+	dataFile.IOManager = ioManager
+	c.entries[dataFile.FileId] = c.lru.PushFront(dataFile)
+	return nil
+}
+
+func (c *dataFileCache) closeLocked(elem *list.Element) error {
+	dataFile := elem.Value.(*storage.DataFile)
+	c.lru.Remove(elem)
+	delete(c.entries, dataFile.FileId)
+
+	err := dataFile.Close()
+	dataFile.IOManager = nil
+	return err
+}
+
+// evictLocked close the least recently used files not in use until the limit is satisfied
+func (c *dataFileCache) evictLocked() {
+	for elem := c.lru.Back(); elem != nil && c.lru.Len() > c.capacity; {
+		prev := elem.Prev()
+		if c.refs[elem.Value.(*storage.DataFile)] == 0 {
+			_ = c.closeLocked(elem)
+		}
+		elem = prev
+	}
+}
+
+// acquireDataFile get data file of fid, inactive file is kept open until release is called
+func (db *DB) acquireDataFile(fid uint32) (*storage.DataFile, func(), error) {
+	var dataFile *storage.DataFile
+	if db.activeFile != nil && db.activeFile.FileId == fid {
+		dataFile = db.activeFile
+	} else {
+		dataFile = db.inactiveFiles[fid]
+	}
+	if dataFile == nil {
+		return nil, nil, ErrDataFileNotFound
+	}
+
+	release, err := db.openDataFile(dataFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dataFile, release, nil
+}
+
+// openDataFile make sure data file is open until release is called, only inactive files can be closed by cache
+func (db *DB) openDataFile(dataFile *storage.DataFile) (func(), error) {
+	if db.fileCache == nil || dataFile == db.activeFile {
+		return func() {}, nil
+	}
+
+	if err := db.fileCache.acquire(dataFile); err != nil {
+		return nil, err
+	}
+	return func() {
+		db.fileCache.release(dataFile)
+	}, nil
+}
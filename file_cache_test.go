@@ -0,0 +1,78 @@
+package bitcask_go
+
+import (
+	"bitcask-go/utils"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestDB_MaxOpenFiles(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_max_open_files")
+	configs.DirPath = dir
+	configs.DataFileSize = 4 * 1024
+	configs.MaxOpenFiles = 2
+	configs.MergeRatio = 0
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	n := 1000
+	for i := 0; i < n; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	assert.Greater(t, len(database.inactiveFiles), 10)
+	assert.LessOrEqual(t, database.fileCache.lru.Len(), configs.MaxOpenFiles)
+
+	// reopen, inactive files are opened on demand
+	err = database.Close()
+	assert.Nil(t, err)
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, database.fileCache.lru.Len(), configs.MaxOpenFiles)
+
+	wg := new(sync.WaitGroup)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < n; i += 4 {
+				val, err := database.Get(utils.GenerateTestKey(i))
+				assert.Nil(t, err)
+				assert.Equal(t, utils.GenerateTestKey(i), val)
+			}
+		}(g)
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, database.fileCache.lru.Len(), configs.MaxOpenFiles)
+
+	// file used by reader is not closed until reader is closed
+	reader, _, err := database.GetReader(utils.GenerateTestKey(0))
+	assert.Nil(t, err)
+	for i := n - 1; i > 0; i -= 50 {
+		_, err = database.Get(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	val, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GenerateTestKey(0), val)
+	assert.Nil(t, reader.Close())
+	assert.LessOrEqual(t, database.fileCache.lru.Len(), configs.MaxOpenFiles)
+
+	err = database.Merge()
+	assert.Nil(t, err)
+	err = database.Close()
+	assert.Nil(t, err)
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	for i := 0; i < n; i++ {
+		val, err := database.Get(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GenerateTestKey(i), val)
+	}
+}
@@ -0,0 +1,269 @@
+package fio
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrInjectedFault = errors.New("injected fault")
+	ErrCrashed       = errors.New("injected crash")
+)
+
+// FaultInjector script failures of the io managers of files under a path, used to test crash safety.
+// Write, Sync and Read fail after the configured budget is used up, a crash discards all the unsynced bytes
+type FaultInjector struct {
+	mu      *sync.Mutex
+	path    string
+	files   map[string]*faultFile // synced state of file, kept across reopening
+	crashed bool
+
+	writeBudget int64 // bytes can be written before failing, negative means unlimited
+	syncBudget  int   // syncs can succeed before failing, negative means unlimited
+	readBudget  int64 // bytes can be read before failing, negative means unlimited
+}
+
+type faultFile struct {
+	fs         FileSystem // file system file is opened by
+	ioType     IOType
+	syncedSize int64
+}
+
+// NewFaultInjector injector of files whose name starts with path, nothing fails until scripted
+func NewFaultInjector(path string) *FaultInjector {
+	return &FaultInjector{
+		mu:          new(sync.Mutex),
+		path:        path,
+		files:       make(map[string]*faultFile),
+		writeBudget: -1,
+		syncBudget:  -1,
+		readBudget:  -1,
+	}
+}
+
+// FileSystem wrap fs, io managers of files under path opened by it are wrapped by injector
+func (fi *FaultInjector) FileSystem(fs FileSystem) FileSystem {
+	return &faultFileSystem{FileSystem: fs, injector: fi}
+}
+
+// FailWriteAfter fail write once n more bytes are written, the failing write is short
+func (fi *FaultInjector) FailWriteAfter(n int64) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.writeBudget = n
+}
+
+// FailSyncAfter fail sync once n more syncs succeed
+func (fi *FaultInjector) FailSyncAfter(n int) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.syncBudget = n
+}
+
+// FailReadAfter fail read once n more bytes are read
+func (fi *FaultInjector) FailReadAfter(n int64) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	fi.readBudget = n
+}
+
+// Crash truncate every existing file to its synced size, all io after crash fails until Reset
+func (fi *FaultInjector) Crash() error {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fi.crashed = true
+	for fileName, file := range fi.files {
+		ioType := StandardFileIOType
+		if file.ioType == MemoryIOType {
+			ioType = MemoryIOType
+		}
+		// file removed or renamed is not restored
+		if _, err := file.fs.Stat(fileName); err != nil {
+			continue
+		}
+		ioManager, err := file.fs.OpenFile(fileName, ioType)
+		if err != nil {
+			return err
+		}
+		size, err := ioManager.Size()
+		if err == nil && size > file.syncedSize {
+			err = ioManager.Truncate(file.syncedSize)
+		}
+		if closeErr := ioManager.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset clear the scripted faults and crash state, synced state of files is kept
+func (fi *FaultInjector) Reset() {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	fi.crashed = false
+	fi.writeBudget = -1
+	fi.syncBudget = -1
+	fi.readBudget = -1
+}
+
+func (fi *FaultInjector) wrap(fs FileSystem, fileName string, ioType IOType, ioManager IOManager) (IOManager, error) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fi.crashed {
+		_ = ioManager.Close()
+		return nil, ErrCrashed
+	}
+
+	if _, ok := fi.files[fileName]; !ok {
+		// existing content is treated as durable
+		size, err := ioManager.Size()
+		if err != nil {
+			return nil, err
+		}
+		fi.files[fileName] = &faultFile{fs: fs, ioType: ioType, syncedSize: size}
+	}
+	if ioType != MMapIOType {
+		fi.files[fileName].ioType = ioType
+	}
+	return &FaultIO{injector: fi, fileName: fileName, inner: ioManager}, nil
+}
+
+// FaultIO io manager wrapped by FaultInjector
+type FaultIO struct {
+	injector *FaultInjector
+	fileName string
+	inner    IOManager
+}
+
+// Read byte from given offset
+func (fio *FaultIO) Read(buf []byte, offset int64) (int, error) {
+	fi := fio.injector
+	fi.mu.Lock()
+	if fi.crashed {
+		fi.mu.Unlock()
+		return 0, ErrCrashed
+	}
+	var err error
+	if fi.readBudget >= 0 && int64(len(buf)) > fi.readBudget {
+		buf = buf[:fi.readBudget]
+		err = ErrInjectedFault
+	}
+	if fi.readBudget >= 0 {
+		fi.readBudget -= int64(len(buf))
+	}
+	fi.mu.Unlock()
+
+	n, readErr := fio.inner.Read(buf, offset)
+	if readErr != nil && readErr != io.EOF {
+		return n, readErr
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, readErr
+}
+
+// Write byte to file, write exceeding budget is short and fails
+func (fio *FaultIO) Write(buf []byte) (int, error) {
+	fi := fio.injector
+	fi.mu.Lock()
+	if fi.crashed {
+		fi.mu.Unlock()
+		return 0, ErrCrashed
+	}
+	var err error
+	if fi.writeBudget >= 0 && int64(len(buf)) > fi.writeBudget {
+		buf = buf[:fi.writeBudget]
+		err = ErrInjectedFault
+	}
+	if fi.writeBudget >= 0 {
+		fi.writeBudget -= int64(len(buf))
+	}
+	fi.mu.Unlock()
+
+	n, writeErr := fio.inner.Write(buf)
+	if writeErr != nil {
+		return n, writeErr
+	}
+	return n, err
+}
+
+// Sync Flush to disk, synced size is recorded for crash
+func (fio *FaultIO) Sync() error {
+	fi := fio.injector
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fi.crashed {
+		return ErrCrashed
+	}
+	if fi.syncBudget == 0 {
+		return ErrInjectedFault
+	}
+	if fi.syncBudget > 0 {
+		fi.syncBudget--
+	}
+
+	if err := fio.inner.Sync(); err != nil {
+		return err
+	}
+	size, err := fio.inner.Size()
+	if err != nil {
+		return err
+	}
+	fi.files[fio.fileName].syncedSize = size
+	return nil
+}
+
+// Close To close file
+func (fio *FaultIO) Close() error {
+	return fio.inner.Close()
+}
+
+// Size of a file
+func (fio *FaultIO) Size() (int64, error) {
+	return fio.inner.Size()
+}
+
+// Truncate file to size
+func (fio *FaultIO) Truncate(size int64) error {
+	fi := fio.injector
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	if fi.crashed {
+		return ErrCrashed
+	}
+	if err := fio.inner.Truncate(size); err != nil {
+		return err
+	}
+	if file := fi.files[fio.fileName]; file.syncedSize > size {
+		file.syncedSize = size
+	}
+	return nil
+}
+
+// faultFileSystem file system whose files under path of injector are wrapped by it
+type faultFileSystem struct {
+	FileSystem
+	injector *FaultInjector
+}
+
+func (fs *faultFileSystem) OpenFile(name string, ioType IOType) (IOManager, error) {
+	ioManager, err := fs.FileSystem.OpenFile(name, ioType)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(name, fs.injector.path) {
+		return ioManager, nil
+	}
+	return fs.injector.wrap(fs.FileSystem, name, ioType, ioManager)
+}
@@ -0,0 +1,120 @@
+package fio
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFaultIO_Write(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "fault")
+	defer os.RemoveAll(dir)
+
+	injector := NewFaultInjector(dir)
+	fs := injector.FileSystem(NewFileSystem(StandardFileIOType))
+
+	fio, err := fs.OpenFile(filepath.Join(dir, "test.storage"), StandardFileIOType)
+	assert.Nil(t, err)
+	_, ok := fio.(*FaultIO)
+	assert.True(t, ok)
+
+	// short write
+	injector.FailWriteAfter(7)
+	n, err := fio.Write([]byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+	n, err = fio.Write([]byte("world"))
+	assert.Equal(t, ErrInjectedFault, err)
+	assert.Equal(t, 2, n)
+	n, err = fio.Write([]byte("!"))
+	assert.Equal(t, ErrInjectedFault, err)
+	assert.Equal(t, 0, n)
+
+	injector.Reset()
+	_, err = fio.Write([]byte("rld"))
+	assert.Nil(t, err)
+	buf := make([]byte, 10)
+	_, err = fio.Read(buf, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("helloworld"), buf)
+
+	// files not under path are not wrapped
+	other, err := fs.OpenFile(filepath.Join("/tmp", "fault-other.storage"), StandardFileIOType)
+	defer destroyFile(filepath.Join("/tmp", "fault-other.storage"))
+	assert.Nil(t, err)
+	_, ok = other.(*FaultIO)
+	assert.False(t, ok)
+
+	// io managers not opened by the file system of injector are not wrapped
+	plain, err := NewIOManager(filepath.Join(dir, "plain.storage"), StandardFileIOType)
+	assert.Nil(t, err)
+	_, ok = plain.(*FaultIO)
+	assert.False(t, ok)
+	assert.Nil(t, plain.Close())
+}
+
+func TestFaultIO_SyncAndRead(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "fault")
+	defer os.RemoveAll(dir)
+
+	injector := NewFaultInjector(dir)
+	fs := injector.FileSystem(NewFileSystem(StandardFileIOType))
+
+	fio, err := fs.OpenFile(filepath.Join(dir, "test.storage"), StandardFileIOType)
+	assert.Nil(t, err)
+	_, err = fio.Write([]byte("hello"))
+	assert.Nil(t, err)
+
+	injector.FailSyncAfter(1)
+	assert.Nil(t, fio.Sync())
+	assert.Equal(t, ErrInjectedFault, fio.Sync())
+
+	injector.FailReadAfter(3)
+	buf := make([]byte, 5)
+	n, err := fio.Read(buf, 0)
+	assert.Equal(t, ErrInjectedFault, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestFaultIO_Crash(t *testing.T) {
+	for _, ioType := range []IOType{StandardFileIOType, MemoryIOType} {
+		dir, _ := os.MkdirTemp("", "fault")
+		injector := NewFaultInjector(dir)
+		fs := injector.FileSystem(NewFileSystem(ioType))
+
+		fileName := filepath.Join(dir, "test.storage")
+		fio, err := fs.OpenFile(fileName, ioType)
+		assert.Nil(t, err)
+		_, err = fio.Write([]byte("hello"))
+		assert.Nil(t, err)
+		assert.Nil(t, fio.Sync())
+		_, err = fio.Write([]byte("world"))
+		assert.Nil(t, err)
+
+		// removed file is not restored by crash
+		removed, err := fs.OpenFile(filepath.Join(dir, "removed.storage"), ioType)
+		assert.Nil(t, err)
+		assert.Nil(t, removed.Close())
+		assert.Nil(t, fs.Remove(filepath.Join(dir, "removed.storage")))
+
+		assert.Nil(t, injector.Crash())
+		_, err = fio.Write([]byte("!"))
+		assert.Equal(t, ErrCrashed, err)
+		_, err = fs.OpenFile(fileName, ioType)
+		assert.Equal(t, ErrCrashed, err)
+
+		// unsynced bytes are dropped
+		injector.Reset()
+		fio, err = fs.OpenFile(fileName, ioType)
+		assert.Nil(t, err)
+		size, err := fio.Size()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(5), size)
+		_, err = fs.Stat(filepath.Join(dir, "removed.storage"))
+		assert.True(t, os.IsNotExist(err))
+
+		_ = fs.RemoveAll(dir)
+		_ = os.RemoveAll(dir)
+	}
+}
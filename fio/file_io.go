@@ -42,3 +42,8 @@ func (fio *FileIO) Size() (int64, error) {
 	}
 	return stat.Size(), nil
 }
+
+// Truncate file to size
+func (fio *FileIO) Truncate(size int64) error {
+	return fio.fd.Truncate(size)
+}
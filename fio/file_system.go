@@ -0,0 +1,67 @@
+package fio
+
+import (
+	"os"
+)
+
+// FileSystem files and directory operations of database
+type FileSystem interface {
+	// OpenFile open io manager of file in io type
+	OpenFile(name string, ioType IOType) (IOManager, error)
+
+	// Stat file or directory, error satisfies os.IsNotExist if it doesn't exist
+	Stat(name string) (os.FileInfo, error)
+
+	// ReadDir entries of directory sorted by name
+	ReadDir(name string) ([]os.DirEntry, error)
+
+	// MkdirAll create directory and its parents
+	MkdirAll(path string, perm os.FileMode) error
+
+	// Remove file or empty directory
+	Remove(name string) error
+
+	// RemoveAll remove path and all its children
+	RemoveAll(path string) error
+
+	// Rename file or directory
+	Rename(oldPath, newPath string) error
+}
+
+// NewFileSystem file system of io type, in memory io opened by NewIOManager shares one file system in process
+func NewFileSystem(ioType IOType) FileSystem {
+	if ioType == MemoryIOType {
+		return memFileSystem
+	}
+	return osFileSystem{}
+}
+
+type osFileSystem struct{}
+
+func (osFileSystem) OpenFile(name string, ioType IOType) (IOManager, error) {
+	return NewIOManager(name, ioType)
+}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (osFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFileSystem) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+func (osFileSystem) Rename(oldPath, newPath string) error {
+	return os.Rename(oldPath, newPath)
+}
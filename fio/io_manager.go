@@ -7,6 +7,8 @@ type IOType = byte
 const (
 	StandardFileIOType IOType = iota + 1
 	MMapIOType
+	MMapRWIOType
+	MemoryIOType
 )
 
 // IOManager interface for File IO
@@ -25,6 +27,9 @@ type IOManager interface {
 
 	// Size of a file
 	Size() (int64, error)
+
+	// Truncate file to size
+	Truncate(int64) error
 }
 
 // NewIOManager Initialize IOManager
@@ -34,6 +39,10 @@ func NewIOManager(fileName string, ioType IOType) (IOManager, error) {
 		return NewFileIOManager(fileName)
 	case MMapIOType:
 		return NewMMapIOManager(fileName)
+	case MMapRWIOType:
+		return NewMMapRWIOManager(fileName)
+	case MemoryIOType:
+		return NewMemoryIOManager(fileName)
 	default:
 		panic("unknown io type")
 	}
@@ -0,0 +1,312 @@
+package fio
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memFileSystem files of the in memory io managers opened by NewIOManager, they live until removed or process exits
+var memFileSystem = NewMemoryFileSystem()
+
+type memoryFile struct {
+	mu   *sync.RWMutex
+	data []byte
+}
+
+// MemoryIO io manager backed by byte slice, nothing is written to disk
+type MemoryIO struct {
+	file *memoryFile
+}
+
+func NewMemoryIOManager(fileName string) (*MemoryIO, error) {
+	return &MemoryIO{file: memFileSystem.openFile(fileName)}, nil
+}
+
+// Read byte from given offset
+func (mio *MemoryIO) Read(buf []byte, offset int64) (int, error) {
+	mio.file.mu.RLock()
+	defer mio.file.mu.RUnlock()
+
+	if offset < 0 || int64(len(mio.file.data)) < offset {
+		return 0, io.EOF
+	}
+	n := copy(buf, mio.file.data[offset:])
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write byte to the end of file
+func (mio *MemoryIO) Write(buf []byte) (int, error) {
+	mio.file.mu.Lock()
+	defer mio.file.mu.Unlock()
+
+	mio.file.data = append(mio.file.data, buf...)
+	return len(buf), nil
+}
+
+// Sync nothing to flush
+func (mio *MemoryIO) Sync() error {
+	return nil
+}
+
+// Close file, content is kept in memory file system
+func (mio *MemoryIO) Close() error {
+	return nil
+}
+
+// Size of a file
+func (mio *MemoryIO) Size() (int64, error) {
+	mio.file.mu.RLock()
+	defer mio.file.mu.RUnlock()
+
+	return int64(len(mio.file.data)), nil
+}
+
+// Truncate file to size
+func (mio *MemoryIO) Truncate(size int64) error {
+	mio.file.mu.Lock()
+	defer mio.file.mu.Unlock()
+
+	if size < int64(len(mio.file.data)) {
+		mio.file.data = mio.file.data[:size:size]
+	} else {
+		mio.file.data = append(mio.file.data, make([]byte, size-int64(len(mio.file.data)))...)
+	}
+	return nil
+}
+
+// MemoryFileSystem FileSystem of in memory io managers, files are keyed by cleaned path
+type MemoryFileSystem struct {
+	mu    *sync.RWMutex
+	files map[string]*memoryFile
+	dirs  map[string]struct{}
+}
+
+// NewMemoryFileSystem file system of its own, files are not shared with other file systems
+func NewMemoryFileSystem() *MemoryFileSystem {
+	return &MemoryFileSystem{
+		mu:    new(sync.RWMutex),
+		files: make(map[string]*memoryFile),
+		dirs:  make(map[string]struct{}),
+	}
+}
+
+// OpenFile open in memory io manager of file whatever io type is, nothing is written to disk
+func (mfs *MemoryFileSystem) OpenFile(name string, _ IOType) (IOManager, error) {
+	return &MemoryIO{file: mfs.openFile(name)}, nil
+}
+
+func (mfs *MemoryFileSystem) Stat(name string) (os.FileInfo, error) {
+	name = filepath.Clean(name)
+
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	if file, ok := mfs.files[name]; ok {
+		return newMemoryFileInfo(name, file), nil
+	}
+	if mfs.isDirLocked(name) {
+		return newMemoryFileInfo(name, nil), nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (mfs *MemoryFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	name = filepath.Clean(name)
+
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	if !mfs.isDirLocked(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	children := make(map[string]os.DirEntry)
+	for fileName, file := range mfs.files {
+		if filepath.Dir(fileName) == name {
+			children[fileName] = fs.FileInfoToDirEntry(newMemoryFileInfo(fileName, file))
+		}
+	}
+	for dir := range mfs.dirs {
+		if dir != name && filepath.Dir(dir) == name {
+			children[dir] = fs.FileInfoToDirEntry(newMemoryFileInfo(dir, nil))
+		}
+	}
+
+	entries := make([]os.DirEntry, 0, len(children))
+	for _, entry := range children {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (mfs *MemoryFileSystem) MkdirAll(path string, _ os.FileMode) error {
+	path = filepath.Clean(path)
+
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+
+	for dir := path; ; dir = filepath.Dir(dir) {
+		mfs.dirs[dir] = struct{}{}
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	return nil
+}
+
+func (mfs *MemoryFileSystem) Remove(name string) error {
+	name = filepath.Clean(name)
+
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+
+	if _, ok := mfs.files[name]; ok {
+		delete(mfs.files, name)
+		return nil
+	}
+	if _, ok := mfs.dirs[name]; ok {
+		if mfs.hasChildrenLocked(name) {
+			return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrExist}
+		}
+		delete(mfs.dirs, name)
+		return nil
+	}
+	return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+}
+
+func (mfs *MemoryFileSystem) RemoveAll(path string) error {
+	path = filepath.Clean(path)
+
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+
+	for fileName := range mfs.files {
+		if isUnderPath(fileName, path) {
+			delete(mfs.files, fileName)
+		}
+	}
+	for dir := range mfs.dirs {
+		if isUnderPath(dir, path) {
+			delete(mfs.dirs, dir)
+		}
+	}
+	return nil
+}
+
+func (mfs *MemoryFileSystem) Rename(oldPath, newPath string) error {
+	oldPath, newPath = filepath.Clean(oldPath), filepath.Clean(newPath)
+
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+
+	if !mfs.isDirLocked(oldPath) {
+		file, ok := mfs.files[oldPath]
+		if !ok {
+			return &fs.PathError{Op: "rename", Path: oldPath, Err: fs.ErrNotExist}
+		}
+		delete(mfs.files, oldPath)
+		mfs.files[newPath] = file
+		return nil
+	}
+
+	for fileName, file := range mfs.files {
+		if isUnderPath(fileName, oldPath) {
+			delete(mfs.files, fileName)
+			mfs.files[newPath+strings.TrimPrefix(fileName, oldPath)] = file
+		}
+	}
+	for dir := range mfs.dirs {
+		if isUnderPath(dir, oldPath) {
+			delete(mfs.dirs, dir)
+			mfs.dirs[newPath+strings.TrimPrefix(dir, oldPath)] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// openFile get file by name, file is created if not exists
+func (mfs *MemoryFileSystem) openFile(name string) *memoryFile {
+	name = filepath.Clean(name)
+
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+
+	file, ok := mfs.files[name]
+	if !ok {
+		file = &memoryFile{mu: new(sync.RWMutex)}
+		mfs.files[name] = file
+	}
+	return file
+}
+
+// isDirLocked directory exists if it's created or has children, must hold lock
+func (mfs *MemoryFileSystem) isDirLocked(name string) bool {
+	if _, ok := mfs.dirs[name]; ok {
+		return true
+	}
+	return mfs.hasChildrenLocked(name)
+}
+
+func (mfs *MemoryFileSystem) hasChildrenLocked(name string) bool {
+	for fileName := range mfs.files {
+		if isUnderPath(fileName, name) && fileName != name {
+			return true
+		}
+	}
+	for dir := range mfs.dirs {
+		if isUnderPath(dir, name) && dir != name {
+			return true
+		}
+	}
+	return false
+}
+
+// isUnderPath check if name is path itself or under it
+func isUnderPath(name, path string) bool {
+	return name == path || strings.HasPrefix(name, strings.TrimSuffix(path, string(filepath.Separator))+string(filepath.Separator))
+}
+
+type memoryFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func newMemoryFileInfo(name string, file *memoryFile) *memoryFileInfo {
+	info := &memoryFileInfo{name: filepath.Base(name), dir: file == nil}
+	if file != nil {
+		file.mu.RLock()
+		info.size = int64(len(file.data))
+		file.mu.RUnlock()
+	}
+	return info
+}
+
+func (fi *memoryFileInfo) Name() string { return fi.name }
+
+func (fi *memoryFileInfo) Size() int64 { return fi.size }
+
+func (fi *memoryFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | os.ModePerm
+	}
+	return FileDataPermission
+}
+
+func (fi *memoryFileInfo) ModTime() time.Time { return time.Time{} }
+
+func (fi *memoryFileInfo) IsDir() bool { return fi.dir }
+
+func (fi *memoryFileInfo) Sys() any { return nil }
@@ -0,0 +1,118 @@
+package fio
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryIOManager_ReadWrite(t *testing.T) {
+	dir := filepath.Join("/memory", "io")
+	fs := NewFileSystem(MemoryIOType)
+	defer fs.RemoveAll(dir)
+
+	mio, err := NewIOManager(filepath.Join(dir, "test.storage"), MemoryIOType)
+	assert.Nil(t, err)
+
+	n, err := mio.Write([]byte("key1"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	_, err = mio.Write([]byte("key2"))
+	assert.Nil(t, err)
+
+	b := make([]byte, 4)
+	n, err = mio.Read(b, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("key2"), b)
+
+	_, err = mio.Read(b, 6)
+	assert.Equal(t, io.EOF, err)
+
+	size, err := mio.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), size)
+
+	// content is kept after close
+	assert.Nil(t, mio.Sync())
+	assert.Nil(t, mio.Close())
+	mio, err = NewIOManager(filepath.Join(dir, "test.storage"), MemoryIOType)
+	assert.Nil(t, err)
+	size, err = mio.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(8), size)
+
+	assert.Nil(t, mio.Truncate(4))
+	size, err = mio.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), size)
+
+	// nothing is written to disk
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMemoryFileSystem(t *testing.T) {
+	dir := filepath.Join("/memory", "fs")
+	fs := NewFileSystem(MemoryIOType)
+	defer fs.RemoveAll(dir)
+
+	_, err := fs.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, fs.MkdirAll(dir, os.ModePerm))
+	info, err := fs.Stat(dir)
+	assert.Nil(t, err)
+	assert.True(t, info.IsDir())
+
+	for _, name := range []string{"b.data", "a.data"} {
+		mio, err := NewMemoryIOManager(filepath.Join(dir, name))
+		assert.Nil(t, err)
+		_, err = mio.Write([]byte(name))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, fs.MkdirAll(filepath.Join(dir, "sub"), os.ModePerm))
+
+	entries, err := fs.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "a.data", entries[0].Name())
+	assert.Equal(t, "b.data", entries[1].Name())
+	assert.True(t, entries[2].IsDir())
+	info, err = entries[0].Info()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), info.Size())
+
+	assert.Nil(t, fs.Rename(filepath.Join(dir, "a.data"), filepath.Join(dir, "sub", "c.data")))
+	_, err = fs.Stat(filepath.Join(dir, "a.data"))
+	assert.True(t, os.IsNotExist(err))
+	info, err = fs.Stat(filepath.Join(dir, "sub", "c.data"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), info.Size())
+
+	assert.NotNil(t, fs.Remove(filepath.Join(dir, "sub")))
+	assert.Nil(t, fs.RemoveAll(filepath.Join(dir, "sub")))
+	entries, err = fs.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestNewMemoryFileSystem(t *testing.T) {
+	dir := filepath.Join("/memory", "own")
+	fs1, fs2 := NewMemoryFileSystem(), NewMemoryFileSystem()
+
+	mio, err := fs1.OpenFile(filepath.Join(dir, "test.storage"), MemoryIOType)
+	assert.Nil(t, err)
+	_, err = mio.Write([]byte("hello"))
+	assert.Nil(t, err)
+
+	// files are not shared with other file systems
+	info, err := fs1.Stat(filepath.Join(dir, "test.storage"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), info.Size())
+	_, err = fs2.Stat(filepath.Join(dir, "test.storage"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = NewFileSystem(MemoryIOType).Stat(filepath.Join(dir, "test.storage"))
+	assert.True(t, os.IsNotExist(err))
+}
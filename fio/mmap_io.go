@@ -1,31 +1,80 @@
 package fio
 
 import (
-	"golang.org/x/exp/mmap"
+	"errors"
+	"io"
 	"os"
+	"sync"
 )
 
+var (
+	errInvalidMMapOffset = errors.New("mmap: invalid offset")
+	errMMapClosed        = errors.New("mmap: file is closed")
+)
+
+// Viewer io manager which can expose its content without copying
+type Viewer interface {
+	// View n bytes from given offset, the returned slice is valid until release is called,
+	// closing io manager is deferred until all the views are released
+	View(n int, offset int64) (view []byte, release func(), err error)
+}
+
 type MMapIO struct {
-	// reader in mmap
-	readerAt *mmap.ReaderAt
+	mu     *sync.Mutex
+	data   []byte // read only mapping of the whole file
+	views  int    // views not released yet
+	closed bool
 }
 
 func NewMMapIOManager(fileName string) (*MMapIO, error) {
-	_, err := os.OpenFile(fileName, os.O_RDONLY|os.O_CREATE, FileDataPermission)
+	fd, err := os.OpenFile(fileName, os.O_RDONLY|os.O_CREATE, FileDataPermission)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	stat, err := fd.Stat()
 	if err != nil {
 		return nil, err
 	}
 
-	readerAt, err := mmap.Open(fileName)
+	data, err := mmapFile(fd, int(stat.Size()), false)
 	if err != nil {
 		return nil, err
 	}
-	return &MMapIO{readerAt: readerAt}, nil
+	return &MMapIO{mu: new(sync.Mutex), data: data}, nil
 }
 
 // Read byte from given offset
 func (mm *MMapIO) Read(key []byte, offset int64) (int, error) {
-	return mm.readerAt.ReadAt(key, offset)
+	if offset < 0 || int64(len(mm.data)) < offset {
+		return 0, errInvalidMMapOffset
+	}
+	n := copy(key, mm.data[offset:])
+	if n < len(key) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// View n bytes from given offset without copying, mapping is kept until release is called
+func (mm *MMapIO) View(n int, offset int64) ([]byte, func(), error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	if mm.closed {
+		return nil, nil, errMMapClosed
+	}
+	if offset < 0 || n < 0 || int64(len(mm.data)) < offset+int64(n) {
+		return nil, nil, io.EOF
+	}
+	mm.views++
+
+	var once sync.Once
+	release := func() {
+		once.Do(mm.releaseView)
+	}
+	return mm.data[offset : offset+int64(n) : offset+int64(n)], release, nil
 }
 
 // Write byte to file
@@ -38,12 +87,39 @@ func (mm *MMapIO) Sync() error {
 	panic("not implemented")
 }
 
-// Close To close file
+// Close To close file, file is unmapped once all the views are released
 func (mm *MMapIO) Close() error {
-	return mm.readerAt.Close()
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.closed = true
+	if mm.views > 0 {
+		return nil
+	}
+	return mm.unmapLocked()
 }
 
 // Size of a file
 func (mm *MMapIO) Size() (int64, error) {
-	return int64(mm.readerAt.Len()), nil
+	return int64(len(mm.data)), nil
+}
+
+// Truncate file to size
+func (mm *MMapIO) Truncate(int64) error {
+	panic("not implemented")
+}
+
+func (mm *MMapIO) releaseView() {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	if mm.views--; mm.views == 0 && mm.closed {
+		_ = mm.unmapLocked()
+	}
+}
+
+func (mm *MMapIO) unmapLocked() error {
+	data := mm.data
+	mm.data = nil
+	return munmapFile(data)
 }
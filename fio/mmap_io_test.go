@@ -72,3 +72,35 @@ func TestMMapIOManager_Close(t *testing.T) {
 	err = mmapIO.Close()
 	assert.Nil(t, err)
 }
+
+func TestMMapIOManager_View(t *testing.T) {
+	filename := filepath.Join("/tmp", "mmap.storage")
+	defer destroyFile(filename)
+
+	fio, err := NewFileIOManager(filename)
+	assert.Nil(t, err)
+	_, err = fio.Write([]byte("hello world"))
+	assert.Nil(t, err)
+
+	mmapIO, err := NewMMapIOManager(filename)
+	assert.Nil(t, err)
+
+	view, release, err := mmapIO.View(5, 6)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("world"), view)
+	assert.Equal(t, 5, cap(view))
+
+	_, _, err = mmapIO.View(6, 6)
+	assert.Equal(t, io.EOF, err)
+
+	// file is unmapped after the view is released
+	err = mmapIO.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("world"), view)
+	_, _, err = mmapIO.View(5, 6)
+	assert.Equal(t, errMMapClosed, err)
+	release()
+	release()
+	assert.Nil(t, mmapIO.data)
+	assert.Equal(t, 0, mmapIO.views)
+}
@@ -0,0 +1,25 @@
+//go:build !unix
+
+package fio
+
+import (
+	"errors"
+	"os"
+)
+
+var errMMapNotSupported = errors.New("mmap: not supported on this platform")
+
+func mmapFile(fd *os.File, size int, writable bool) ([]byte, error) {
+	if size == 0 {
+		return nil, nil
+	}
+	return nil, errMMapNotSupported
+}
+
+func munmapFile(data []byte) error {
+	return nil
+}
+
+func msyncFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,150 @@
+package fio
+
+import (
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// file is pre-sized to this capacity when it's opened for mmap writes
+	mmapInitialCapacity = 1024 * 1024
+	// max bytes added to capacity each time the mapping grows
+	mmapMaxGrowSize = 64 * 1024 * 1024
+)
+
+// MMapRWIO write through a shared writable mapping, file is pre-sized to capacity and grown on demand,
+// the real size is tracked in memory and file is truncated to it on close
+type MMapRWIO struct {
+	mu   *sync.RWMutex
+	fd   *os.File
+	data []byte // mapping of the whole capacity
+	size int64  // bytes written
+
+	syncedCapacity *atomic.Int64 // file size made durable by the last sync
+}
+
+func NewMMapRWIOManager(fileName string) (*MMapRWIO, error) {
+	fd, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, FileDataPermission)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := fd.Stat()
+	if err != nil {
+		_ = fd.Close()
+		return nil, err
+	}
+
+	mm := &MMapRWIO{mu: new(sync.RWMutex), fd: fd, size: stat.Size(), syncedCapacity: new(atomic.Int64)}
+	if err := mm.grow(max(stat.Size(), mmapInitialCapacity)); err != nil {
+		_ = fd.Close()
+		return nil, err
+	}
+	return mm, nil
+}
+
+// Read byte from given offset
+func (mm *MMapRWIO) Read(buf []byte, offset int64) (int, error) {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	if offset < 0 || mm.size < offset {
+		return 0, errInvalidMMapOffset
+	}
+	n := copy(buf, mm.data[offset:mm.size])
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write byte to the end of file, mapping is grown if capacity is not enough
+func (mm *MMapRWIO) Write(buf []byte) (int, error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	if need := mm.size + int64(len(buf)); need > int64(len(mm.data)) {
+		capacity := int64(len(mm.data)) + min(max(int64(len(mm.data)), mmapInitialCapacity), mmapMaxGrowSize)
+		if err := mm.grow(max(capacity, need)); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(mm.data[mm.size:], buf)
+	mm.size += int64(n)
+	return n, nil
+}
+
+// Sync Flush written bytes to disk by msync, and file size by fsync if file grows since the last sync
+func (mm *MMapRWIO) Sync() error {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	if err := msyncFile(mm.data[:mm.size]); err != nil {
+		return err
+	}
+	// msync doesn't flush metadata, the grown part is lost after crash without it
+	if capacity := int64(len(mm.data)); mm.syncedCapacity.Load() != capacity {
+		if err := mm.fd.Sync(); err != nil {
+			return err
+		}
+		mm.syncedCapacity.Store(capacity)
+	}
+	return nil
+}
+
+// Close To close file, file is truncated to the written size
+func (mm *MMapRWIO) Close() error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	if err := munmapFile(mm.data); err != nil {
+		return err
+	}
+	mm.data = nil
+	if err := mm.fd.Truncate(mm.size); err != nil {
+		return err
+	}
+	return mm.fd.Close()
+}
+
+// Size of written bytes
+func (mm *MMapRWIO) Size() (int64, error) {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+
+	return mm.size, nil
+}
+
+// Truncate written bytes to size, the truncated bytes are zeroed but capacity is kept
+func (mm *MMapRWIO) Truncate(size int64) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	if size < 0 || size > mm.size {
+		return errInvalidMMapOffset
+	}
+	clear(mm.data[size:mm.size])
+	mm.size = size
+	return nil
+}
+
+// grow file to capacity and map it again, must hold lock
+func (mm *MMapRWIO) grow(capacity int64) error {
+	if err := munmapFile(mm.data); err != nil {
+		return err
+	}
+	mm.data = nil
+
+	if err := mm.fd.Truncate(capacity); err != nil {
+		return err
+	}
+	data, err := mmapFile(mm.fd, int(capacity), true)
+	if err != nil {
+		return err
+	}
+	mm.data = data
+	return nil
+}
@@ -0,0 +1,100 @@
+package fio
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMMapRWIOManager_Write(t *testing.T) {
+	filename := filepath.Join("/tmp", "mmap-rw.storage")
+	defer destroyFile(filename)
+
+	mmapIO, err := NewMMapRWIOManager(filename)
+	assert.Nil(t, err)
+
+	// file is pre-sized
+	stat, err := os.Stat(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(mmapInitialCapacity), stat.Size())
+
+	n, err := mmapIO.Write([]byte("hello "))
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+
+	// grow mapping
+	val := bytes.Repeat([]byte("a"), mmapInitialCapacity)
+	n, err = mmapIO.Write(val)
+	assert.Nil(t, err)
+	assert.Equal(t, len(val), n)
+
+	size, err := mmapIO.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6+len(val)), size)
+
+	buf := make([]byte, 7)
+	n, err = mmapIO.Read(buf, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello a"), buf)
+
+	_, err = mmapIO.Read(buf, size-1)
+	assert.Equal(t, io.EOF, err)
+
+	err = mmapIO.Sync()
+	assert.Nil(t, err)
+	// grown file size is synced
+	assert.Equal(t, int64(len(mmapIO.data)), mmapIO.syncedCapacity.Load())
+	assert.Greater(t, mmapIO.syncedCapacity.Load(), int64(mmapInitialCapacity))
+
+	// file is truncated to written size on close
+	err = mmapIO.Close()
+	assert.Nil(t, err)
+	stat, err = os.Stat(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, size, stat.Size())
+
+	// reopen and append
+	mmapIO, err = NewMMapRWIOManager(filename)
+	assert.Nil(t, err)
+	_, err = mmapIO.Write([]byte("world"))
+	assert.Nil(t, err)
+	buf = make([]byte, 5)
+	_, err = mmapIO.Read(buf, size)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("world"), buf)
+	err = mmapIO.Close()
+	assert.Nil(t, err)
+}
+
+func TestMMapRWIOManager_Truncate(t *testing.T) {
+	filename := filepath.Join("/tmp", "mmap-rw.storage")
+	defer destroyFile(filename)
+
+	mmapIO, err := NewMMapRWIOManager(filename)
+	assert.Nil(t, err)
+
+	_, err = mmapIO.Write([]byte("hello world"))
+	assert.Nil(t, err)
+
+	err = mmapIO.Truncate(5)
+	assert.Nil(t, err)
+	size, err := mmapIO.Size()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), size)
+
+	err = mmapIO.Truncate(6)
+	assert.NotNil(t, err)
+
+	_, err = mmapIO.Write([]byte("!"))
+	assert.Nil(t, err)
+	buf := make([]byte, 6)
+	_, err = mmapIO.Read(buf, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello!"), buf)
+
+	err = mmapIO.Close()
+	assert.Nil(t, err)
+}
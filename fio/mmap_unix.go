@@ -0,0 +1,36 @@
+//go:build unix
+
+package fio
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapFile map size bytes of file into memory, empty file is mapped to nil
+func mmapFile(fd *os.File, size int, writable bool) ([]byte, error) {
+	if size == 0 {
+		return nil, nil
+	}
+
+	prot := unix.PROT_READ
+	if writable {
+		prot |= unix.PROT_WRITE
+	}
+	return unix.Mmap(int(fd.Fd()), 0, size, prot, unix.MAP_SHARED)
+}
+
+func munmapFile(data []byte) error {
+	if data == nil {
+		return nil
+	}
+	return unix.Munmap(data)
+}
+
+func msyncFile(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return unix.Msync(data, unix.MS_SYNC)
+}
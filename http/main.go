@@ -14,6 +14,7 @@ func init() {
 	configs := bitcask.DefaultConfig
 	dir, _ := os.MkdirTemp("", "bitcask_test_http")
 	configs.DirPath = dir
+	configs.EnableMetrics = true
 
 	var err error
 	database, err = bitcask.OpenDatabase(configs)
@@ -29,6 +30,7 @@ func main() {
 	http.HandleFunc("/delete", handleDelete)
 	http.HandleFunc("/list-keys", HandleListKeys)
 	http.HandleFunc("/stats", HandleStats)
+	http.Handle("/metrics", database.MetricsHandler())
 
 	_ = http.ListenAndServe(":8080", nil)
 }
@@ -2,21 +2,21 @@ package index
 
 import (
 	"bitcask-go/storage"
-	"bytes"
-	art "github.com/plar/go-adaptive-radix-tree"
-	"sort"
+	"errors"
+	goart "github.com/plar/go-adaptive-radix-tree"
+	"math"
 	"sync"
 )
 
 type AdaptiveRadixTree struct {
-	tree art.Tree
+	tree goart.Tree
 	mu   *sync.RWMutex
 }
 
 // NewAdaptiveRadixTree Initialize AdaptiveRadixTree
 func NewAdaptiveRadixTree() *AdaptiveRadixTree {
 	return &AdaptiveRadixTree{
-		tree: art.New(),
+		tree: goart.New(),
 		mu:   new(sync.RWMutex),
 	}
 }
@@ -65,14 +65,13 @@ func (art *AdaptiveRadixTree) Delete(key []byte) (*storage.LogRecordPos, bool) {
 	return oldItem.(*storage.LogRecordPos), deleted
 }
 
+// Iterator walks the tree lazily in both directions
 func (art *AdaptiveRadixTree) Iterator(reverse bool) Iterator {
 	if art.tree == nil {
 		return nil
 	}
 
-	art.mu.RLock()
-	defer art.mu.RUnlock()
-	return newArtIterator(art.tree, reverse)
+	return newArtIterator(art, reverse)
 }
 
 func (art *AdaptiveRadixTree) Size() int {
@@ -85,84 +84,272 @@ func (art *AdaptiveRadixTree) Close() error {
 	return nil
 }
 
-type ArtIterator struct {
-	currentIndex int
-	reverse      bool
-	values       []*Item
-}
-
-func newArtIterator(tree art.Tree, reverse bool) *ArtIterator {
-	values := make([]*Item, tree.Size())
-
-	var index int
-	if reverse {
-		index = tree.Size() - 1
-	}
-
-	getValue := func(node art.Node) bool {
-		item := &Item{
-			key: node.Key(),
-			pos: node.Value().(*storage.LogRecordPos),
-		}
-		values[index] = item
-
-		if reverse {
-			index--
-		} else {
-			index++
-		}
+const (
+	// items loaded by iterator at a time when it doesn't follow the native iterator, batch is doubled
+	// while iterating on so the cost of finding the position is amortized
+	artIteratorBatchSize    = 128
+	artIteratorMaxBatchSize = 8192
+	// subtree with at most so many keys is collected and reversed when iterating in descending order
+	artSubtreeSize = 64
+)
 
-		return true
-	}
+// artCallback called for keys in iterating order, iteration stops once it returns false
+type artCallback func(key []byte, pos *storage.LogRecordPos) bool
 
-	tree.ForEach(getValue)
+// ArtIterator walk the tree lazily, the read lock is only held while moving. Forward iterator follows the
+// native iterator from the first key, seeking, reverse iterating or restarting after the tree is modified load
+// items in batches by walking the subtrees of key prefixes, so keys before the position are never visited
+type ArtIterator struct {
+	art       *AdaptiveRadixTree
+	reverse   bool
+	iter      goart.Iterator // native iterator, nil if items are loaded in batches
+	items     []*Item        // items loaded after current
+	batchSize int            // items of the next batch
+	exhausted bool           // no more items after the loaded ones
+	current   *Item
+}
 
-	return &ArtIterator{
-		currentIndex: 0,
-		reverse:      reverse,
-		values:       values,
-	}
+func newArtIterator(art *AdaptiveRadixTree, reverse bool) *ArtIterator {
+	ai := &ArtIterator{art: art, reverse: reverse}
+	ai.Rewind()
+	return ai
 }
 
 // Rewind set iterator to first element
 func (ai *ArtIterator) Rewind() {
-	ai.currentIndex = 0
-}
+	ai.art.mu.RLock()
+	defer ai.art.mu.RUnlock()
 
-// Seek the first element less/greater than key
-func (ai *ArtIterator) Seek(key []byte) {
+	ai.batchSize = artIteratorBatchSize
 	if ai.reverse {
-		ai.currentIndex = sort.Search(len(ai.values), func(i int) bool {
-			return bytes.Compare(ai.values[i].key, key) <= 0
-		})
-	} else {
-		ai.currentIndex = sort.Search(len(ai.values), func(i int) bool {
-			return bytes.Compare(ai.values[i].key, key) >= 0
-		})
+		ai.loadLocked(nil, true)
+		return
 	}
+	ai.iter = ai.art.tree.Iterator()
+	ai.items = nil
+	ai.nextLocked()
+}
+
+// Seek the first element less/greater than or equal to key
+func (ai *ArtIterator) Seek(key []byte) {
+	ai.art.mu.RLock()
+	defer ai.art.mu.RUnlock()
+
+	ai.batchSize = artIteratorBatchSize
+	ai.loadLocked(append([]byte{}, key...), true)
 }
 
 // Next go to next element
 func (ai *ArtIterator) Next() {
-	ai.currentIndex++
+	if ai.current == nil {
+		return
+	}
+
+	ai.art.mu.RLock()
+	defer ai.art.mu.RUnlock()
+
+	ai.nextLocked()
 }
 
 // Valid check if has next element
 func (ai *ArtIterator) Valid() bool {
-	return ai.currentIndex < len(ai.values)
+	return ai.current != nil
 }
 
 // Key current element key
 func (ai *ArtIterator) Key() []byte {
-	return ai.values[ai.currentIndex].key
+	return ai.current.key
 }
 
 // Value current element value
 func (ai *ArtIterator) Value() *storage.LogRecordPos {
-	return ai.values[ai.currentIndex].pos
+	return ai.current.pos
 }
 
 // Close iterator, free resource
 func (ai *ArtIterator) Close() {
-	ai.values = nil
+	ai.iter = nil
+	ai.items = nil
+	ai.current = nil
+}
+
+func (ai *ArtIterator) nextLocked() {
+	if ai.iter == nil {
+		if len(ai.items) == 0 && !ai.exhausted && ai.current != nil {
+			ai.loadLocked(ai.current.key, false)
+			return
+		}
+		ai.popLocked()
+		return
+	}
+
+	if !ai.iter.HasNext() {
+		ai.current = nil
+		return
+	}
+	node, err := ai.iter.Next()
+	if errors.Is(err, goart.ErrConcurrentModification) && ai.current != nil {
+		// continue after the current key
+		ai.loadLocked(ai.current.key, false)
+		return
+	}
+	if err != nil {
+		ai.current = nil
+		return
+	}
+	ai.current = &Item{key: node.Key(), pos: node.Value().(*storage.LogRecordPos)}
+}
+
+// loadLocked load a batch of items from key in iterating order and move to the first one,
+// nil key loads from the last key for reverse iterator
+func (ai *ArtIterator) loadLocked(key []byte, inclusive bool) {
+	ai.iter = nil
+	batchSize := ai.batchSize
+	ai.batchSize = min(2*batchSize, artIteratorMaxBatchSize)
+	ai.items = make([]*Item, 0, batchSize)
+	collect := func(key []byte, pos *storage.LogRecordPos) bool {
+		ai.items = append(ai.items, &Item{key: key, pos: pos})
+		return len(ai.items) < batchSize
+	}
+
+	tree := ai.art.tree
+	switch {
+	case ai.reverse && key == nil:
+		descendPrefix(tree, nil, collect)
+	case ai.reverse:
+		descendFrom(tree, key, inclusive, collect)
+	default:
+		ascendFrom(tree, key, inclusive, collect)
+	}
+	ai.exhausted = len(ai.items) < batchSize
+	ai.popLocked()
+}
+
+func (ai *ArtIterator) popLocked() {
+	if len(ai.items) == 0 {
+		ai.current = nil
+		return
+	}
+	ai.current = ai.items[0]
+	ai.items = ai.items[1:]
+}
+
+// forEachPrefix call fn for keys having prefix in ascending order, return false if fn stops it
+func forEachPrefix(tree goart.Tree, prefix []byte, fn artCallback) bool {
+	cont := true
+	callback := func(node goart.Node) bool {
+		// ForEachPrefix passes inner nodes too
+		if node.Kind() != goart.Leaf {
+			return true
+		}
+		cont = fn(node.Key(), node.Value().(*storage.LogRecordPos))
+		return cont
+	}
+	// ART doesn't match any key with empty prefix
+	if len(prefix) == 0 {
+		tree.ForEach(callback)
+	} else {
+		tree.ForEachPrefix(prefix, callback)
+	}
+	return cont
+}
+
+func hasPrefix(tree goart.Tree, prefix []byte) bool {
+	return !forEachPrefix(tree, prefix, func([]byte, *storage.LogRecordPos) bool {
+		return false
+	})
+}
+
+// ascendFrom call fn for keys greater than key, or equal to it if inclusive, in ascending order until fn returns false.
+// Keys having key as prefix come first, then the subtrees of prefixes of key followed by a greater byte,
+// from the longest prefix to the shortest
+func ascendFrom(tree goart.Tree, key []byte, inclusive bool, fn artCallback) bool {
+	if !inclusive {
+		// the least key greater than key
+		key = append(key[:len(key):len(key)], 0)
+	}
+	if !forEachPrefix(tree, key, fn) {
+		return false
+	}
+
+	prefix := append([]byte{}, key...)
+	for i := len(key) - 1; i >= 0; i-- {
+		if !hasPrefix(tree, key[:i]) {
+			continue
+		}
+		for b := int(key[i]) + 1; b <= math.MaxUint8; b++ {
+			prefix[i] = byte(b)
+			if !forEachPrefix(tree, prefix[:i+1], fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// descendFrom call fn for keys less than key, or equal to it if inclusive, in descending order until fn returns false.
+// The subtrees of prefixes of key followed by a less byte are visited from the longest prefix to the shortest,
+// and each prefix itself follows them
+func descendFrom(tree goart.Tree, key []byte, inclusive bool, fn artCallback) bool {
+	if inclusive {
+		if pos, found := tree.Search(key); found && !fn(key, pos.(*storage.LogRecordPos)) {
+			return false
+		}
+	}
+
+	prefix := append([]byte{}, key...)
+	for i := len(key) - 1; i >= 0; i-- {
+		if !hasPrefix(tree, key[:i]) {
+			continue
+		}
+		for b := int(key[i]) - 1; b >= 0; b-- {
+			prefix[i] = byte(b)
+			if !descendPrefix(tree, prefix[:i+1], fn) {
+				return false
+			}
+		}
+		if i == 0 {
+			break
+		}
+		if pos, found := tree.Search(key[:i]); found && !fn(append([]byte{}, key[:i]...), pos.(*storage.LogRecordPos)) {
+			return false
+		}
+	}
+	return true
+}
+
+// descendPrefix call fn for keys having prefix in descending order until fn returns false.
+// Small subtree is collected and reversed, larger one is split by the next byte
+func descendPrefix(tree goart.Tree, prefix []byte, fn artCallback) bool {
+	items := make([]*Item, 0, artSubtreeSize)
+	complete := forEachPrefix(tree, prefix, func(key []byte, pos *storage.LogRecordPos) bool {
+		if len(items) == artSubtreeSize {
+			return false
+		}
+		items = append(items, &Item{key: key, pos: pos})
+		return true
+	})
+	if complete {
+		for i := len(items) - 1; i >= 0; i-- {
+			if !fn(items[i].key, items[i].pos) {
+				return false
+			}
+		}
+		return true
+	}
+
+	child := append(prefix[:len(prefix):len(prefix)], 0)
+	for b := math.MaxUint8; b >= 0; b-- {
+		child[len(prefix)] = byte(b)
+		if !descendPrefix(tree, child, fn) {
+			return false
+		}
+	}
+	// prefix itself is the least key having it
+	if len(prefix) > 0 {
+		if pos, found := tree.Search(prefix); found {
+			return fn(append([]byte{}, prefix...), pos.(*storage.LogRecordPos))
+		}
+	}
+	return true
 }
@@ -2,7 +2,10 @@ package index
 
 import (
 	"bitcask-go/storage"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
 	"testing"
 )
 
@@ -173,3 +176,91 @@ func TestAdaptiveRadixTree_Iterator_TestSeek(t *testing.T) {
 		assert.Equal(t, value1, iter2.Value())
 	}
 }
+
+func TestAdaptiveRadixTree_Iterator_ConcurrentModification(t *testing.T) {
+	art := NewAdaptiveRadixTree()
+	for i := 0; i < 100; i++ {
+		art.Put([]byte(fmt.Sprintf("key-%03d", i)), &storage.LogRecordPos{Fid: 1, Offset: int64(i)})
+	}
+
+	iter := art.Iterator(false)
+	var keys []string
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+		// modify tree while iterating, iterator continues from the current key
+		if len(keys) == 10 {
+			art.Delete([]byte("key-005"))
+			art.Delete([]byte("key-050"))
+			art.Put([]byte("key-100"), &storage.LogRecordPos{Fid: 1, Offset: 100})
+		}
+	}
+	iter.Close()
+
+	assert.Equal(t, 100, len(keys))
+	assert.Equal(t, "key-009", keys[9])
+	assert.Equal(t, "key-049", keys[49])
+	assert.Equal(t, "key-051", keys[50])
+	assert.Equal(t, "key-100", keys[99])
+}
+
+func TestAdaptiveRadixTree_Iterator_SeekRandomKeys(t *testing.T) {
+	art := NewAdaptiveRadixTree()
+	random := rand.New(rand.NewSource(1))
+	// short keys of few bytes, many of them are prefixes of others
+	alphabet := []byte{0x00, 0x01, 'a', 'b', 0x7f, 0xfe, 0xff}
+	randomKey := func() []byte {
+		key := make([]byte, 1+random.Intn(5))
+		for i := range key {
+			key[i] = alphabet[random.Intn(len(alphabet))]
+		}
+		return key
+	}
+
+	keys := make(map[string]bool)
+	for i := 0; i < 3000; i++ {
+		key := randomKey()
+		keys[string(key)] = true
+		art.Put(key, &storage.LogRecordPos{Fid: 1, Offset: int64(i)})
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	assert.Equal(t, len(sorted), art.Size())
+
+	collect := func(iter Iterator) []string {
+		var collected []string
+		for ; iter.Valid(); iter.Next() {
+			collected = append(collected, string(iter.Key()))
+		}
+		return collected
+	}
+
+	forward := art.Iterator(false)
+	reverse := art.Iterator(true)
+	defer forward.Close()
+	defer reverse.Close()
+
+	reversed := make([]string, len(sorted))
+	for i, key := range sorted {
+		reversed[len(sorted)-1-i] = key
+	}
+	reverse.Rewind()
+	assert.Equal(t, reversed, collect(reverse))
+
+	for i := 0; i < 50; i++ {
+		seek := randomKey()
+		start := sort.SearchStrings(sorted, string(seek))
+		forward.Seek(seek)
+		assert.Equal(t, sorted[start:], collect(forward), "seek %q", seek)
+
+		// reverse iterator starts from the last key less than or equal to seek
+		end := start
+		if end < len(sorted) && sorted[end] == string(seek) {
+			end++
+		}
+		reverse.Seek(seek)
+		assert.Equal(t, reversed[len(sorted)-end:], collect(reverse), "seek %q", seek)
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"path/filepath"
 )
 
-const bPlusTreeFileName = "bplustree-index"
+const BPlusTreeFileName = "bplustree-index"
 
 var BPTreeBucketName = []byte("bitcask-index")
 
@@ -19,7 +19,7 @@ func NewBPlusTree(dirPath string, syncWrites bool) *BPlusTree {
 	options := bbolt.DefaultOptions
 	options.NoSync = syncWrites
 
-	bPlusTree, err := bbolt.Open(filepath.Join(dirPath, bPlusTreeFileName), 0644, options)
+	bPlusTree, err := bbolt.Open(filepath.Join(dirPath, BPlusTreeFileName), 0644, options)
 	if err != nil {
 		panic("failed to open bplus tree: " + err.Error())
 	}
@@ -153,7 +153,10 @@ func (bpti *bPlusTreeIterator) Rewind() {
 func (bpti *bPlusTreeIterator) Seek(key []byte) {
 	bpti.key, bpti.value = bpti.cursor.Seek(key)
 	// keep the same logic with btree
-	if bpti.reverse && bytes.Compare(bpti.key, key) > 0 {
+	if bpti.reverse && bpti.key == nil {
+		// all the keys are less than key
+		bpti.key, bpti.value = bpti.cursor.Last()
+	} else if bpti.reverse && bytes.Compare(bpti.key, key) > 0 {
 		bpti.key, bpti.value = bpti.cursor.Prev()
 	}
 }
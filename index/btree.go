@@ -3,7 +3,6 @@ package index
 import (
 	"bitcask-go/storage"
 	"bytes"
-	"sort"
 	"sync"
 
 	"github.com/google/btree"
@@ -80,14 +79,18 @@ func (bt *BTree) Delete(key []byte) (*storage.LogRecordPos, bool) {
 	return oldItem.(*Item).pos, true
 }
 
+// Iterator walk a copy-on-write clone of the tree lazily, writes after creating iterator are not visible
 func (bt *BTree) Iterator(reverse bool) Iterator {
 	if bt.tree == nil {
 		return nil
 	}
 
-	bt.mu.RLock()
-	defer bt.mu.RUnlock()
-	return newBTreeIterator(bt.tree, reverse)
+	// clone is not safe to call concurrently with other operations
+	bt.mu.Lock()
+	tree := bt.tree.Clone()
+	bt.mu.Unlock()
+
+	return newBTreeIterator(tree, reverse)
 }
 
 func (bt *BTree) Size() int {
@@ -105,56 +108,46 @@ func (item *Item) Less(than btree.Item) bool {
 	return bytes.Compare(item.key, than.(*Item).key) == -1
 }
 
+// number of items fetched from btree each time
+const btreeIteratorBatchSize = 64
+
 type BTreeIterator struct {
-	currentIndex int
+	tree         *btree.BTree
 	reverse      bool
-	values       []*Item
+	currentIndex int
+	values       []*Item // current batch of items
+	hasMore      bool    // more items after current batch
 }
 
 func newBTreeIterator(tree *btree.BTree, reverse bool) *BTreeIterator {
-	values := make([]*Item, tree.Len())
-	var index int
-
-	getValue := func(item btree.Item) bool {
-		values[index] = item.(*Item)
-		index++
-		return true
-	}
-
-	if reverse {
-		tree.Descend(getValue)
-	} else {
-		tree.Ascend(getValue)
-	}
-
-	return &BTreeIterator{
-		currentIndex: 0,
-		reverse:      reverse,
-		values:       values,
+	bi := &BTreeIterator{
+		tree:    tree,
+		reverse: reverse,
+		values:  make([]*Item, 0, btreeIteratorBatchSize),
 	}
+	bi.Rewind()
+	return bi
 }
 
 // Rewind set iterator to first element
 func (bi *BTreeIterator) Rewind() {
-	bi.currentIndex = 0
+	bi.fetch(nil, false)
 }
 
 // Seek the first element less/greater than key
 func (bi *BTreeIterator) Seek(key []byte) {
-	if bi.reverse {
-		bi.currentIndex = sort.Search(len(bi.values), func(i int) bool {
-			return bytes.Compare(bi.values[i].key, key) <= 0
-		})
-	} else {
-		bi.currentIndex = sort.Search(len(bi.values), func(i int) bool {
-			return bytes.Compare(bi.values[i].key, key) >= 0
-		})
+	if key == nil {
+		key = []byte{}
 	}
+	bi.fetch(key, false)
 }
 
 // Next go to next element
 func (bi *BTreeIterator) Next() {
 	bi.currentIndex++
+	if bi.currentIndex == len(bi.values) && bi.hasMore {
+		bi.fetch(bi.values[len(bi.values)-1].key, true)
+	}
 }
 
 // Valid check if has next element
@@ -174,5 +167,37 @@ func (bi *BTreeIterator) Value() *storage.LogRecordPos {
 
 // Close iterator, free resource
 func (bi *BTreeIterator) Close() {
+	bi.tree = nil
 	bi.values = nil
 }
+
+// fetch next batch of items from pivot, start from the first (or last for reverse) item if pivot is nil
+func (bi *BTreeIterator) fetch(pivot []byte, skipPivot bool) {
+	bi.values = bi.values[:0]
+	bi.currentIndex = 0
+	bi.hasMore = false
+
+	collect := func(item btree.Item) bool {
+		it := item.(*Item)
+		if skipPivot && bytes.Equal(it.key, pivot) {
+			return true
+		}
+		if len(bi.values) == btreeIteratorBatchSize {
+			bi.hasMore = true
+			return false
+		}
+		bi.values = append(bi.values, it)
+		return true
+	}
+
+	switch {
+	case pivot == nil && bi.reverse:
+		bi.tree.Descend(collect)
+	case pivot == nil:
+		bi.tree.Ascend(collect)
+	case bi.reverse:
+		bi.tree.DescendLessOrEqual(&Item{key: pivot}, collect)
+	default:
+		bi.tree.AscendGreaterOrEqual(&Item{key: pivot}, collect)
+	}
+}
@@ -2,6 +2,7 @@ package index
 
 import (
 	"bitcask-go/storage"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -169,3 +170,41 @@ func TestBTree_Iterator_TestSeek(t *testing.T) {
 	}
 	assert.Equal(t, 1, i)
 }
+
+func TestBTree_Iterator_AcrossBatches(t *testing.T) {
+	bt := NewBTree(DefaultDegree)
+	n := btreeIteratorBatchSize*3 + 5
+	for i := 0; i < n; i++ {
+		bt.Put([]byte(fmt.Sprintf("key-%04d", i)), &storage.LogRecordPos{Fid: 1, Offset: int64(i)})
+	}
+
+	iter := bt.Iterator(false)
+	var count int
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		assert.Equal(t, []byte(fmt.Sprintf("key-%04d", count)), iter.Key())
+		count++
+	}
+	assert.Equal(t, n, count)
+
+	// writes after creating iterator are not visible
+	bt.Put([]byte("key-9999"), &storage.LogRecordPos{Fid: 1, Offset: 9999})
+	bt.Delete([]byte("key-0000"))
+	iter.Seek([]byte("key-0100"))
+	count = 0
+	for ; iter.Valid(); iter.Next() {
+		assert.Equal(t, []byte(fmt.Sprintf("key-%04d", 100+count)), iter.Key())
+		count++
+	}
+	assert.Equal(t, n-100, count)
+	iter.Close()
+
+	iter = bt.Iterator(true)
+	count = 0
+	for iter.Seek([]byte("key-0100")); iter.Valid(); iter.Next() {
+		assert.Equal(t, []byte(fmt.Sprintf("key-%04d", 100-count)), iter.Key())
+		count++
+	}
+	// key-0000 is deleted
+	assert.Equal(t, 100, count)
+	iter.Close()
+}
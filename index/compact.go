@@ -0,0 +1,358 @@
+package index
+
+import (
+	"bitcask-go/storage"
+	"bytes"
+	"hash/maphash"
+	"sync"
+	"unsafe"
+
+	"github.com/google/btree"
+)
+
+const (
+	compactFidBits    = 24
+	compactOffsetBits = 64 - compactFidBits
+	maxCompactFid     = 1<<compactFidBits - 1
+	maxCompactOffset  = 1<<compactOffsetBits - 1
+)
+
+// KeyReader read the key of log record on disk, used to verify the key while only key hash is kept in memory
+type KeyReader func(pos *storage.LogRecordPos) ([]byte, error)
+
+// compactPos log record position packed into fixed-width integers,
+// fid takes the high 24 bits and offset takes the low 40 bits
+type compactPos struct {
+	fidOffset uint64
+	size      uint32
+}
+
+type compactItem struct {
+	key []byte
+	pos compactPos
+}
+
+// CompactIndex memory-compact index, which keeps positions by value instead of heap-allocated *storage.LogRecordPos,
+// in hash only mode, only 64-bit hash of key is kept and the key is verified on disk through KeyReader
+type CompactIndex struct {
+	mu       *sync.RWMutex
+	hashOnly bool
+
+	// full key mode, items are stored in btree nodes by value
+	tree     *btree.BTreeG[compactItem]
+	keyBytes int64
+
+	// hash only mode, keys with same hash are kept in collisions
+	seed       maphash.Seed
+	entries    map[uint64]compactPos
+	collisions map[uint64][]compactPos
+	keyReader  KeyReader
+	size       int
+}
+
+// NewCompactIndex Initialize CompactIndex, keyReader is required in hash only mode
+func NewCompactIndex(hashOnly bool, keyReader KeyReader) *CompactIndex {
+	if hashOnly && keyReader == nil {
+		panic("key reader is required for hash only compact index")
+	}
+
+	ci := &CompactIndex{
+		mu:       new(sync.RWMutex),
+		hashOnly: hashOnly,
+	}
+	if hashOnly {
+		ci.seed = maphash.MakeSeed()
+		ci.entries = make(map[uint64]compactPos)
+		ci.collisions = make(map[uint64][]compactPos)
+		ci.keyReader = keyReader
+	} else {
+		ci.tree = btree.NewG[compactItem](DefaultDegree, func(a, b compactItem) bool {
+			return bytes.Compare(a.key, b.key) == -1
+		})
+	}
+	return ci
+}
+
+// Get position of key, nil is returned if key on disk can't be read, use GetChecked to get the error
+func (ci *CompactIndex) Get(key []byte) *storage.LogRecordPos {
+	pos, _ := ci.GetChecked(key)
+	return pos
+}
+
+// Put position of key, nothing is changed if it fails, use PutChecked to get the error
+func (ci *CompactIndex) Put(key []byte, pos *storage.LogRecordPos) *storage.LogRecordPos {
+	oldPos, _ := ci.PutChecked(key, pos)
+	return oldPos
+}
+
+// Delete key, nothing is changed if it fails, use DeleteChecked to get the error
+func (ci *CompactIndex) Delete(key []byte) (*storage.LogRecordPos, bool) {
+	oldPos, ok, _ := ci.DeleteChecked(key)
+	return oldPos, ok
+}
+
+// Iterator keys which can't be read from disk are skipped, use IteratorChecked to get the error
+func (ci *CompactIndex) Iterator(reverse bool) Iterator {
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+
+	values, _ := ci.itemsLocked(true)
+	return newSliceIterator(values, reverse)
+}
+
+func (ci *CompactIndex) GetChecked(key []byte) (*storage.LogRecordPos, error) {
+	if key == nil {
+		return nil, nil
+	}
+
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+
+	if !ci.hashOnly {
+		item, found := ci.tree.Get(compactItem{key: key})
+		if !found {
+			return nil, nil
+		}
+		return unpackPos(item.pos), nil
+	}
+
+	h := maphash.Bytes(ci.seed, key)
+	if _, found := ci.entries[h]; !found {
+		return nil, nil
+	}
+	slot, found, err := ci.findLocked(h, key)
+	if err != nil || !found {
+		return nil, err
+	}
+	return unpackPos(ci.slotLocked(h, slot)), nil
+}
+
+func (ci *CompactIndex) PutChecked(key []byte, pos *storage.LogRecordPos) (*storage.LogRecordPos, error) {
+	if key == nil {
+		return nil, nil
+	}
+
+	packed, err := packPos(pos)
+	if err != nil {
+		return nil, err
+	}
+
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	if !ci.hashOnly {
+		// copy key, so it does not keep the whole buffer of log record alive
+		oldItem, replaced := ci.tree.ReplaceOrInsert(compactItem{key: bytes.Clone(key), pos: packed})
+		if !replaced {
+			ci.keyBytes += int64(len(key))
+			return nil, nil
+		}
+		return unpackPos(oldItem.pos), nil
+	}
+
+	h := maphash.Bytes(ci.seed, key)
+	if _, found := ci.entries[h]; !found {
+		ci.entries[h] = packed
+		ci.size++
+		return nil, nil
+	}
+	// key must be verified, otherwise it's inserted again as a collision
+	slot, found, err := ci.findLocked(h, key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		ci.collisions[h] = append(ci.collisions[h], packed)
+		ci.size++
+		return nil, nil
+	}
+	oldPos := unpackPos(ci.slotLocked(h, slot))
+	ci.setSlotLocked(h, slot, packed)
+	return oldPos, nil
+}
+
+func (ci *CompactIndex) DeleteChecked(key []byte) (*storage.LogRecordPos, bool, error) {
+	if key == nil {
+		return nil, false, nil
+	}
+
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+
+	if !ci.hashOnly {
+		oldItem, deleted := ci.tree.Delete(compactItem{key: key})
+		if !deleted {
+			return nil, false, nil
+		}
+		ci.keyBytes -= int64(len(oldItem.key))
+		return unpackPos(oldItem.pos), true, nil
+	}
+
+	h := maphash.Bytes(ci.seed, key)
+	if _, found := ci.entries[h]; !found {
+		return nil, false, nil
+	}
+	slot, found, err := ci.findLocked(h, key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	oldPos := unpackPos(ci.slotLocked(h, slot))
+
+	// move the last one of collisions to the deleted slot
+	collisions := ci.collisions[h]
+	if len(collisions) == 0 {
+		delete(ci.entries, h)
+	} else {
+		ci.setSlotLocked(h, slot, collisions[len(collisions)-1])
+		ci.setCollisions(h, collisions[:len(collisions)-1])
+	}
+	ci.size--
+	return oldPos, true, nil
+}
+
+// IteratorChecked in hash only mode, keys are read from disk and sorted, error is returned if any key can't be read
+func (ci *CompactIndex) IteratorChecked(reverse bool) (Iterator, error) {
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+
+	values, err := ci.itemsLocked(false)
+	if err != nil {
+		return nil, err
+	}
+	return newSliceIterator(values, reverse), nil
+}
+
+func (ci *CompactIndex) Size() int {
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+	return ci.sizeLocked()
+}
+
+func (ci *CompactIndex) Close() error {
+	return nil
+}
+
+// MemoryUsage estimated memory of entries, btree nodes and map buckets overhead is not counted
+func (ci *CompactIndex) MemoryUsage() int64 {
+	ci.mu.RLock()
+	defer ci.mu.RUnlock()
+
+	if !ci.hashOnly {
+		return int64(ci.tree.Len())*int64(unsafe.Sizeof(compactItem{})) + ci.keyBytes
+	}
+
+	entrySize := int64(unsafe.Sizeof(uint64(0)) + unsafe.Sizeof(compactPos{}))
+	usage := int64(len(ci.entries)) * entrySize
+	for _, collisions := range ci.collisions {
+		usage += int64(cap(collisions)) * int64(unsafe.Sizeof(compactPos{}))
+	}
+	return usage
+}
+
+func (ci *CompactIndex) sizeLocked() int {
+	if !ci.hashOnly {
+		return ci.tree.Len()
+	}
+	return ci.size
+}
+
+func (ci *CompactIndex) setCollisions(h uint64, collisions []compactPos) {
+	if len(collisions) == 0 {
+		delete(ci.collisions, h)
+		return
+	}
+	ci.collisions[h] = collisions
+}
+
+// itemsLocked all the items, in hash only mode keys are read from disk,
+// the ones failed to read are skipped if skipErr, otherwise the error is returned
+func (ci *CompactIndex) itemsLocked(skipErr bool) ([]*Item, error) {
+	values := make([]*Item, 0, ci.sizeLocked())
+	if !ci.hashOnly {
+		ci.tree.Ascend(func(item compactItem) bool {
+			values = append(values, &Item{key: item.key, pos: unpackPos(item.pos)})
+			return true
+		})
+		return values, nil
+	}
+
+	appendItem := func(packed compactPos) error {
+		pos := unpackPos(packed)
+		key, err := ci.keyReader(pos)
+		if err != nil {
+			if skipErr {
+				return nil
+			}
+			return err
+		}
+		values = append(values, &Item{key: key, pos: pos})
+		return nil
+	}
+	for h, pos := range ci.entries {
+		if err := appendItem(pos); err != nil {
+			return nil, err
+		}
+		for _, collision := range ci.collisions[h] {
+			if err := appendItem(collision); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+// findLocked find slot of key in the entry and collisions of hash, slot -1 is the primary entry
+func (ci *CompactIndex) findLocked(h uint64, key []byte) (int, bool, error) {
+	for slot := -1; slot < len(ci.collisions[h]); slot++ {
+		match, err := ci.matchKey(key, ci.slotLocked(h, slot))
+		if err != nil {
+			return 0, false, err
+		}
+		if match {
+			return slot, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (ci *CompactIndex) slotLocked(h uint64, slot int) compactPos {
+	if slot < 0 {
+		return ci.entries[h]
+	}
+	return ci.collisions[h][slot]
+}
+
+func (ci *CompactIndex) setSlotLocked(h uint64, slot int, packed compactPos) {
+	if slot < 0 {
+		ci.entries[h] = packed
+		return
+	}
+	ci.collisions[h][slot] = packed
+}
+
+// matchKey verify key of position on disk
+func (ci *CompactIndex) matchKey(key []byte, packed compactPos) (bool, error) {
+	diskKey, err := ci.keyReader(unpackPos(packed))
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(key, diskKey), nil
+}
+
+func packPos(pos *storage.LogRecordPos) (compactPos, error) {
+	if pos.Fid > maxCompactFid || pos.Offset < 0 || pos.Offset > maxCompactOffset {
+		return compactPos{}, ErrPositionOutOfRange
+	}
+	return compactPos{
+		fidOffset: uint64(pos.Fid)<<compactOffsetBits | uint64(pos.Offset),
+		size:      pos.LogRecordSize,
+	}, nil
+}
+
+func unpackPos(packed compactPos) *storage.LogRecordPos {
+	return &storage.LogRecordPos{
+		Fid:           uint32(packed.fidOffset >> compactOffsetBits),
+		Offset:        int64(packed.fidOffset & maxCompactOffset),
+		LogRecordSize: packed.size,
+	}
+}
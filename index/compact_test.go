@@ -0,0 +1,199 @@
+package index
+
+import (
+	"bitcask-go/storage"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKeyReader pretend key on disk is stored at offset
+type fakeKeyReader map[int64][]byte
+
+func (r fakeKeyReader) read(pos *storage.LogRecordPos) ([]byte, error) {
+	key, ok := r[pos.Offset]
+	if !ok {
+		return nil, errors.New("key not found on disk")
+	}
+	return key, nil
+}
+
+func TestCompactIndex_PackPosition(t *testing.T) {
+	for _, pos := range []*storage.LogRecordPos{
+		{Fid: maxCompactFid, Offset: maxCompactOffset, LogRecordSize: 1024},
+		{Fid: 1, Offset: 0, LogRecordSize: 0},
+	} {
+		packed, err := packPos(pos)
+		assert.Nil(t, err)
+		assert.Equal(t, pos, unpackPos(packed))
+	}
+
+	_, err := packPos(&storage.LogRecordPos{Fid: maxCompactFid + 1})
+	assert.Equal(t, ErrPositionOutOfRange, err)
+	_, err = packPos(&storage.LogRecordPos{Fid: 1, Offset: maxCompactOffset + 1})
+	assert.Equal(t, ErrPositionOutOfRange, err)
+
+	ci := NewCompactIndex(false, nil)
+	_, err = ci.PutChecked([]byte("key"), &storage.LogRecordPos{Fid: maxCompactFid + 1})
+	assert.Equal(t, ErrPositionOutOfRange, err)
+	assert.Nil(t, ci.Put([]byte("key"), &storage.LogRecordPos{Fid: maxCompactFid + 1}))
+	assert.Equal(t, 0, ci.Size())
+}
+
+func TestCompactIndex_PutGetDelete(t *testing.T) {
+	reader := fakeKeyReader{}
+	for _, hashOnly := range []bool{false, true} {
+		ci := NewCompactIndex(hashOnly, reader.read)
+
+		assert.Nil(t, ci.Put(nil, &storage.LogRecordPos{Fid: 1, Offset: 10}))
+		assert.Nil(t, ci.Get(nil))
+
+		reader[10] = []byte("123")
+		res := ci.Put([]byte("123"), &storage.LogRecordPos{Fid: 1, Offset: 10, LogRecordSize: 5})
+		assert.Nil(t, res)
+		pos := ci.Get([]byte("123"))
+		assert.Equal(t, &storage.LogRecordPos{Fid: 1, Offset: 10, LogRecordSize: 5}, pos)
+
+		reader[20] = []byte("123")
+		res = ci.Put([]byte("123"), &storage.LogRecordPos{Fid: 2, Offset: 20, LogRecordSize: 6})
+		assert.Equal(t, &storage.LogRecordPos{Fid: 1, Offset: 10, LogRecordSize: 5}, res)
+		assert.Equal(t, 1, ci.Size())
+
+		assert.Nil(t, ci.Get([]byte("not-exist")))
+
+		oldPos, ok := ci.Delete([]byte("123"))
+		assert.True(t, ok)
+		assert.Equal(t, &storage.LogRecordPos{Fid: 2, Offset: 20, LogRecordSize: 6}, oldPos)
+		assert.Nil(t, ci.Get([]byte("123")))
+		assert.Equal(t, 0, ci.Size())
+
+		_, ok = ci.Delete([]byte("123"))
+		assert.False(t, ok)
+	}
+}
+
+func TestCompactIndex_HashCollision(t *testing.T) {
+	reader := fakeKeyReader{}
+	ci := NewCompactIndex(true, reader.read)
+
+	// force all the keys in the same hash slot
+	for i := 0; i < 3; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		reader[int64(i)] = key
+		packed, err := packPos(&storage.LogRecordPos{Fid: 1, Offset: int64(i)})
+		assert.Nil(t, err)
+		ci.collisions[0] = append(ci.collisions[0], packed)
+	}
+	ci.entries[0] = ci.collisions[0][0]
+	ci.collisions[0] = ci.collisions[0][1:]
+	ci.size = 3
+
+	// keys are verified on disk, so it's fine to look them up by slot directly
+	for i := 0; i < 3; i++ {
+		match, err := ci.matchKey([]byte(fmt.Sprintf("key-%d", i)), ci.entriesOf(0)[i])
+		assert.Nil(t, err)
+		assert.True(t, match)
+	}
+
+	iter := ci.Iterator(false)
+	var keys []string
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	iter.Close()
+	assert.Equal(t, []string{"key-0", "key-1", "key-2"}, keys)
+
+	// primary entry is slot -1, collisions follow it
+	for i := 0; i < 3; i++ {
+		slot, found, err := ci.findLocked(0, []byte(fmt.Sprintf("key-%d", i)))
+		assert.Nil(t, err)
+		assert.True(t, found)
+		assert.Equal(t, i-1, slot)
+	}
+	_, found, err := ci.findLocked(0, []byte("key-3"))
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestCompactIndex_ReadKeyFailed(t *testing.T) {
+	reader := fakeKeyReader{}
+	ci := NewCompactIndex(true, reader.read)
+
+	reader[1] = []byte("key")
+	assert.Nil(t, ci.Put([]byte("key"), &storage.LogRecordPos{Fid: 1, Offset: 1}))
+	delete(reader, 1)
+
+	// key on disk can't be verified, so nothing is changed instead of inserting a duplicate
+	_, err := ci.PutChecked([]byte("key"), &storage.LogRecordPos{Fid: 1, Offset: 2})
+	assert.NotNil(t, err)
+	assert.Nil(t, ci.Put([]byte("key"), &storage.LogRecordPos{Fid: 1, Offset: 2}))
+	assert.Equal(t, 1, ci.Size())
+
+	_, err = ci.GetChecked([]byte("key"))
+	assert.NotNil(t, err)
+	_, _, err = ci.DeleteChecked([]byte("key"))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, ci.Size())
+
+	_, err = ci.IteratorChecked(false)
+	assert.NotNil(t, err)
+	iter := ci.Iterator(false)
+	iter.Rewind()
+	assert.False(t, iter.Valid())
+	iter.Close()
+
+	reader[1] = []byte("key")
+	oldPos, err := CheckedPut(ci, []byte("key"), &storage.LogRecordPos{Fid: 1, Offset: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), oldPos.Offset)
+}
+
+func TestCompactIndex_Iterator(t *testing.T) {
+	reader := fakeKeyReader{}
+	for _, hashOnly := range []bool{false, true} {
+		ci := NewCompactIndex(hashOnly, reader.read)
+		for i, key := range []string{"ccde", "adse", "bbde", "bade"} {
+			reader[int64(i)] = []byte(key)
+			ci.Put([]byte(key), &storage.LogRecordPos{Fid: 1, Offset: int64(i)})
+		}
+
+		iter := ci.Iterator(false)
+		var keys []string
+		for iter.Rewind(); iter.Valid(); iter.Next() {
+			keys = append(keys, string(iter.Key()))
+		}
+		assert.Equal(t, []string{"adse", "bade", "bbde", "ccde"}, keys)
+
+		iter.Seek([]byte("bb"))
+		assert.Equal(t, []byte("bbde"), iter.Key())
+		iter.Close()
+
+		iter = ci.Iterator(true)
+		iter.Seek([]byte("bb"))
+		assert.Equal(t, []byte("bade"), iter.Key())
+		assert.Equal(t, int64(3), iter.Value().Offset)
+		iter.Close()
+	}
+}
+
+func TestCompactIndex_MemoryUsage(t *testing.T) {
+	reader := fakeKeyReader{}
+	for _, hashOnly := range []bool{false, true} {
+		ci := NewCompactIndex(hashOnly, reader.read)
+		assert.Equal(t, int64(0), ci.MemoryUsage())
+
+		for i := 0; i < 100; i++ {
+			key := []byte(fmt.Sprintf("key-%03d", i))
+			reader[int64(i)] = key
+			ci.Put(key, &storage.LogRecordPos{Fid: 1, Offset: int64(i)})
+		}
+		assert.Greater(t, ci.MemoryUsage(), int64(0))
+	}
+}
+
+// entriesOf primary entry with its collisions
+func (ci *CompactIndex) entriesOf(h uint64) []compactPos {
+	return append([]compactPos{ci.entries[h]}, ci.collisions[h]...)
+}
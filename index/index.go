@@ -2,6 +2,11 @@ package index
 
 import (
 	"bitcask-go/storage"
+	"errors"
+)
+
+var (
+	ErrPositionOutOfRange = errors.New("log record position exceeds index limit")
 )
 
 // Indexer Key can't be nil
@@ -18,15 +23,66 @@ type Indexer interface {
 	Close() error
 }
 
+// MemoryReporter optional interface for indexer to report estimated memory in bytes
+type MemoryReporter interface {
+	MemoryUsage() int64
+}
+
+// CheckedIndexer optional interface for indexer which may fail on reading disk or on positions it can't keep,
+// methods of Indexer drop the error, so the checked ones are used through CheckedGet, CheckedPut and so on
+type CheckedIndexer interface {
+	GetChecked(key []byte) (*storage.LogRecordPos, error)
+	PutChecked(key []byte, pos *storage.LogRecordPos) (*storage.LogRecordPos, error)
+	DeleteChecked(key []byte) (*storage.LogRecordPos, bool, error)
+	IteratorChecked(reverse bool) (Iterator, error)
+}
+
+// CheckedGet get position from indexer, error is only returned by CheckedIndexer
+func CheckedGet(indexer Indexer, key []byte) (*storage.LogRecordPos, error) {
+	if checked, ok := indexer.(CheckedIndexer); ok {
+		return checked.GetChecked(key)
+	}
+	return indexer.Get(key), nil
+}
+
+// CheckedPut put position to indexer, error is only returned by CheckedIndexer
+func CheckedPut(indexer Indexer, key []byte, pos *storage.LogRecordPos) (*storage.LogRecordPos, error) {
+	if checked, ok := indexer.(CheckedIndexer); ok {
+		return checked.PutChecked(key, pos)
+	}
+	return indexer.Put(key, pos), nil
+}
+
+// CheckedDelete delete key from indexer, error is only returned by CheckedIndexer
+func CheckedDelete(indexer Indexer, key []byte) (*storage.LogRecordPos, bool, error) {
+	if checked, ok := indexer.(CheckedIndexer); ok {
+		return checked.DeleteChecked(key)
+	}
+	oldPos, ok := indexer.Delete(key)
+	return oldPos, ok, nil
+}
+
+// CheckedIterator create iterator of indexer, error is only returned by CheckedIndexer
+func CheckedIterator(indexer Indexer, reverse bool) (Iterator, error) {
+	if checked, ok := indexer.(CheckedIndexer); ok {
+		return checked.IteratorChecked(reverse)
+	}
+	return indexer.Iterator(reverse), nil
+}
+
 type IndexerType = byte
 
 const (
 	BTreeIndexType IndexerType = iota + 1 // BTree index type enumeration
 	ARTIndexType                          // ART?
 	BPlusTreeIndexType
+	CompactIndexType     // compact positions with full keys
+	CompactHashIndexType // compact positions with key hash only, key is verified on disk
+	ShardedHashIndexType // unordered hash map with lock striping
 )
 
-func NewIndexer(typ IndexerType, dirPath string, syncWrites bool) Indexer {
+// NewIndexer keyReader is only used by CompactHashIndexType
+func NewIndexer(typ IndexerType, dirPath string, syncWrites bool, keyReader KeyReader) Indexer {
 	switch typ {
 	case BTreeIndexType:
 		return NewBTree(DefaultDegree)
@@ -34,6 +90,12 @@ func NewIndexer(typ IndexerType, dirPath string, syncWrites bool) Indexer {
 		return NewAdaptiveRadixTree()
 	case BPlusTreeIndexType:
 		return NewBPlusTree(dirPath, syncWrites)
+	case CompactIndexType:
+		return NewCompactIndex(false, nil)
+	case CompactHashIndexType:
+		return NewCompactIndex(true, keyReader)
+	case ShardedHashIndexType:
+		return NewShardedHashIndex(DefaultShardNum)
 	default:
 		panic("unsupported index type")
 	}
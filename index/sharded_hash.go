@@ -0,0 +1,118 @@
+package index
+
+import (
+	"bitcask-go/storage"
+	"hash/maphash"
+	"sync"
+)
+
+const (
+	DefaultShardNum = 64
+)
+
+// ShardedHashIndex hash map index split into shards, each shard has its own lock,
+// so concurrent writers on different shards don't block each other. Keys are not ordered,
+// iterator sorts all the keys on demand
+type ShardedHashIndex struct {
+	seed   maphash.Seed
+	shards []*hashShard
+}
+
+type hashShard struct {
+	mu    *sync.RWMutex
+	items map[string]*storage.LogRecordPos
+}
+
+// NewShardedHashIndex Initialize ShardedHashIndex
+func NewShardedHashIndex(shardNum int) *ShardedHashIndex {
+	if shardNum <= 0 {
+		shardNum = DefaultShardNum
+	}
+
+	shards := make([]*hashShard, shardNum)
+	for i := range shards {
+		shards[i] = &hashShard{
+			mu:    new(sync.RWMutex),
+			items: make(map[string]*storage.LogRecordPos),
+		}
+	}
+	return &ShardedHashIndex{
+		seed:   maphash.MakeSeed(),
+		shards: shards,
+	}
+}
+
+func (shi *ShardedHashIndex) Get(key []byte) *storage.LogRecordPos {
+	if key == nil {
+		return nil
+	}
+
+	shard := shi.getShard(key)
+	shard.mu.RLock()
+	pos := shard.items[string(key)]
+	shard.mu.RUnlock()
+
+	return pos
+}
+
+func (shi *ShardedHashIndex) Put(key []byte, pos *storage.LogRecordPos) *storage.LogRecordPos {
+	if key == nil {
+		return nil
+	}
+
+	shard := shi.getShard(key)
+	shard.mu.Lock()
+	oldPos := shard.items[string(key)]
+	shard.items[string(key)] = pos
+	shard.mu.Unlock()
+
+	return oldPos
+}
+
+func (shi *ShardedHashIndex) Delete(key []byte) (*storage.LogRecordPos, bool) {
+	if key == nil {
+		return nil, false
+	}
+
+	shard := shi.getShard(key)
+	shard.mu.Lock()
+	oldPos, ok := shard.items[string(key)]
+	if ok {
+		delete(shard.items, string(key))
+	}
+	shard.mu.Unlock()
+
+	return oldPos, ok
+}
+
+// Iterator copy the keys of all shards and sort them
+func (shi *ShardedHashIndex) Iterator(reverse bool) Iterator {
+	values := make([]*Item, 0, shi.Size())
+	for _, shard := range shi.shards {
+		shard.mu.RLock()
+		for key, pos := range shard.items {
+			values = append(values, &Item{key: []byte(key), pos: pos})
+		}
+		shard.mu.RUnlock()
+	}
+
+	return newSliceIterator(values, reverse)
+}
+
+func (shi *ShardedHashIndex) Size() int {
+	var size int
+	for _, shard := range shi.shards {
+		shard.mu.RLock()
+		size += len(shard.items)
+		shard.mu.RUnlock()
+	}
+	return size
+}
+
+func (shi *ShardedHashIndex) Close() error {
+	return nil
+}
+
+func (shi *ShardedHashIndex) getShard(key []byte) *hashShard {
+	return shi.shards[maphash.Bytes(shi.seed, key)%uint64(len(shi.shards))]
+}
@@ -0,0 +1,85 @@
+package index
+
+import (
+	"bitcask-go/storage"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedHashIndex_PutGetDelete(t *testing.T) {
+	shi := NewShardedHashIndex(DefaultShardNum)
+
+	assert.Nil(t, shi.Put(nil, &storage.LogRecordPos{Fid: 1, Offset: 10}))
+	assert.Nil(t, shi.Get(nil))
+
+	res := shi.Put([]byte("123"), &storage.LogRecordPos{Fid: 1, Offset: 10})
+	assert.Nil(t, res)
+	pos := shi.Get([]byte("123"))
+	assert.Equal(t, uint32(1), pos.Fid)
+	assert.Equal(t, int64(10), pos.Offset)
+
+	res = shi.Put([]byte("123"), &storage.LogRecordPos{Fid: 2, Offset: 20})
+	assert.Equal(t, uint32(1), res.Fid)
+	assert.Equal(t, 1, shi.Size())
+
+	oldPos, ok := shi.Delete([]byte("123"))
+	assert.True(t, ok)
+	assert.Equal(t, uint32(2), oldPos.Fid)
+	assert.Nil(t, shi.Get([]byte("123")))
+	assert.Equal(t, 0, shi.Size())
+
+	_, ok = shi.Delete([]byte("123"))
+	assert.False(t, ok)
+}
+
+func TestShardedHashIndex_ConcurrentPut(t *testing.T) {
+	shi := NewShardedHashIndex(DefaultShardNum)
+
+	wg := new(sync.WaitGroup)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := []byte(fmt.Sprintf("key-%d-%04d", w, i))
+				shi.Put(key, &storage.LogRecordPos{Fid: uint32(w), Offset: int64(i)})
+				assert.NotNil(t, shi.Get(key))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 8000, shi.Size())
+}
+
+func TestShardedHashIndex_Iterator(t *testing.T) {
+	shi := NewShardedHashIndex(4)
+	for i, key := range []string{"ccde", "adse", "bbde", "bade"} {
+		shi.Put([]byte(key), &storage.LogRecordPos{Fid: 1, Offset: int64(i)})
+	}
+
+	iter := shi.Iterator(false)
+	var keys []string
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"adse", "bade", "bbde", "ccde"}, keys)
+
+	iter.Seek([]byte("bb"))
+	assert.Equal(t, []byte("bbde"), iter.Key())
+	iter.Close()
+
+	iter = shi.Iterator(true)
+	keys = nil
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	assert.Equal(t, []string{"ccde", "bbde", "bade", "adse"}, keys)
+
+	iter.Seek([]byte("bb"))
+	assert.Equal(t, []byte("bade"), iter.Key())
+	iter.Close()
+}
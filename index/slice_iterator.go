@@ -0,0 +1,74 @@
+package index
+
+import (
+	"bitcask-go/storage"
+	"bytes"
+	"sort"
+)
+
+// sliceIterator iterate over items which are already sorted in iterating order,
+// used by the indexes which can't walk their keys in order
+type sliceIterator struct {
+	currentIndex int
+	reverse      bool
+	values       []*Item
+}
+
+// newSliceIterator sort items by key and build iterator
+func newSliceIterator(values []*Item, reverse bool) *sliceIterator {
+	sort.Slice(values, func(i, j int) bool {
+		if reverse {
+			return bytes.Compare(values[i].key, values[j].key) > 0
+		}
+		return bytes.Compare(values[i].key, values[j].key) < 0
+	})
+
+	return &sliceIterator{
+		currentIndex: 0,
+		reverse:      reverse,
+		values:       values,
+	}
+}
+
+// Rewind set iterator to first element
+func (si *sliceIterator) Rewind() {
+	si.currentIndex = 0
+}
+
+// Seek the first element less/greater than key
+func (si *sliceIterator) Seek(key []byte) {
+	if si.reverse {
+		si.currentIndex = sort.Search(len(si.values), func(i int) bool {
+			return bytes.Compare(si.values[i].key, key) <= 0
+		})
+	} else {
+		si.currentIndex = sort.Search(len(si.values), func(i int) bool {
+			return bytes.Compare(si.values[i].key, key) >= 0
+		})
+	}
+}
+
+// Next go to next element
+func (si *sliceIterator) Next() {
+	si.currentIndex++
+}
+
+// Valid check if has next element
+func (si *sliceIterator) Valid() bool {
+	return si.currentIndex < len(si.values)
+}
+
+// Key current element key
+func (si *sliceIterator) Key() []byte {
+	return si.values[si.currentIndex].key
+}
+
+// Value current element value
+func (si *sliceIterator) Value() *storage.LogRecordPos {
+	return si.values[si.currentIndex].pos
+}
+
+// Close iterator, free resource
+func (si *sliceIterator) Close() {
+	si.values = nil
+}
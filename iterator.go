@@ -10,38 +10,86 @@ type Iterator struct {
 	indexIterator index.Iterator
 	db            *DB
 	config        IteratorConfig
+	lowerBound    []byte // inclusive, merged from config start and prefix
+	upperBound    []byte // exclusive, merged from config end and prefix
+	count         int    // number of keys iterated since rewind or seek
 }
 
 func (db *DB) NewIterator(config IteratorConfig) *Iterator {
-	iterator := db.index.Iterator(config.reverse)
-	return &Iterator{
-		indexIterator: iterator,
+	_ = db.waitIndexReady()
+
+	iterator := &Iterator{
+		indexIterator: db.index.Iterator(config.Reverse),
 		db:            db,
 		config:        config,
+		lowerBound:    config.Start,
+		upperBound:    config.End,
+	}
+
+	if len(config.Prefix) > 0 {
+		if iterator.lowerBound == nil || bytes.Compare(config.Prefix, iterator.lowerBound) > 0 {
+			iterator.lowerBound = config.Prefix
+		}
+		prefixEnd := prefixUpperBound(config.Prefix)
+		if prefixEnd != nil && (iterator.upperBound == nil || bytes.Compare(prefixEnd, iterator.upperBound) < 0) {
+			iterator.upperBound = prefixEnd
+		}
 	}
+
+	iterator.Rewind()
+	return iterator
 }
 
-// Rewind set iterator to first element
+// Rewind set iterator to first element in range
 func (it *Iterator) Rewind() {
+	it.count = 0
+	if it.config.Reverse {
+		if it.upperBound != nil {
+			it.seekBeforeUpperBound()
+			return
+		}
+	} else if it.lowerBound != nil {
+		it.indexIterator.Seek(it.lowerBound)
+		return
+	}
 	it.indexIterator.Rewind()
-	it.skipToNext()
 }
 
-// Seek the first element less/greater than key byte[]
+// Seek the first element less/greater than key byte[], key out of range is moved to the bound
 func (it *Iterator) Seek(key []byte) {
+	it.count = 0
+	if it.config.Reverse {
+		if it.upperBound != nil && bytes.Compare(key, it.upperBound) >= 0 {
+			it.seekBeforeUpperBound()
+			return
+		}
+	} else if it.lowerBound != nil && bytes.Compare(key, it.lowerBound) < 0 {
+		key = it.lowerBound
+	}
 	it.indexIterator.Seek(key)
-	it.skipToNext()
 }
 
 // Next go to next element
 func (it *Iterator) Next() {
 	it.indexIterator.Next()
-	it.skipToNext()
+	it.count++
 }
 
-// Valid check if has next element
+// Valid check if has next element in range and limit
 func (it *Iterator) Valid() bool {
-	return it.indexIterator.Valid()
+	if !it.indexIterator.Valid() {
+		return false
+	}
+
+	if it.config.Limit > 0 && it.count >= it.config.Limit {
+		return false
+	}
+
+	key := it.indexIterator.Key()
+	if it.config.Reverse {
+		return it.lowerBound == nil || bytes.Compare(key, it.lowerBound) >= 0
+	}
+	return it.upperBound == nil || bytes.Compare(key, it.upperBound) < 0
 }
 
 // Key current element key
@@ -49,12 +97,13 @@ func (it *Iterator) Key() []byte {
 	return it.indexIterator.Key()
 }
 
-// Value current element value
+// Value current element value, always nil for key only iterator
 func (it *Iterator) Value() ([]byte, error) {
-	logPos := it.indexIterator.Value()
-	it.db.mu.Lock()
-	defer it.db.mu.Unlock()
-	return it.db.getValueByLogPosition(logPos)
+	if it.config.KeyOnly {
+		return nil, nil
+	}
+
+	return it.db.getValueByLogPosition(it.indexIterator.Value())
 }
 
 // Close iterator, free resource
@@ -62,15 +111,23 @@ func (it *Iterator) Close() {
 	it.indexIterator.Close()
 }
 
-func (it *Iterator) skipToNext() {
-	if len(it.config.prefix) == 0 {
-		return
+// seekBeforeUpperBound move reverse iterator to the last element less than upper bound
+func (it *Iterator) seekBeforeUpperBound() {
+	it.indexIterator.Seek(it.upperBound)
+	if it.indexIterator.Valid() && bytes.Equal(it.indexIterator.Key(), it.upperBound) {
+		it.indexIterator.Next()
 	}
+}
 
-	// To find the key has prefix match with config.prefix
-	for ; it.Valid(); it.Next() {
-		if bytes.HasPrefix(it.Key(), it.config.prefix) {
-			break
+// prefixUpperBound the smallest key greater than all the keys with prefix, nil if there is no such key
+func prefixUpperBound(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			upperBound := make([]byte, i+1)
+			copy(upperBound, prefix)
+			upperBound[i]++
+			return upperBound
 		}
 	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package bitcask_go
 
 import (
+	"bitcask-go/index"
 	"bitcask-go/utils"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
@@ -115,7 +116,7 @@ func TestIterator_MultipleRecords_Reverse(t *testing.T) {
 
 	// reverse
 	iterConfig := DefaultIteratorConfig
-	iterConfig.reverse = true
+	iterConfig.Reverse = true
 	iter := database.NewIterator(iterConfig)
 	assert.NotNil(t, iter)
 
@@ -164,7 +165,7 @@ func TestIterator_MultipleRecords_Prefix(t *testing.T) {
 
 	// 1. match "a"
 	iterConfig := DefaultIteratorConfig
-	iterConfig.prefix = []byte("a")
+	iterConfig.Prefix = []byte("a")
 	iter := database.NewIterator(iterConfig)
 	assert.NotNil(t, iter)
 
@@ -181,7 +182,7 @@ func TestIterator_MultipleRecords_Prefix(t *testing.T) {
 	iter.Close()
 
 	// 2. match "b"
-	iterConfig.prefix = []byte("b")
+	iterConfig.Prefix = []byte("b")
 	iter = database.NewIterator(iterConfig)
 	assert.NotNil(t, iter)
 
@@ -197,10 +198,96 @@ func TestIterator_MultipleRecords_Prefix(t *testing.T) {
 	iter.Close()
 
 	// 3. match nothing
-	iterConfig.prefix = []byte("abcg")
+	iterConfig.Prefix = []byte("abcg")
 	iter = database.NewIterator(iterConfig)
 	iter.Rewind()
 	assert.NotNil(t, iter)
 	assert.False(t, iter.Valid())
 	iter.Close()
 }
+
+func TestIterator_RangeAndLimit(t *testing.T) {
+	for _, indexType := range []index.IndexerType{index.BTreeIndexType, index.ARTIndexType, index.BPlusTreeIndexType} {
+		configs := DefaultConfig
+		dir, _ := os.MkdirTemp("", "bitcask_test_iterator")
+		configs.DirPath = dir
+		configs.IndexerType = indexType
+
+		database, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+		assert.NotNil(t, database)
+
+		for _, key := range []string{"a", "ab", "abc", "abd", "b", "ba", "c"} {
+			err = database.Put([]byte(key), []byte(key))
+			assert.Nil(t, err)
+		}
+
+		collect := func(iterConfig IteratorConfig) []string {
+			iter := database.NewIterator(iterConfig)
+			defer iter.Close()
+			res := make([]string, 0)
+			for ; iter.Valid(); iter.Next() {
+				res = append(res, string(iter.Key()))
+			}
+			return res
+		}
+
+		iterConfig := DefaultIteratorConfig
+		iterConfig.Start = []byte("ab")
+		iterConfig.End = []byte("b")
+		assert.Equal(t, []string{"ab", "abc", "abd"}, collect(iterConfig))
+
+		iterConfig.Reverse = true
+		assert.Equal(t, []string{"abd", "abc", "ab"}, collect(iterConfig))
+
+		iterConfig.Limit = 2
+		assert.Equal(t, []string{"abd", "abc"}, collect(iterConfig))
+
+		// prefix with bounds
+		iterConfig = DefaultIteratorConfig
+		iterConfig.Prefix = []byte("ab")
+		iterConfig.Start = []byte("abd")
+		assert.Equal(t, []string{"abd"}, collect(iterConfig))
+
+		iterConfig = DefaultIteratorConfig
+		iterConfig.Prefix = []byte("b")
+		iterConfig.Reverse = true
+		assert.Equal(t, []string{"ba", "b"}, collect(iterConfig))
+
+		// upper bound is greater than all the keys
+		iterConfig = DefaultIteratorConfig
+		iterConfig.End = []byte("z")
+		iterConfig.Reverse = true
+		assert.Equal(t, []string{"c", "ba", "b", "abd", "abc", "ab", "a"}, collect(iterConfig))
+
+		// seek out of range is moved to bound
+		iterConfig = DefaultIteratorConfig
+		iterConfig.Start = []byte("b")
+		iter := database.NewIterator(iterConfig)
+		iter.Seek([]byte("a"))
+		assert.Equal(t, []byte("b"), iter.Key())
+		iter.Seek([]byte("bb"))
+		assert.Equal(t, []byte("c"), iter.Key())
+		iter.Close()
+
+		// key only
+		iterConfig = DefaultIteratorConfig
+		iterConfig.KeyOnly = true
+		iter = database.NewIterator(iterConfig)
+		assert.True(t, iter.Valid())
+		val, err := iter.Value()
+		assert.Nil(t, err)
+		assert.Nil(t, val)
+		iter.Close()
+
+		destroyDatabase(database)
+	}
+}
+
+func TestIterator_PrefixUpperBound(t *testing.T) {
+	assert.Equal(t, []byte("b"), prefixUpperBound([]byte("a")))
+	assert.Equal(t, []byte("ac"), prefixUpperBound([]byte("ab")))
+	assert.Equal(t, []byte("b"), prefixUpperBound([]byte{'a', 0xff}))
+	assert.Nil(t, prefixUpperBound([]byte{0xff, 0xff}))
+	assert.Nil(t, prefixUpperBound(nil))
+}
@@ -0,0 +1,69 @@
+package bitcask_go
+
+import (
+	"hash/maphash"
+	"sort"
+	"sync"
+)
+
+const keyLockStripeNum = 256
+
+// keyLocks striped locks of keys, writes of the same key append log record and update index in order,
+// while writes of different keys update index concurrently
+type keyLocks struct {
+	seed    maphash.Seed
+	stripes []sync.Mutex
+}
+
+func newKeyLocks(stripeNum int) *keyLocks {
+	return &keyLocks{
+		seed:    maphash.MakeSeed(),
+		stripes: make([]sync.Mutex, stripeNum),
+	}
+}
+
+// lock the stripe of key, return the unlock function
+func (kl *keyLocks) lock(key []byte) func() {
+	stripe := &kl.stripes[kl.stripeOf(key)]
+	stripe.Lock()
+	return stripe.Unlock
+}
+
+// lockKeys lock the stripes of keys in ascending order to avoid deadlock between batches
+func (kl *keyLocks) lockKeys(keys [][]byte) func() {
+	seen := make(map[int]struct{}, len(keys))
+	stripes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		idx := kl.stripeOf(key)
+		if _, ok := seen[idx]; !ok {
+			seen[idx] = struct{}{}
+			stripes = append(stripes, idx)
+		}
+	}
+	sort.Ints(stripes)
+
+	for _, idx := range stripes {
+		kl.stripes[idx].Lock()
+	}
+	return func() {
+		for i := len(stripes) - 1; i >= 0; i-- {
+			kl.stripes[stripes[i]].Unlock()
+		}
+	}
+}
+
+// lockAll lock all the stripes, used by writes of unknown keys like range deletion
+func (kl *keyLocks) lockAll() func() {
+	for i := range kl.stripes {
+		kl.stripes[i].Lock()
+	}
+	return func() {
+		for i := len(kl.stripes) - 1; i >= 0; i-- {
+			kl.stripes[i].Unlock()
+		}
+	}
+}
+
+func (kl *keyLocks) stripeOf(key []byte) int {
+	return int(maphash.Bytes(kl.seed, key) % uint64(len(kl.stripes)))
+}
@@ -0,0 +1,181 @@
+package bitcask_go
+
+import (
+	"bitcask-go/utils"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_ConcurrentReadWrite(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_concurrent")
+	configs.DirPath = dir
+	configs.DataFileSize = 32 * 1024
+	configs.MergeRatio = 0
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	const writers, keysPerWriter, rounds = 4, 50, 10
+	wg := new(sync.WaitGroup)
+	stop := make(chan struct{})
+
+	// writers own disjoint keys, and all of them write the shared keys
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				for i := 0; i < keysPerWriter; i++ {
+					key := []byte(fmt.Sprintf("writer-%d-key-%d", w, i))
+					assert.Nil(t, database.Put(key, []byte(fmt.Sprintf("value-%d", r))))
+					assert.Nil(t, database.Put(utils.GenerateTestKey(i), []byte(fmt.Sprintf("writer-%d-%d", w, r))))
+				}
+
+				batch := database.NewWriteBatch(DefaultWriteBatchConfig)
+				for i := 0; i < keysPerWriter; i += 2 {
+					assert.Nil(t, batch.Delete([]byte(fmt.Sprintf("writer-%d-key-%d", w, i))))
+				}
+				assert.Nil(t, batch.Commit())
+			}
+		}(w)
+	}
+
+	// readers and merge run until writers are done
+	readersWg := new(sync.WaitGroup)
+	readersWg.Add(3)
+	go func() {
+		defer readersWg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			_, err := database.Get(utils.GenerateTestKey(1))
+			if err != nil {
+				assert.Equal(t, ErrKeyNotFound, err)
+			}
+			database.MultiGet([][]byte{utils.GenerateTestKey(2), utils.GenerateTestKey(3)})
+		}
+	}()
+	go func() {
+		defer readersWg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			iter := database.NewIterator(DefaultIteratorConfig)
+			for iter.Rewind(); iter.Valid(); iter.Next() {
+				_, err := iter.Value()
+				assert.Nil(t, err)
+			}
+			iter.Close()
+			_, err := database.Stats()
+			assert.Nil(t, err)
+		}
+	}()
+	go func() {
+		defer readersWg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			err := database.Merge()
+			if err != nil {
+				assert.Equal(t, ErrMergingFileIsInProgress, err)
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	readersWg.Wait()
+
+	for w := 0; w < writers; w++ {
+		for i := 0; i < keysPerWriter; i++ {
+			value, err := database.Get([]byte(fmt.Sprintf("writer-%d-key-%d", w, i)))
+			if i%2 == 0 {
+				assert.Equal(t, ErrKeyNotFound, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, []byte(fmt.Sprintf("value-%d", rounds-1)), value)
+			}
+		}
+	}
+
+	// index must follow the order of log records, so the shared keys are the same after reopen
+	shared := make([][]byte, keysPerWriter)
+	for i := range shared {
+		shared[i], err = database.Get(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, database.Close())
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	for i := range shared {
+		value, err := database.Get(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, shared[i], value)
+	}
+}
+
+func TestDB_ConcurrentPutAndClose(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_concurrent")
+	configs.DirPath = dir
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	const writers = 8
+	acked := make([][][]byte, writers)
+	wg := new(sync.WaitGroup)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				key := []byte(fmt.Sprintf("writer-%d-key-%d", w, i))
+				err := database.Put(key, key)
+				if err != nil {
+					assert.Equal(t, ErrDBClosed, err)
+					return
+				}
+				acked[w] = append(acked[w], key)
+			}
+		}(w)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, database.Close())
+	wg.Wait()
+
+	// every acknowledged write is either in index snapshot or replayed after it
+	database, err = OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+	assert.NotNil(t, database.indexSnapshotPos)
+	var total int
+	for w := 0; w < writers; w++ {
+		total += len(acked[w])
+		for _, key := range acked[w] {
+			value, err := database.Get(key)
+			assert.Nil(t, err)
+			assert.Equal(t, key, value)
+		}
+	}
+	assert.Greater(t, total, 0)
+	assert.Equal(t, total, len(database.ListKeys()))
+}
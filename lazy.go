@@ -0,0 +1,162 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/storage"
+	"context"
+	"io"
+)
+
+// startLazyIndexLoad build index in background, writes are accepted while loading,
+// their index updates are pended and applied after all the data files are loaded
+func (db *DB) startLazyIndexLoad() error {
+	dataFiles := db.dataFilesForLoading()
+
+	activeFile := db.activeFile
+	if activeFile != nil {
+		// records are appended to active file while loading, so it can't stay in mmap io
+		if err := activeFile.SetIOType(db.fs, db.config.DirPath, db.activeFileIOType()); err != nil {
+			return err
+		}
+		// new records must follow the last record, not the pre-sized tail left by crash
+		end, err := db.findActiveFileEnd(activeFile)
+		if err != nil {
+			return err
+		}
+		activeFile.WriteOffset = end
+		if err := db.truncateActiveDataFile(); err != nil {
+			return err
+		}
+		db.indexLoadEndPos = &storage.LogRecordPos{Fid: activeFile.FileId, Offset: end}
+	}
+
+	go func() {
+		err := db.loadIndex(dataFiles)
+		if err == nil {
+			// finish loading, set back io type, active file is already set
+			db.mu.Lock()
+			if db.fileCache != nil {
+				err = db.fileCache.setIOType(db.inactiveFileIOType())
+			} else {
+				for _, dataFile := range dataFiles {
+					if dataFile == activeFile {
+						continue
+					}
+					if err = dataFile.SetIOType(db.fs, db.config.DirPath, db.inactiveFileIOType()); err != nil {
+						break
+					}
+				}
+			}
+			db.mu.Unlock()
+		}
+		db.finishIndexLoading(err)
+	}()
+
+	return nil
+}
+
+// findActiveFileEnd read records of active file until eof and return the end of the last one
+func (db *DB) findActiveFileEnd(activeFile *storage.DataFile) (int64, error) {
+	var offset int64 = 0
+	for {
+		_, size, err := activeFile.ReadLogRecord(offset)
+		if err != nil {
+			if err == io.EOF {
+				return offset, nil
+			}
+			return 0, err
+		}
+		offset += size
+	}
+}
+
+// finishIndexLoading apply pending index updates in written order and mark index ready
+func (db *DB) finishIndexLoading(err error) {
+	db.pendingMu.Lock()
+	defer db.pendingMu.Unlock()
+
+	if err == nil {
+		for _, pair := range db.pendingIndexRecords {
+			if err = db.updateLogRecordIndex(pair.Record, pair.Pos); err != nil {
+				break
+			}
+		}
+	}
+
+	db.indexLoadErr = err
+	db.pendingIndexRecords = nil
+	db.pendingIndexKeys = nil
+	db.indexReady.Store(true)
+	close(db.indexReadyCh)
+}
+
+// pendIndexUpdate keep index update in memory if index is still loading, return false if index is ready
+func (db *DB) pendIndexUpdate(logRecord *storage.LogRecord, pos *storage.LogRecordPos) bool {
+	if db.indexReady.Load() {
+		return false
+	}
+
+	db.pendingMu.Lock()
+	defer db.pendingMu.Unlock()
+
+	if db.indexReady.Load() {
+		return false
+	}
+
+	if db.pendingIndexKeys == nil {
+		db.pendingIndexKeys = make(map[string]*storage.LogRecordPositionPair)
+	}
+
+	// value is not needed to update index
+	pair := &storage.LogRecordPositionPair{
+		Record: &storage.LogRecord{Key: logRecord.Key, Type: logRecord.Type},
+		Pos:    pos,
+	}
+	db.pendingIndexRecords = append(db.pendingIndexRecords, pair)
+	db.pendingIndexKeys[string(logRecord.Key)] = pair
+
+	return true
+}
+
+// getIndexPosition get position from pending index updates while loading,
+// fall back to wait until index is ready if key is not written after opening
+func (db *DB) getIndexPosition(key []byte) (*storage.LogRecordPos, error) {
+	if !db.indexReady.Load() {
+		db.pendingMu.Lock()
+		pair, ok := db.pendingIndexKeys[string(key)]
+		db.pendingMu.Unlock()
+
+		if ok {
+			if pair.Record.Type == storage.LogRecordDeleted {
+				return nil, nil
+			}
+			return pair.Pos, nil
+		}
+
+		if err := db.waitIndexReady(); err != nil {
+			return nil, err
+		}
+	}
+
+	return index.CheckedGet(db.index, key)
+}
+
+func (db *DB) waitIndexReady() error {
+	<-db.indexReadyCh
+	return db.indexLoadErr
+}
+
+// Ready check if index is fully loaded
+func (db *DB) Ready() bool {
+	return db.indexReady.Load()
+}
+
+// WaitReady block until index is fully loaded or ctx is done, return the error of loading index
+func (db *DB) WaitReady(ctx context.Context) error {
+	select {
+	case <-db.indexReadyCh:
+		return db.indexLoadErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,188 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/utils"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDB_LazyIndexLoad(t *testing.T) {
+	for _, indexType := range []index.IndexerType{index.BTreeIndexType, index.ARTIndexType, index.BPlusTreeIndexType} {
+		configs := DefaultConfig
+		dir, _ := os.MkdirTemp("", "bitcask_test_lazy")
+		configs.DirPath = dir
+		configs.DataFileSize = 64 * 1024
+		configs.IndexerType = indexType
+
+		database, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+		n := 2000
+		for i := 0; i < n; i++ {
+			err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+			assert.Nil(t, err)
+		}
+		err = database.Close()
+		assert.Nil(t, err)
+
+		configs.LazyIndexLoad = true
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+		assert.NotNil(t, database)
+
+		// writes are served while loading
+		err = database.Put(utils.GenerateTestKey(n), []byte("new"))
+		assert.Nil(t, err)
+		err = database.Put(utils.GenerateTestKey(0), []byte("overwrite"))
+		assert.Nil(t, err)
+		err = database.Delete(utils.GenerateTestKey(1))
+		assert.Nil(t, err)
+
+		val, err := database.Get(utils.GenerateTestKey(n))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("new"), val)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = database.WaitReady(ctx)
+		cancel()
+		assert.Nil(t, err)
+		assert.True(t, database.Ready())
+
+		val, err = database.Get(utils.GenerateTestKey(0))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("overwrite"), val)
+		_, err = database.Get(utils.GenerateTestKey(1))
+		assert.Equal(t, ErrKeyNotFound, err)
+		val, err = database.Get(utils.GenerateTestKey(n - 1))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GenerateTestKey(n-1), val)
+		assert.Equal(t, n, len(database.ListKeys()))
+
+		stats, err := database.Stats()
+		assert.Nil(t, err)
+		assert.True(t, stats.IndexReady)
+
+		// reopen without lazy loading to check data on disk
+		err = database.Close()
+		assert.Nil(t, err)
+		configs.LazyIndexLoad = false
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+		assert.Equal(t, n, len(database.ListKeys()))
+		val, err = database.Get(utils.GenerateTestKey(0))
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("overwrite"), val)
+
+		destroyDatabase(database)
+	}
+}
+
+func TestDB_LazyIndexLoad_GetBlocksUntilReady(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_lazy")
+	configs.DirPath = dir
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = database.Close()
+	assert.Nil(t, err)
+
+	configs.LazyIndexLoad = true
+	database, err = OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	val, err := database.Get(utils.GenerateTestKey(50))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GenerateTestKey(50), val)
+	assert.True(t, database.Ready())
+
+	wb := database.NewWriteBatch(DefaultWriteBatchConfig)
+	err = wb.Put(utils.GenerateTestKey(200), utils.GenerateTestKey(200))
+	assert.Nil(t, err)
+	err = wb.Commit()
+	assert.Nil(t, err)
+	val, err = database.Get(utils.GenerateTestKey(200))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GenerateTestKey(200), val)
+}
+
+func TestDB_LazyIndexLoad_EmptyDatabase(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_lazy")
+	configs.DirPath = dir
+	configs.LazyIndexLoad = true
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	err = database.WaitReady(context.Background())
+	assert.Nil(t, err)
+	err = database.Put(utils.GenerateTestKey(1), utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	val, err := database.Get(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GenerateTestKey(1), val)
+}
+
+func TestDB_LazyIndexLoad_MMapWritesCrash(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_lazy")
+	configs.DirPath = dir
+	configs.EnableMMapWrites = true
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+	n := 100
+	for i := 0; i < n; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = database.Sync()
+	assert.Nil(t, err)
+
+	// copy of the pre-sized active file is like a crashed database
+	dir2, _ := os.MkdirTemp("", "bitcask_test_lazy2")
+	err = database.Backup(dir2)
+	assert.Nil(t, err)
+
+	configs2 := configs
+	configs2.DirPath = dir2
+	configs2.LazyIndexLoad = true
+	database2, err := OpenDatabase(configs2)
+	defer destroyDatabase(database2)
+	assert.Nil(t, err)
+
+	err = database2.Put(utils.GenerateTestKey(n), []byte("new"))
+	assert.Nil(t, err)
+	err = database2.WaitReady(context.Background())
+	assert.Nil(t, err)
+
+	err = database2.Sync()
+	assert.Nil(t, err)
+
+	// crash again, the record written after lazy opening should follow the old records
+	dir3, _ := os.MkdirTemp("", "bitcask_test_lazy3")
+	err = database2.Backup(dir3)
+	assert.Nil(t, err)
+
+	configs3 := configs
+	configs3.DirPath = dir3
+	database3, err := OpenDatabase(configs3)
+	defer destroyDatabase(database3)
+	assert.Nil(t, err)
+
+	val, err := database3.Get(utils.GenerateTestKey(n))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("new"), val)
+	assert.Equal(t, n+1, len(database3.ListKeys()))
+}
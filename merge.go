@@ -9,80 +9,81 @@ import (
 	"path"
 	"sort"
 	"strconv"
+	"time"
 )
 
 // Merge inactive files' log records, will create new merged file and hint file for all data file while call this function
 func (db *DB) Merge() error {
-	if db.activeFile == nil {
+	if db.metrics != nil {
+		defer db.metrics.observe(metricsMerge, time.Now())
+	}
+
+	// data files of replica are the same as primary's
+	if db.config.ReadOnly {
+		return ErrReadOnlyDatabase
+	}
+
+	db.mu.RLock()
+	noDataFile := db.activeFile == nil
+	db.mu.RUnlock()
+	if noDataFile {
 		return nil
 	}
 
-	db.mu.Lock()
-	if db.isMerging {
-		db.mu.Unlock()
+	if err := db.waitIndexReady(); err != nil {
+		return err
+	}
+
+	if !db.isMerging.CompareAndSwap(false, true) {
 		return ErrMergingFileIsInProgress
 	}
+	defer db.isMerging.Store(false)
 
 	// check file stats, if want to continue merge
 	stats, err := db.Stats()
 	if err != nil || stats.TotalFileSizeInBytes == int64(0) {
-		db.mu.Unlock()
 		return err
 	}
 
 	// check ratio
 	ratio := float32(stats.ReclaimableSizeInBytes) / float32(stats.TotalFileSizeInBytes)
 	if ratio < db.config.MergeRatio {
-		db.mu.Unlock()
 		return ErrMergeRatioNotSatisfied
 	}
 
 	// check available disk size to store merge file
-	needDiskSpaceInBytes := stats.TotalFileSizeInBytes - stats.ReclaimableSizeInBytes
-	availSizeInBytes, err := utils.AvailableSizeOnDiskInBytes()
-	if uint64(needDiskSpaceInBytes) > availSizeInBytes {
-		db.mu.Unlock()
-		return ErrNotEnoughDiskSpace
+	if !db.config.InMemory {
+		needDiskSpaceInBytes := stats.TotalFileSizeInBytes - stats.ReclaimableSizeInBytes
+		availSizeInBytes, _ := utils.AvailableSizeOnDiskInBytes()
+		if uint64(needDiskSpaceInBytes) > availSizeInBytes {
+			return ErrNotEnoughDiskSpace
+		}
 	}
 
-	db.isMerging = true
-	defer func() {
-		db.isMerging = false
-	}()
-
-	// update active file, this could be race condition, while other threads are updating or deleting data, and modify the active file
-	db.inactiveFiles[db.activeFile.FileId] = db.activeFile
-	if err := db.setActiveDataFile(); err != nil {
-		db.mu.Unlock()
+	// rotate active file, the records appended later are not merged
+	needMergeFiles, nonMergeFileId, err := db.rotateForMerge()
+	if err != nil || needMergeFiles == nil {
 		return err
 	}
-	var nonMergeFileId uint32 = db.activeFile.FileId
-
-	db.mu.Unlock()
-
-	var needMergeFiles []*storage.DataFile
-	for _, file := range db.inactiveFiles {
-		needMergeFiles = append(needMergeFiles, file)
-	}
 
 	sort.Slice(needMergeFiles, func(i, j int) bool {
 		return needMergeFiles[i].FileId < needMergeFiles[j].FileId
 	})
 
 	mergeDirPath := db.getMergeDirPath()
-	if err := buildMergeDirectory(mergeDirPath); err != nil {
+	if err := db.buildMergeDirectory(mergeDirPath); err != nil {
 		return err
 	}
 
 	// init another database instance to handle merge
-	mergeDb, err := newMergeDatabase(mergeDirPath)
+	mergeDb, err := db.newMergeDatabase(mergeDirPath)
 	if err != nil {
 		return err
 	}
 
 	var hintFile *storage.DataFile
 	if db.config.IndexerType != index.BPlusTreeIndexType {
-		hintFile, err = storage.OpenHintFile(mergeDirPath)
+		hintFile, err = storage.OpenHintFile(db.fs, mergeDirPath, db.fileIOType())
 		if err != nil {
 			return err
 		}
@@ -94,25 +95,38 @@ func (db *DB) Merge() error {
 	for _, dataFile := range needMergeFiles {
 		var offset int64 = 0
 
+		// keep file open while it's merged
+		release, err := db.openDataFile(dataFile)
+		if err != nil {
+			return err
+		}
+
 		for {
 			logRecord, size, err := dataFile.ReadLogRecord(offset)
 			if err != nil {
 				if err == io.EOF {
 					break
 				}
+				release()
 				return err
 			}
 
-			logRecordPos := db.index.Get(logRecord.Key)
+			logRecordPos, err := index.CheckedGet(db.index, logRecord.Key)
+			if err != nil {
+				release()
+				return err
+			}
 			if logRecordPos != nil && logRecordPos.Fid == dataFile.FileId && logRecordPos.Offset == offset {
 				pos, err := mergeDb.appendLogRecord(logRecord)
 				if err != nil {
+					release()
 					return err
 				}
 
 				if hintFile != nil {
 					encodeLogPosRecord := getEncodeLogRecordForPosition(logRecord.Key, pos)
 					if err := hintFile.Write(encodeLogPosRecord); err != nil {
+						release()
 						return err
 					}
 				}
@@ -121,6 +135,7 @@ func (db *DB) Merge() error {
 
 			offset += size
 		}
+		release()
 	}
 
 	if hintFile != nil {
@@ -129,12 +144,16 @@ func (db *DB) Merge() error {
 		}
 	}
 
+	// merged records must be durable before merge finish file is written
+	if err := mergeDb.Sync(); err != nil {
+		return err
+	}
 	if err := mergeDb.Close(); err != nil {
 		return err
 	}
 
 	// add the merge finish file
-	finishFile, err := storage.OpenMergeFinishFile(mergeDirPath)
+	finishFile, err := storage.OpenMergeFinishFile(db.fs, mergeDirPath, db.fileIOType())
 	if err != nil {
 		return err
 	}
@@ -151,25 +170,61 @@ func (db *DB) Merge() error {
 		return err
 	}
 
+	db.stats.merges.Add(1)
+	db.stats.lastMergeTime.Store(time.Now().UnixNano())
 	return nil
 }
 
+// rotateForMerge archive active file and open a new one, return the inactive files to merge and the first file not merged
+func (db *DB) rotateForMerge() ([]*storage.DataFile, uint32, error) {
+	db.appendMu.Lock()
+	defer db.appendMu.Unlock()
+
+	if db.activeFile == nil {
+		return nil, 0, nil
+	}
+
+	if err := db.rotateActiveDataFile(true); err != nil {
+		return nil, 0, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// files not exported by change data capture yet are kept
+	nonMergeFileId := db.activeFile.FileId
+	if db.cdc != nil {
+		nonMergeFileId = min(nonMergeFileId, db.cdc.mergeLimit())
+	}
+
+	needMergeFiles := make([]*storage.DataFile, 0, len(db.inactiveFiles))
+	for _, file := range db.inactiveFiles {
+		if file.FileId < nonMergeFileId {
+			needMergeFiles = append(needMergeFiles, file)
+		}
+	}
+	if len(needMergeFiles) == 0 {
+		return nil, 0, nil
+	}
+	return needMergeFiles, nonMergeFileId, nil
+}
+
 // loadMergeFile: find merge files and remove merge dir
 func (db *DB) loadMergeFile() error {
 	mergeDirPath := db.getMergeDirPath()
 
 	// 1. check if merge file exists and remove merge dir
-	if _, err := os.Stat(mergeDirPath); err != nil {
+	if _, err := db.fs.Stat(mergeDirPath); err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
 		return err
 	}
 	defer func() {
-		_ = os.RemoveAll(mergeDirPath)
+		_ = db.fs.RemoveAll(mergeDirPath)
 	}()
 
-	dirEntries, err := os.ReadDir(mergeDirPath)
+	dirEntries, err := db.fs.ReadDir(mergeDirPath)
 	if err != nil {
 		return err
 	}
@@ -177,7 +232,8 @@ func (db *DB) loadMergeFile() error {
 	var mergeFileNames []string
 	var mergeFinished bool
 	for _, entry := range dirEntries {
-		if entry.Name() == storage.SequenceNumberFileName || entry.Name() == lockFileName {
+		if entry.Name() == storage.SequenceNumberFileName || entry.Name() == lockFileName ||
+			entry.Name() == storage.IndexSnapshotFileName {
 			continue
 		}
 		if entry.Name() == storage.MergeFinishFileName {
@@ -190,8 +246,11 @@ func (db *DB) loadMergeFile() error {
 		return nil
 	}
 
-	// 2. remove all inactive data files in original db dir
-	nonMergeFileId, err := getNonMergedFileId(mergeDirPath)
+	// 2. remove all inactive data files in original db dir, index snapshot is stale as well
+	if err := db.fs.RemoveAll(storage.GetIndexSnapshotFileName(db.config.DirPath)); err != nil {
+		return err
+	}
+	nonMergeFileId, err := db.getNonMergedFileId(mergeDirPath)
 	if err != nil {
 		return err
 	}
@@ -199,8 +258,8 @@ func (db *DB) loadMergeFile() error {
 	var fileId uint32 = initialDataFileId
 	for ; fileId < nonMergeFileId; fileId++ {
 		dataFileName := storage.GetDataFileName(db.config.DirPath, fileId)
-		if _, err := os.Stat(dataFileName); err == nil {
-			if err := os.Remove(dataFileName); err != nil {
+		if _, err := db.fs.Stat(dataFileName); err == nil {
+			if err := db.fs.Remove(dataFileName); err != nil {
 				return err
 			}
 		}
@@ -210,7 +269,7 @@ func (db *DB) loadMergeFile() error {
 	for _, fileName := range mergeFileNames {
 		srcFile := path.Join(mergeDirPath, fileName)
 		dstFile := path.Join(db.config.DirPath, fileName)
-		if err := os.Rename(srcFile, dstFile); err != nil {
+		if err := db.fs.Rename(srcFile, dstFile); err != nil {
 			return err
 		}
 	}
@@ -221,14 +280,14 @@ func (db *DB) loadMergeFile() error {
 // loadHintFile to load index from hint file
 func (db *DB) loadHintFile() error {
 	hintFileName := storage.GetHintFileName(db.config.DirPath)
-	if _, err := os.Stat(hintFileName); err != nil {
+	if _, err := db.fs.Stat(hintFileName); err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
 		return err
 	}
 
-	hintFile, err := storage.OpenHintFile(db.config.DirPath)
+	hintFile, err := storage.OpenHintFile(db.fs, db.config.DirPath, db.fileIOType())
 	if err != nil {
 		return err
 	}
@@ -243,8 +302,12 @@ func (db *DB) loadHintFile() error {
 			return err
 		}
 
+		// merged data files only have the records in hint file
 		logRecordPos, _ := storage.DecodeLogRecordPosition(logRecord.Value)
-		db.index.Put(logRecord.Key, logRecordPos)
+		if _, err := index.CheckedPut(db.index, logRecord.Key, logRecordPos); err != nil {
+			return err
+		}
+		db.stats.addRecord(logRecordPos.Fid, int64(logRecordPos.LogRecordSize))
 		offset += size
 	}
 
@@ -257,8 +320,8 @@ func (db *DB) getMergeDirPath() string {
 	return path.Join(dir, base+mergeDirNameSuffix)
 }
 
-func getNonMergedFileId(dirPath string) (uint32, error) {
-	finishFile, err := storage.OpenMergeFinishFile(dirPath)
+func (db *DB) getNonMergedFileId(dirPath string) (uint32, error) {
+	finishFile, err := storage.OpenMergeFinishFile(db.fs, dirPath, db.fileIOType())
 	if err != nil {
 		return 0, err
 	}
@@ -275,24 +338,27 @@ func getNonMergedFileId(dirPath string) (uint32, error) {
 	return uint32(nonMergeFileId), nil
 }
 
-func buildMergeDirectory(dirPath string) error {
+func (db *DB) buildMergeDirectory(dirPath string) error {
 	// file exist
-	if _, err := os.Stat(dirPath); err == nil {
-		if err := os.RemoveAll(dirPath); err != nil {
+	if _, err := db.fs.Stat(dirPath); err == nil {
+		if err := db.fs.RemoveAll(dirPath); err != nil {
 			return err
 		}
 	}
 
-	if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
+	if err := db.fs.MkdirAll(dirPath, os.ModePerm); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func newMergeDatabase(dirPath string) (*DB, error) {
+func (db *DB) newMergeDatabase(dirPath string) (*DB, error) {
 	mergeConfig := DefaultConfig
 	mergeConfig.DirPath = dirPath
+	mergeConfig.InMemory = db.config.InMemory
+	// merged files are installed from the same file system
+	mergeConfig.FileSystem = db.fs
 	return OpenDatabase(mergeConfig)
 }
 
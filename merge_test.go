@@ -1,10 +1,12 @@
 package bitcask_go
 
 import (
+	"bitcask-go/fio"
 	"bitcask-go/index"
 	"bitcask-go/utils"
 	"github.com/stretchr/testify/assert"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -291,7 +293,7 @@ func TestDB_Merge_NewDataIsWritingWhileMerging(t *testing.T) {
 		// delete all
 		for i := 0; i < n; i++ {
 			key := utils.GenerateTestKey(i)
-			err = database.Delete(utils.GenerateTestKey(i))
+			err := database.Delete(utils.GenerateTestKey(i))
 			delete(keyValMap, string(key))
 			assert.Nil(t, err)
 		}
@@ -299,7 +301,7 @@ func TestDB_Merge_NewDataIsWritingWhileMerging(t *testing.T) {
 		// add new data
 		for i := n; i < n+n2; i++ {
 			key, val := utils.GenerateTestKey(i), utils.GenerateRandomValue(1<<3)
-			err = database.Put(utils.GenerateTestKey(i), val)
+			err := database.Put(utils.GenerateTestKey(i), val)
 			keyValMap[string(key)] = val
 			assert.Nil(t, err)
 		}
@@ -333,6 +335,25 @@ func TestDB_Merge_NewDataIsWritingWhileMerging(t *testing.T) {
 	}
 }
 
+// blockingFileSystem block the first file opened under path until released
+type blockingFileSystem struct {
+	fio.FileSystem
+	path     string
+	once     *sync.Once
+	entered  chan struct{}
+	released chan struct{}
+}
+
+func (fs *blockingFileSystem) OpenFile(name string, ioType fio.IOType) (fio.IOManager, error) {
+	if strings.HasPrefix(name, fs.path) {
+		fs.once.Do(func() {
+			close(fs.entered)
+			<-fs.released
+		})
+	}
+	return fs.FileSystem.OpenFile(name, ioType)
+}
+
 func TestDB_Merge_ByTwoThreads(t *testing.T) {
 	configs := DefaultConfig
 	dir, _ := os.MkdirTemp("", "bitcask_test_merge")
@@ -340,35 +361,34 @@ func TestDB_Merge_ByTwoThreads(t *testing.T) {
 	configs.DataFileSize = 8 * 1024 * 1024
 	configs.MergeRatio = 0
 
+	// the first merge is held while it writes merge files
+	fs := &blockingFileSystem{
+		FileSystem: fio.NewFileSystem(fio.StandardFileIOType),
+		path:       dir + mergeDirNameSuffix,
+		once:       new(sync.Once),
+		entered:    make(chan struct{}),
+		released:   make(chan struct{}),
+	}
+	configs.FileSystem = fs
+
 	database, err := OpenDatabase(configs)
 	defer destroyDatabase(database)
 	assert.Nil(t, err)
 	assert.NotNil(t, database)
+	defer destroyMergeDir(database)
 
 	err = database.Put(utils.GenerateTestKey(1), utils.GenerateTestKey(1))
 	assert.Nil(t, err)
 
-	wg := new(sync.WaitGroup)
-	wg.Add(1)
-	var res []error
-
+	errCh := make(chan error)
 	go func() {
-		defer wg.Done()
-		err = database.Merge()
-		res = append(res, err)
+		errCh <- database.Merge()
 	}()
+	<-fs.entered
 
 	err = database.Merge()
-	defer destroyMergeDir(database)
-	res = append(res, err)
+	assert.Equal(t, ErrMergingFileIsInProgress, err)
 
-	wg.Wait()
-
-	assert.Equal(t, 2, len(res))
-
-	if res[0] == nil {
-		assert.Equal(t, ErrMergingFileIsInProgress, res[1])
-	} else {
-		assert.Equal(t, ErrMergingFileIsInProgress, res[0])
-	}
+	close(fs.released)
+	assert.Nil(t, <-errCh)
 }
@@ -0,0 +1,124 @@
+package bitcask_go
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+type metricsOperation = int
+
+const (
+	metricsPut metricsOperation = iota
+	metricsGet
+	metricsDelete
+	metricsCommit
+	metricsSync
+	metricsMerge
+	metricsOperationNum
+)
+
+// names of operations in label of latency histogram
+var metricsOperationNames = [metricsOperationNum]string{"put", "get", "delete", "commit", "sync", "merge"}
+
+// upper bounds of latency buckets in seconds, from 10us for in memory reads to 10s for merging
+var latencyBuckets = [...]float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05,
+	0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// latencyHistogram counts of latency in each bucket, the last one is +Inf
+type latencyHistogram struct {
+	counts   [len(latencyBuckets) + 1]atomic.Uint64
+	sumNanos atomic.Int64
+}
+
+func (h *latencyHistogram) observe(latency time.Duration) {
+	seconds := latency.Seconds()
+	bucket := len(latencyBuckets)
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			bucket = i
+			break
+		}
+	}
+	h.counts[bucket].Add(1)
+	h.sumNanos.Add(int64(latency))
+}
+
+// metrics latency of operations, nil if Config.EnableMetrics is not set so nothing is recorded
+type metrics struct {
+	latencies [metricsOperationNum]latencyHistogram
+}
+
+// observe record latency of op started at start, it's deferred at the beginning of operation
+func (m *metrics) observe(op metricsOperation, start time.Time) {
+	m.latencies[op].observe(time.Since(start))
+}
+
+// MetricsHandler serve metrics in Prometheus text format: latency histograms of operations if Config.EnableMetrics is set,
+// and gauges of key count, data file count and reclaimable bytes
+func (db *DB) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		stats, err := db.Stats()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = db.writeMetrics(w, stats)
+	})
+}
+
+// writeMetrics write metrics in Prometheus text exposition format
+func (db *DB) writeMetrics(w io.Writer, stats Stats) error {
+	writer := bufio.NewWriter(w)
+
+	if db.metrics != nil {
+		const name = "bitcask_operation_duration_seconds"
+		fmt.Fprintf(writer, "# HELP %s Latency of database operations.\n", name)
+		fmt.Fprintf(writer, "# TYPE %s histogram\n", name)
+		for op := range db.metrics.latencies {
+			histogram := &db.metrics.latencies[op]
+			label := metricsOperationNames[op]
+			// buckets are cumulative, count is taken from buckets so they're consistent
+			var count uint64
+			for i, bound := range latencyBuckets {
+				count += histogram.counts[i].Load()
+				fmt.Fprintf(writer, "%s_bucket{operation=%q,le=%q} %d\n", name, label, strconv.FormatFloat(bound, 'g', -1, 64), count)
+			}
+			count += histogram.counts[len(latencyBuckets)].Load()
+			fmt.Fprintf(writer, "%s_bucket{operation=%q,le=\"+Inf\"} %d\n", name, label, count)
+			fmt.Fprintf(writer, "%s_sum{operation=%q} %s\n", name, label,
+				strconv.FormatFloat(time.Duration(histogram.sumNanos.Load()).Seconds(), 'g', -1, 64))
+			fmt.Fprintf(writer, "%s_count{operation=%q} %d\n", name, label, count)
+		}
+	}
+
+	gauges := []struct {
+		name  string
+		help  string
+		value int64
+	}{
+		{"bitcask_keys", "Number of keys.", int64(stats.KeyNum)},
+		{"bitcask_data_files", "Number of data files.", int64(stats.DataFileNum)},
+		{"bitcask_reclaimable_bytes", "Bytes of data files reclaimable by merging.", stats.ReclaimableSizeInBytes},
+	}
+	for _, gauge := range gauges {
+		fmt.Fprintf(writer, "# HELP %s %s\n", gauge.name, gauge.help)
+		fmt.Fprintf(writer, "# TYPE %s gauge\n", gauge.name)
+		fmt.Fprintf(writer, "%s %d\n", gauge.name, gauge.value)
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,119 @@
+package bitcask_go
+
+import (
+	"bitcask-go/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDB_MetricsHandler(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_metrics")
+	configs.DirPath = dir
+	configs.EnableMetrics = true
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, database.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+	_, err = database.Get(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Nil(t, database.Delete(utils.GenerateTestKey(2)))
+	batch := database.NewWriteBatch(DefaultWriteBatchConfig)
+	assert.Nil(t, batch.Put(utils.GenerateTestKey(3), utils.GenerateRandomValue(64)))
+	assert.Nil(t, batch.Commit())
+	assert.Nil(t, database.Sync())
+
+	recorder := httptest.NewRecorder()
+	database.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.True(t, strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, "# TYPE bitcask_operation_duration_seconds histogram\n")
+	assert.Contains(t, body, `bitcask_operation_duration_seconds_bucket{operation="put",le="+Inf"} 10`+"\n")
+	assert.Contains(t, body, `bitcask_operation_duration_seconds_count{operation="put"} 10`+"\n")
+	assert.Contains(t, body, `bitcask_operation_duration_seconds_count{operation="get"} 1`+"\n")
+	assert.Contains(t, body, `bitcask_operation_duration_seconds_count{operation="delete"} 1`+"\n")
+	assert.Contains(t, body, `bitcask_operation_duration_seconds_count{operation="commit"} 1`+"\n")
+	// batch commit syncs by default, so it's counted along with Sync
+	assert.Contains(t, body, `bitcask_operation_duration_seconds_count{operation="sync"} 2`+"\n")
+	assert.Contains(t, body, `bitcask_operation_duration_seconds_count{operation="merge"} 0`+"\n")
+	assert.Contains(t, body, `bitcask_operation_duration_seconds_bucket{operation="put",le="1e-05"}`)
+	assert.Contains(t, body, "bitcask_keys 9\n")
+	assert.Contains(t, body, "bitcask_data_files 1\n")
+	assert.Contains(t, body, "# TYPE bitcask_reclaimable_bytes gauge\n")
+}
+
+func TestDB_MetricsHandler_SyncByPolicy(t *testing.T) {
+	for _, policy := range []SyncPolicy{{Type: SyncEveryWrite}, {Type: SyncEveryInterval, Interval: 10 * time.Millisecond}} {
+		configs := DefaultConfig
+		dir, _ := os.MkdirTemp("", "bitcask_test_metrics")
+		configs.DirPath = dir
+		configs.EnableMetrics = true
+		configs.SyncPolicy = policy
+		database, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, database.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+		}
+
+		// syncs after writes and by background ticker are recorded as well as Sync
+		assert.Eventually(t, func() bool {
+			stats, err := database.Stats()
+			assert.Nil(t, err)
+			return stats.SyncCount > 0
+		}, time.Second, 5*time.Millisecond)
+		stats, err := database.Stats()
+		assert.Nil(t, err)
+		recorder := httptest.NewRecorder()
+		database.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		assert.Contains(t, recorder.Body.String(),
+			fmt.Sprintf(`bitcask_operation_duration_seconds_count{operation="sync"} %d`+"\n", stats.SyncCount))
+		if policy.Type == SyncEveryWrite {
+			assert.Equal(t, uint64(3), stats.SyncCount)
+		}
+
+		destroyDatabase(database)
+	}
+}
+
+func TestDB_MetricsHandler_Disabled(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_metrics")
+	configs.DirPath = dir
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+	assert.Nil(t, database.metrics)
+
+	assert.Nil(t, database.Put(utils.GenerateTestKey(1), utils.GenerateRandomValue(64)))
+
+	recorder := httptest.NewRecorder()
+	database.MetricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.NotContains(t, recorder.Body.String(), "bitcask_operation_duration_seconds")
+	assert.Contains(t, recorder.Body.String(), "bitcask_keys 1\n")
+}
+
+func TestLatencyHistogram(t *testing.T) {
+	var histogram latencyHistogram
+	histogram.observe(5 * time.Microsecond)
+	histogram.observe(2 * time.Millisecond)
+	histogram.observe(20 * time.Second)
+
+	assert.Equal(t, uint64(1), histogram.counts[0].Load())
+	assert.Equal(t, uint64(1), histogram.counts[7].Load())
+	assert.Equal(t, uint64(1), histogram.counts[len(latencyBuckets)].Load())
+	assert.Equal(t, int64(20*time.Second+2*time.Millisecond+5*time.Microsecond), histogram.sumNanos.Load())
+}
@@ -0,0 +1,134 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/storage"
+	"sort"
+)
+
+const (
+	// records closer than this gap in the same file are read in one call
+	multiGetMaxGapInBytes = 4 * 1024
+	// max size of one coalesced read
+	multiGetMaxReadInBytes = 1024 * 1024
+)
+
+type multiGetEntry struct {
+	index int // index in keys
+	pos   *storage.LogRecordPos
+}
+
+// MultiGet get values of keys in one call, values and errors are returned in the same order as keys.
+// Positions are sorted by file and offset, and adjacent records are read together to reduce seeks
+func (db *DB) MultiGet(keys [][]byte) ([][]byte, []error) {
+	values := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+
+	if !db.isOpen.Load() {
+		for i := range errs {
+			errs[i] = ErrDBClosed
+		}
+		return values, errs
+	}
+
+	// positions are resolved after index is loaded, waiting for it under read lock blocks the loader
+	if err := db.waitIndexReady(); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return values, errs
+	}
+
+	// 1. resolve all the positions in one critical section, writes of the keys are blocked by key locks,
+	// so values are read at the same point of time. It's done before db.mu is taken as Get does,
+	// index like compact hash index takes read lock to read keys on disk
+	unlockKeys := db.keyLocks.lockKeys(keys)
+	entries := make([]*multiGetEntry, 0, len(keys))
+	for i, key := range keys {
+		if len(key) == 0 {
+			errs[i] = ErrKeyIsEmpty
+			continue
+		}
+
+		pos, err := index.CheckedGet(db.index, key)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if pos == nil {
+			errs[i] = ErrKeyNotFound
+			continue
+		}
+		entries = append(entries, &multiGetEntry{index: i, pos: pos})
+	}
+	// data files can't be removed or rotated under read lock, it's taken before key locks are released
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	unlockKeys()
+
+	// 2. sort by file and offset
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].pos.Fid != entries[j].pos.Fid {
+			return entries[i].pos.Fid < entries[j].pos.Fid
+		}
+		return entries[i].pos.Offset < entries[j].pos.Offset
+	})
+
+	// 3. read each run of close records at once
+	for start := 0; start < len(entries); {
+		end := start + 1
+		runStart := entries[start].pos.Offset
+		runEnd := runStart + int64(entries[start].pos.LogRecordSize)
+		for end < len(entries) {
+			pos := entries[end].pos
+			if pos.Fid != entries[start].pos.Fid || pos.Offset-runEnd > multiGetMaxGapInBytes ||
+				pos.Offset+int64(pos.LogRecordSize)-runStart > multiGetMaxReadInBytes {
+				break
+			}
+			runEnd = max(runEnd, pos.Offset+int64(pos.LogRecordSize))
+			end++
+		}
+
+		db.readMultiGetRun(entries[start:end], runStart, runEnd, values, errs)
+		start = end
+	}
+
+	return values, errs
+}
+
+func (db *DB) readMultiGetRun(entries []*multiGetEntry, runStart, runEnd int64, values [][]byte, errs []error) {
+	setErr := func(err error) {
+		for _, entry := range entries {
+			errs[entry.index] = err
+		}
+	}
+
+	dataFile, release, err := db.acquireDataFile(entries[0].pos.Fid)
+	if err != nil {
+		setErr(err)
+		return
+	}
+	defer release()
+
+	buf, err := dataFile.ReadBytes(runEnd-runStart, runStart)
+	if err != nil {
+		setErr(err)
+		return
+	}
+	db.stats.bytesRead.Add(uint64(len(buf)))
+
+	for _, entry := range entries {
+		offset := entry.pos.Offset - runStart
+		logRecord, _, err := storage.DecodeLogRecord(buf[offset : offset+int64(entry.pos.LogRecordSize)])
+		if err != nil {
+			errs[entry.index] = err
+			continue
+		}
+		if logRecord.Type == storage.LogRecordDeleted {
+			errs[entry.index] = ErrKeyNotFound
+			continue
+		}
+		values[entry.index] = logRecord.Value
+		db.stats.gets.Add(1)
+	}
+}
@@ -0,0 +1,112 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/utils"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDB_MultiGet(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_multi_get")
+	configs.DirPath = dir
+	configs.DataFileSize = 32 * 1024
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	n := 1000
+	for i := 0; i < n; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = database.Delete(utils.GenerateTestKey(5))
+	assert.Nil(t, err)
+	err = database.Put(utils.GenerateTestKey(6), []byte("overwrite"))
+	assert.Nil(t, err)
+
+	keys := [][]byte{
+		utils.GenerateTestKey(999),
+		utils.GenerateTestKey(1),
+		nil,
+		utils.GenerateTestKey(5),
+		utils.GenerateTestKey(6),
+		[]byte("not-exist"),
+		utils.GenerateTestKey(2),
+		utils.GenerateTestKey(500),
+		utils.GenerateTestKey(1),
+	}
+	values, errs := database.MultiGet(keys)
+	assert.Equal(t, len(keys), len(values))
+	assert.Equal(t, len(keys), len(errs))
+
+	assert.Nil(t, errs[0])
+	assert.Equal(t, utils.GenerateTestKey(999), values[0])
+	assert.Nil(t, errs[1])
+	assert.Equal(t, utils.GenerateTestKey(1), values[1])
+	assert.Equal(t, ErrKeyIsEmpty, errs[2])
+	assert.Equal(t, ErrKeyNotFound, errs[3])
+	assert.Nil(t, errs[4])
+	assert.Equal(t, []byte("overwrite"), values[4])
+	assert.Equal(t, ErrKeyNotFound, errs[5])
+	assert.Nil(t, errs[6])
+	assert.Equal(t, utils.GenerateTestKey(2), values[6])
+	assert.Nil(t, errs[7])
+	assert.Equal(t, utils.GenerateTestKey(500), values[7])
+	assert.Nil(t, errs[8])
+	assert.Equal(t, utils.GenerateTestKey(1), values[8])
+
+	// appending to one value does not overwrite the value next to it
+	values[1] = append(values[1], 'x')
+	assert.Equal(t, utils.GenerateTestKey(2), values[6])
+
+	err = database.Close()
+	assert.Nil(t, err)
+	_, errs = database.MultiGet(keys)
+	assert.Equal(t, ErrDBClosed, errs[0])
+}
+
+func TestDB_MultiGet_CompactHashIndexWithRotation(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_multi_get")
+	configs.DirPath = dir
+	configs.DataFileSize = 4 * 1024
+	configs.IndexerType = index.CompactHashIndexType
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	n := 100
+	keys := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		keys[i] = utils.GenerateTestKey(i)
+		err = database.Put(keys[i], keys[i])
+		assert.Nil(t, err)
+	}
+
+	// writes of other keys rotate active file while positions are resolved
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := n; i < 20*n; i++ {
+			assert.Nil(t, database.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(128)))
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		values, errs := database.MultiGet(keys)
+		for i := range keys {
+			assert.Nil(t, errs[i])
+			assert.Equal(t, keys[i], values[i])
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package raft
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	hardStateFileName = "hardstate"
+	logFileName       = "log"
+	snapshotFileName  = "snapshot"
+	tempFilePattern   = "*.tmp"
+
+	// crc | index | term of snapshot before its data
+	snapshotHeaderSize = 4 + 8 + 8
+	// crc | uvarint payload size
+	maxEntryHeaderSize = 4 + binary.MaxVarintLen64
+)
+
+// FileStorage persist raft state in directory. Hard state and snapshot are replaced by renaming,
+// entries after snapshot are appended to log file as:
+// crc | uvarint size | uvarint index | uvarint term | uvarint dataSize+1, 0 for nil data | data
+type FileStorage struct {
+	dir        string
+	mu         *sync.Mutex
+	logFile    *os.File
+	snapshot   *Snapshot
+	firstIndex uint64   // index of the first entry in log file
+	terms      []uint64 // term of each entry in log file
+	offsets    []int64  // offset of each entry in log file, followed by the end of log
+	loaded     []Entry  // entries read when opening, dropped once they're returned by InitialState
+}
+
+// OpenFileStorage open storage in dir, torn entry at the end of log is truncated
+func OpenFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	// snapshots not committed before crash
+	tempFiles, err := filepath.Glob(filepath.Join(dir, tempFilePattern))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range tempFiles {
+		if err := os.Remove(name); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &FileStorage{dir: dir, mu: new(sync.Mutex)}
+	if s.snapshot, err = s.readSnapshotHeader(); err != nil {
+		return nil, err
+	}
+	if err := s.loadLog(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStorage) InitialState() (HardState, *Snapshot, []Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var state HardState
+	buf, err := os.ReadFile(filepath.Join(s.dir, hardStateFileName))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return HardState{}, nil, nil, err
+	default:
+		if len(buf) < 4 || crc32.ChecksumIEEE(buf[4:]) != binary.LittleEndian.Uint32(buf) {
+			return HardState{}, nil, nil, ErrCorruptedState
+		}
+		var n, m int
+		state.Term, n = binary.Uvarint(buf[4:])
+		state.Vote, m = binary.Uvarint(buf[4+max(n, 0):])
+		if n <= 0 || m <= 0 {
+			return HardState{}, nil, nil, ErrCorruptedState
+		}
+	}
+
+	entries := s.loaded
+	s.loaded = nil
+	return state, s.snapshot, entries, nil
+}
+
+func (s *FileStorage) SaveHardState(state HardState) error {
+	buf := make([]byte, 4+2*binary.MaxVarintLen64)
+	n := 4 + binary.PutUvarint(buf[4:], state.Term)
+	n += binary.PutUvarint(buf[n:], state.Vote)
+	binary.LittleEndian.PutUint32(buf, crc32.ChecksumIEEE(buf[4:n]))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replaceFile(hardStateFileName, buf[:n])
+}
+
+func (s *FileStorage) Append(entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// entries included in snapshot are committed and never replaced
+	for len(entries) > 0 && entries[0].Index < s.firstIndex {
+		entries = entries[1:]
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if entries[0].Index > s.lastIndex()+1 {
+		return ErrLogNotContinuous
+	}
+
+	// conflicting entries are truncated
+	position := int(entries[0].Index - s.firstIndex)
+	end := s.offsets[position]
+	if position < len(s.terms) {
+		if err := s.logFile.Truncate(end); err != nil {
+			return err
+		}
+		s.terms = s.terms[:position]
+		s.offsets = s.offsets[:position+1]
+	}
+
+	var buf []byte
+	for _, entry := range entries {
+		buf = appendEntry(buf, entry)
+		s.terms = append(s.terms, entry.Term)
+		s.offsets = append(s.offsets, end+int64(len(buf)))
+	}
+	if _, err := s.logFile.WriteAt(buf, end); err != nil {
+		return err
+	}
+	return s.logFile.Sync()
+}
+
+func (s *FileStorage) CreateSnapshot(snapshot Snapshot) (SnapshotWriter, error) {
+	file, err := os.CreateTemp(s.dir, snapshotFileName+"-"+tempFilePattern)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, snapshotHeaderSize)
+	binary.LittleEndian.PutUint64(header[4:], snapshot.Index)
+	binary.LittleEndian.PutUint64(header[12:], snapshot.Term)
+	binary.LittleEndian.PutUint32(header, crc32.ChecksumIEEE(header[4:]))
+	if _, err := file.Write(header); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return nil, err
+	}
+	return &fileSnapshotWriter{storage: s, snapshot: snapshot, file: file}, nil
+}
+
+func (s *FileStorage) OpenSnapshot() (Snapshot, SnapshotReader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snapshot == nil {
+		return Snapshot{}, nil, ErrNoSnapshot
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, snapshotFileName))
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return Snapshot{}, nil, err
+	}
+	return *s.snapshot, &fileSnapshotReader{file: file, size: stat.Size() - snapshotHeaderSize}, nil
+}
+
+// Close log file, storage can't be used after closed
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logFile.Close()
+}
+
+func (s *FileStorage) lastIndex() uint64 {
+	return s.firstIndex + uint64(len(s.terms)) - 1
+}
+
+func (s *FileStorage) readSnapshotHeader() (*Snapshot, error) {
+	file, err := os.Open(filepath.Join(s.dir, snapshotFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	header := make([]byte, snapshotHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, ErrCorruptedState
+	}
+	if crc32.ChecksumIEEE(header[4:]) != binary.LittleEndian.Uint32(header) {
+		return nil, ErrCorruptedState
+	}
+	return &Snapshot{
+		Index: binary.LittleEndian.Uint64(header[4:]),
+		Term:  binary.LittleEndian.Uint64(header[12:]),
+	}, nil
+}
+
+// loadLog read entries of log file, and drop the ones included in snapshot if crash happened before compacting
+func (s *FileStorage) loadLog() error {
+	path := filepath.Join(s.dir, logFileName)
+	buf, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if s.logFile, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644); err != nil {
+		return err
+	}
+
+	s.offsets = []int64{0}
+	var offset int64
+	for offset < int64(len(buf)) {
+		entry, size, ok := decodeEntry(buf[offset:])
+		if !ok || (len(s.loaded) > 0 && entry.Index != s.loaded[len(s.loaded)-1].Index+1) {
+			break
+		}
+		s.loaded = append(s.loaded, entry)
+		s.terms = append(s.terms, entry.Term)
+		offset += size
+		s.offsets = append(s.offsets, offset)
+	}
+	// torn entry of the last append
+	if offset < int64(len(buf)) {
+		if err := s.logFile.Truncate(offset); err != nil {
+			_ = s.logFile.Close()
+			return err
+		}
+	}
+
+	switch {
+	case len(s.loaded) > 0:
+		s.firstIndex = s.loaded[0].Index
+	case s.snapshot != nil:
+		s.firstIndex = s.snapshot.Index + 1
+	default:
+		s.firstIndex = 1
+	}
+	if s.snapshot != nil {
+		if err := s.compact(*s.snapshot); err != nil {
+			_ = s.logFile.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+// compact drop entries included in snapshot, or all the entries if log doesn't have the last entry of snapshot
+func (s *FileStorage) compact(snapshot Snapshot) error {
+	if snapshot.Index < s.firstIndex {
+		return nil
+	}
+	dropped := len(s.terms)
+	if snapshot.Index <= s.lastIndex() && s.terms[snapshot.Index-s.firstIndex] == snapshot.Term {
+		dropped = int(snapshot.Index - s.firstIndex + 1)
+	}
+
+	// rewrite the entries kept, they are the ones not applied yet
+	start, end := s.offsets[dropped], s.offsets[len(s.offsets)-1]
+	buf := make([]byte, end-start)
+	if _, err := s.logFile.ReadAt(buf, start); err != nil {
+		return err
+	}
+	if err := s.replaceFile(logFileName, buf); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(s.dir, logFileName), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	_ = s.logFile.Close()
+	s.logFile = logFile
+
+	offsets := make([]int64, 0, len(s.offsets)-dropped)
+	for _, offset := range s.offsets[dropped:] {
+		offsets = append(offsets, offset-start)
+	}
+	s.offsets = offsets
+	s.terms = append([]uint64(nil), s.terms[dropped:]...)
+	s.firstIndex = snapshot.Index + 1
+	if dropped < len(s.loaded) {
+		s.loaded = s.loaded[dropped:]
+	} else {
+		s.loaded = nil
+	}
+	return nil
+}
+
+// replaceFile write file of name atomically by renaming a synced temporary file
+func (s *FileStorage) replaceFile(name string, data []byte) error {
+	file, err := os.CreateTemp(s.dir, name+"-"+tempFilePattern)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return s.commitFile(file, name)
+}
+
+// commitFile sync and close file, then rename it to name
+func (s *FileStorage) commitFile(file *os.File, name string) error {
+	err := file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filepath.Join(s.dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func appendEntry(buf []byte, entry Entry) []byte {
+	payload := make([]byte, 3*binary.MaxVarintLen64, 3*binary.MaxVarintLen64+len(entry.Data))
+	n := binary.PutUvarint(payload, entry.Index)
+	n += binary.PutUvarint(payload[n:], entry.Term)
+	dataSize := uint64(0)
+	if entry.Data != nil {
+		dataSize = uint64(len(entry.Data)) + 1
+	}
+	n += binary.PutUvarint(payload[n:], dataSize)
+	payload = append(payload[:n], entry.Data...)
+
+	header := make([]byte, maxEntryHeaderSize)
+	binary.LittleEndian.PutUint32(header, crc32.ChecksumIEEE(payload))
+	headerSize := 4 + binary.PutUvarint(header[4:], uint64(len(payload)))
+	buf = append(buf, header[:headerSize]...)
+	return append(buf, payload...)
+}
+
+// decodeEntry decode entry at the beginning of buf, ok is false if it's incomplete or corrupted
+func decodeEntry(buf []byte) (entry Entry, size int64, ok bool) {
+	if len(buf) < 4 {
+		return Entry{}, 0, false
+	}
+	payloadSize, n := binary.Uvarint(buf[4:])
+	if n <= 0 || payloadSize > uint64(len(buf)-4-n) {
+		return Entry{}, 0, false
+	}
+	payload := buf[4+n : 4+n+int(payloadSize)]
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(buf) {
+		return Entry{}, 0, false
+	}
+
+	var index int
+	readUvarint := func() (uint64, bool) {
+		value, n := binary.Uvarint(payload[index:])
+		index += max(n, 0)
+		return value, n > 0
+	}
+	var indexOk, termOk, sizeOk bool
+	entry.Index, indexOk = readUvarint()
+	entry.Term, termOk = readUvarint()
+	dataSize, sizeOk := readUvarint()
+	if !indexOk || !termOk || !sizeOk || (dataSize > 0 && dataSize-1 != uint64(len(payload)-index)) {
+		return Entry{}, 0, false
+	}
+	if dataSize > 0 {
+		entry.Data = append([]byte{}, payload[index:]...)
+	}
+	return entry, int64(4 + n + int(payloadSize)), true
+}
+
+type fileSnapshotWriter struct {
+	storage  *FileStorage
+	snapshot Snapshot
+	file     *os.File
+}
+
+func (w *fileSnapshotWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
+}
+
+func (w *fileSnapshotWriter) Commit() error {
+	s := w.storage
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.snapshot != nil && w.snapshot.Index <= s.snapshot.Index {
+		_ = w.Abort()
+		return ErrSnapshotOutOfDate
+	}
+	if err := s.commitFile(w.file, snapshotFileName); err != nil {
+		return err
+	}
+	snapshot := w.snapshot
+	s.snapshot = &snapshot
+	return s.compact(snapshot)
+}
+
+func (w *fileSnapshotWriter) Abort() error {
+	_ = w.file.Close()
+	return os.Remove(w.file.Name())
+}
+
+type fileSnapshotReader struct {
+	file *os.File
+	size int64
+}
+
+func (r *fileSnapshotReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.size-off {
+		p = p[:r.size-off]
+	}
+	n, err := r.file.ReadAt(p, off+snapshotHeaderSize)
+	if err == nil && off+int64(n) == r.size {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *fileSnapshotReader) Size() int64 {
+	return r.size
+}
+
+func (r *fileSnapshotReader) Close() error {
+	return r.file.Close()
+}
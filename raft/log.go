@@ -0,0 +1,87 @@
+package raft
+
+// raftLog entries after the last snapshot, entries[i] has index snapshotIndex+1+i
+type raftLog struct {
+	snapshot *Snapshot // nil before the first snapshot
+	entries  []Entry
+}
+
+func newRaftLog() *raftLog {
+	return &raftLog{}
+}
+
+func (l *raftLog) snapshotIndex() uint64 {
+	if l.snapshot == nil {
+		return 0
+	}
+	return l.snapshot.Index
+}
+
+func (l *raftLog) snapshotTerm() uint64 {
+	if l.snapshot == nil {
+		return 0
+	}
+	return l.snapshot.Term
+}
+
+func (l *raftLog) lastIndex() uint64 {
+	return l.snapshotIndex() + uint64(len(l.entries))
+}
+
+func (l *raftLog) lastTerm() uint64 {
+	return l.term(l.lastIndex())
+}
+
+// term of entry at index, 0 if it's not in log
+func (l *raftLog) term(index uint64) uint64 {
+	if index == l.snapshotIndex() {
+		return l.snapshotTerm()
+	}
+	if index < l.snapshotIndex() || index > l.lastIndex() {
+		return 0
+	}
+	return l.entries[index-l.snapshotIndex()-1].Term
+}
+
+// matchTerm check if log has entry at index with term
+func (l *raftLog) matchTerm(index, term uint64) bool {
+	if index < l.snapshotIndex() || index > l.lastIndex() {
+		return false
+	}
+	return l.term(index) == term
+}
+
+// isUpToDate check if log ending with lastTerm and lastIndex is at least as up to date as this log
+func (l *raftLog) isUpToDate(lastIndex, lastTerm uint64) bool {
+	return lastTerm > l.lastTerm() || (lastTerm == l.lastTerm() && lastIndex >= l.lastIndex())
+}
+
+// slice copy of entries in [lo, hi), lo must be after snapshot. Entries are copied since
+// messages are read by other nodes while log may be truncated
+func (l *raftLog) slice(lo, hi uint64) []Entry {
+	offset := l.snapshotIndex() + 1
+	return append([]Entry(nil), l.entries[lo-offset:hi-offset]...)
+}
+
+func (l *raftLog) entry(index uint64) Entry {
+	return l.entries[index-l.snapshotIndex()-1]
+}
+
+func (l *raftLog) append(entries ...Entry) {
+	l.entries = append(l.entries, entries...)
+}
+
+// truncateFrom remove entries from index on, they conflict with leader
+func (l *raftLog) truncateFrom(index uint64) {
+	l.entries = l.entries[:index-l.snapshotIndex()-1]
+}
+
+// compact drop the entries included in snapshot
+func (l *raftLog) compact(snapshot *Snapshot) {
+	if snapshot.Index <= l.lastIndex() && l.term(snapshot.Index) == snapshot.Term {
+		l.entries = append([]Entry(nil), l.entries[snapshot.Index-l.snapshotIndex():]...)
+	} else {
+		l.entries = nil
+	}
+	l.snapshot = snapshot
+}
@@ -0,0 +1,51 @@
+package raft
+
+// MessageType type of message exchanged between nodes
+type MessageType byte
+
+const (
+	// MsgVote candidate requests vote, Index and LogTerm are the last entry of candidate
+	MsgVote MessageType = iota + 1
+	// MsgVoteResp grant or reject vote
+	MsgVoteResp
+	// MsgApp leader appends Entries after the entry at Index with LogTerm, also used as heartbeat
+	MsgApp
+	// MsgAppResp Index is the last entry matched, or the rejected Index with RejectHint the last entry of follower
+	MsgAppResp
+	// MsgSnap leader sends chunk Data at Offset of Snapshot to follower lagging behind the compacted log,
+	// Done is set for the last chunk
+	MsgSnap
+	// MsgSnapResp follower requests the chunk at Offset of snapshot at Index
+	MsgSnapResp
+)
+
+// Entry of replicated log, Data is nil for the empty entry appended by new leader
+type Entry struct {
+	Index uint64
+	Term  uint64
+	Data  []byte
+}
+
+// Snapshot of state machine including all the entries up to Index, its data is kept by Storage
+type Snapshot struct {
+	Index uint64
+	Term  uint64
+}
+
+// Message between nodes, fields are used by message type
+type Message struct {
+	Type       MessageType
+	From       uint64
+	To         uint64
+	Term       uint64
+	Index      uint64
+	LogTerm    uint64
+	Entries    []Entry
+	Commit     uint64
+	Reject     bool
+	RejectHint uint64
+	Snapshot   *Snapshot
+	Offset     uint64
+	Data       []byte
+	Done       bool
+}
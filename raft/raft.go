@@ -0,0 +1,879 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotLeader         = errors.New("node is not leader")
+	ErrLeadershipLost    = errors.New("leadership lost before proposal is committed, it may be committed or not")
+	ErrProposalDropped   = errors.New("proposal is dropped by new leader")
+	ErrNodeStopped       = errors.New("node is stopped")
+	ErrInvalidNodeId     = errors.New("node id is zero or not in peers")
+	ErrNilStateMachine   = errors.New("state machine is nil")
+	ErrNilTransport      = errors.New("transport is nil")
+	ErrInvalidTickCount  = errors.New("election tick must be greater than heartbeat tick")
+	ErrNoSnapshot        = errors.New("no snapshot is saved")
+	ErrSnapshotOutOfDate = errors.New("snapshot is not newer than the saved one")
+	ErrLogNotContinuous  = errors.New("appended entries don't follow the saved log")
+	ErrCorruptedState    = errors.New("raft state file is corrupted")
+)
+
+const (
+	// none node id, used as no leader or no vote
+	none uint64 = 0
+	// buffered messages of node, more messages are dropped as lost on network
+	inboxSize = 4096
+)
+
+// StateType role of node
+type StateType byte
+
+const (
+	StateFollower StateType = iota
+	StateCandidate
+	StateLeader
+)
+
+// StateMachine apply the committed commands, every node applies the same commands in the same order
+type StateMachine interface {
+	// Apply committed command, error is returned to proposer and doesn't stop the node
+	Apply(data []byte) error
+
+	// Snapshot capture the state including all the commands applied, it's called between commands.
+	// Returned reader is read and closed in background while later commands are applied, it may
+	// include them only if applying them again on it gives the same state
+	Snapshot() (io.ReadCloser, error)
+
+	// Restore state from snapshot, replacing the current state
+	Restore(r io.Reader) error
+}
+
+// Transport deliver message to node To, message may be lost
+type Transport interface {
+	Send(msg Message)
+}
+
+type Config struct {
+	ID                   uint64        // id of node, can't be zero
+	Peers                []uint64      // ids of all the nodes in cluster including this node
+	StateMachine         StateMachine  // state replicated by log
+	Transport            Transport     // send messages to other nodes
+	Storage              Storage       // persist raft state, state is kept in memory if it's nil
+	TickInterval         time.Duration // interval of logical clock
+	ElectionTick         int           // follower starts election after no message from leader in [ElectionTick, 2*ElectionTick) ticks
+	HeartbeatTick        int           // leader sends heartbeat every HeartbeatTick ticks
+	SnapshotThreshold    uint64        // take snapshot and compact log once so many entries are applied, 0 means never
+	MaxEntriesPerMessage int           // max entries sent in one append message
+	SnapshotChunkSize    int           // max bytes of snapshot sent in one message
+}
+
+var DefaultConfig = Config{
+	TickInterval:         10 * time.Millisecond,
+	ElectionTick:         10,
+	HeartbeatTick:        1,
+	SnapshotThreshold:    10_000,
+	MaxEntriesPerMessage: 256,
+	SnapshotChunkSize:    1 << 20,
+}
+
+// Status of node
+type Status struct {
+	ID           uint64
+	State        StateType
+	Term         uint64
+	Leader       uint64
+	CommitIndex  uint64
+	AppliedIndex uint64
+	LastIndex    uint64
+}
+
+// proposal waiting for being applied
+type proposal struct {
+	term uint64
+	done chan error
+}
+
+// snapshotSend snapshot sent by leader in chunks, the next chunk is sent once follower received the last one
+type snapshotSend struct {
+	snapshot Snapshot
+	reader   SnapshotReader
+	offset   int64
+}
+
+// snapshotReceive snapshot received by follower from leader
+type snapshotReceive struct {
+	from     uint64
+	snapshot Snapshot
+	writer   SnapshotWriter
+	offset   int64
+}
+
+// Node of raft cluster. Hard state and entries are persisted by Storage before the messages depending on them
+// are sent, a restarted node restores the saved snapshot to state machine and applies the entries after it again
+type Node struct {
+	config  Config
+	storage Storage
+
+	mu                        *sync.Mutex
+	state                     StateType
+	term                      uint64
+	votedFor                  uint64
+	leader                    uint64
+	log                       *raftLog
+	commitIndex               uint64
+	appliedIndex              uint64
+	nextIndex                 map[uint64]uint64 // next entry to send to each follower, only used by leader
+	matchIndex                map[uint64]uint64 // last entry replicated on each follower, only used by leader
+	votes                     map[uint64]bool
+	recentActive              map[uint64]bool // followers responded in the last election timeout, only used by leader
+	electionElapsed           int
+	heartbeatElapsed          int
+	randomizedElectionTimeout int
+	proposals                 map[uint64]*proposal // proposals of leader by index
+	rand                      *rand.Rand
+
+	hardState       HardState                // hard state saved
+	unstable        uint64                   // index of the first entry not saved
+	msgs            []Message                // messages sent once state is saved
+	snapshotting    bool                     // snapshot is being saved in background
+	snapshotSends   map[uint64]*snapshotSend // snapshots sent to followers, only used by leader
+	snapshotReceive *snapshotReceive         // snapshot being received from leader
+	err             error                    // error of saving state, node is stopped by it
+
+	inbox  chan Message
+	stopCh chan struct{}
+	doneCh chan struct{}
+	wg     *sync.WaitGroup
+}
+
+// NewNode start node as follower, it's driven by ticker and messages delivered through Step
+func NewNode(config Config) (*Node, error) {
+	if err := checkConfig(config); err != nil {
+		return nil, err
+	}
+
+	storage := config.Storage
+	if storage == nil {
+		storage = NewMemoryStorage()
+	}
+	n := &Node{
+		config:    config,
+		storage:   storage,
+		mu:        new(sync.Mutex),
+		log:       newRaftLog(),
+		proposals: make(map[uint64]*proposal),
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano() + int64(config.ID))),
+		inbox:     make(chan Message, inboxSize),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+		wg:        new(sync.WaitGroup),
+	}
+	if err := n.restore(); err != nil {
+		return nil, err
+	}
+	n.becomeFollower(n.term, none)
+
+	go n.run()
+	return n, nil
+}
+
+// restore load the state saved by storage, entries after snapshot are applied again once they're committed
+func (n *Node) restore() error {
+	hardState, snapshot, entries, err := n.storage.InitialState()
+	if err != nil {
+		return err
+	}
+	if snapshot != nil {
+		_, reader, err := n.storage.OpenSnapshot()
+		if err != nil {
+			return err
+		}
+		err = n.config.StateMachine.Restore(io.NewSectionReader(reader, 0, reader.Size()))
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+		n.log.compact(snapshot)
+		n.commitIndex = snapshot.Index
+		n.appliedIndex = snapshot.Index
+	}
+	n.log.append(entries...)
+	n.term = hardState.Term
+	n.votedFor = hardState.Vote
+	n.hardState = hardState
+	n.unstable = n.log.lastIndex() + 1
+	return nil
+}
+
+// Step deliver message to node, message is dropped if node is overloaded or stopped
+func (n *Node) Step(msg Message) {
+	select {
+	case n.inbox <- msg:
+	default:
+	}
+}
+
+// Propose append data to log, and wait until it's applied on this node.
+// Only leader accepts proposals, error of applying data is returned
+func (n *Node) Propose(ctx context.Context, data []byte) error {
+	n.mu.Lock()
+	if n.err != nil {
+		n.mu.Unlock()
+		return n.err
+	}
+	select {
+	case <-n.stopCh:
+		n.mu.Unlock()
+		return ErrNodeStopped
+	default:
+	}
+	if n.state != StateLeader {
+		n.mu.Unlock()
+		return ErrNotLeader
+	}
+
+	entry := Entry{Index: n.log.lastIndex() + 1, Term: n.term, Data: data}
+	p := &proposal{term: n.term, done: make(chan error, 1)}
+	n.proposals[entry.Index] = p
+	n.log.append(entry)
+	n.broadcastAppend()
+	n.advance()
+	n.mu.Unlock()
+
+	select {
+	case err := <-p.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-n.stopCh:
+		return ErrNodeStopped
+	}
+}
+
+// Status current state of node
+func (n *Node) Status() Status {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return Status{
+		ID:           n.config.ID,
+		State:        n.state,
+		Term:         n.term,
+		Leader:       n.leader,
+		CommitIndex:  n.commitIndex,
+		AppliedIndex: n.appliedIndex,
+		LastIndex:    n.log.lastIndex(),
+	}
+}
+
+// Stop node, pending proposals fail with ErrNodeStopped. Storage can be closed once it returns
+func (n *Node) Stop() {
+	n.mu.Lock()
+	select {
+	case <-n.stopCh:
+	default:
+		close(n.stopCh)
+	}
+	n.mu.Unlock()
+	<-n.doneCh
+	n.wg.Wait()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closeSnapshotSends()
+	n.abortSnapshotReceive()
+}
+
+func (n *Node) run() {
+	defer close(n.doneCh)
+
+	ticker := time.NewTicker(n.config.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stopCh:
+			return
+		case <-ticker.C:
+			n.mu.Lock()
+			n.tick()
+			n.advance()
+			n.mu.Unlock()
+		case msg := <-n.inbox:
+			n.mu.Lock()
+			n.step(msg)
+			n.advance()
+			n.mu.Unlock()
+		}
+	}
+}
+
+// advance save the hard state and entries changed, then send the messages depending on them.
+// Node is stopped if state can't be saved, since it may have lost the state others know
+func (n *Node) advance() {
+	if n.err != nil {
+		return
+	}
+
+	if state := (HardState{Term: n.term, Vote: n.votedFor}); state != n.hardState {
+		if err := n.storage.SaveHardState(state); err != nil {
+			n.fail(err)
+			return
+		}
+		n.hardState = state
+	}
+	if n.unstable <= n.log.lastIndex() {
+		if err := n.storage.Append(n.log.slice(n.unstable, n.log.lastIndex()+1)); err != nil {
+			n.fail(err)
+			return
+		}
+		n.unstable = n.log.lastIndex() + 1
+		// entries of leader count for commit once they're saved
+		if n.state == StateLeader {
+			n.matchIndex[n.config.ID] = n.log.lastIndex()
+			if n.maybeCommit() {
+				n.broadcastAppend()
+			}
+		}
+	}
+
+	for _, msg := range n.msgs {
+		n.config.Transport.Send(msg)
+	}
+	n.msgs = nil
+}
+
+func (n *Node) fail(err error) {
+	n.err = err
+	n.msgs = nil
+	n.failProposals(err)
+	select {
+	case <-n.stopCh:
+	default:
+		close(n.stopCh)
+	}
+}
+
+func (n *Node) tick() {
+	if n.state == StateLeader {
+		// leader steps down if it can't reach quorum, so proposals to a partitioned leader fail fast
+		n.electionElapsed++
+		if n.electionElapsed >= n.config.ElectionTick {
+			n.electionElapsed = 0
+			n.recentActive[n.config.ID] = true
+			if !n.quorumReached(n.recentActive) {
+				n.becomeFollower(n.term, none)
+				return
+			}
+			n.recentActive = make(map[uint64]bool, len(n.config.Peers))
+		}
+
+		n.heartbeatElapsed++
+		if n.heartbeatElapsed >= n.config.HeartbeatTick {
+			n.heartbeatElapsed = 0
+			n.broadcastAppend()
+		}
+		return
+	}
+
+	n.electionElapsed++
+	if n.electionElapsed >= n.randomizedElectionTimeout {
+		n.campaign()
+	}
+}
+
+func (n *Node) step(msg Message) {
+	switch {
+	case msg.Term > n.term:
+		// message from leader of newer term makes node its follower, otherwise leader is unknown
+		if msg.Type == MsgApp || msg.Type == MsgSnap {
+			n.becomeFollower(msg.Term, msg.From)
+		} else {
+			n.becomeFollower(msg.Term, none)
+		}
+	case msg.Term < n.term:
+		// let stale leader know the new term
+		if msg.Type == MsgApp || msg.Type == MsgSnap {
+			n.send(Message{Type: MsgAppResp, To: msg.From, Reject: true})
+		}
+		return
+	}
+
+	switch msg.Type {
+	case MsgVote:
+		n.handleVote(msg)
+	case MsgVoteResp:
+		n.handleVoteResp(msg)
+	case MsgApp:
+		n.handleAppend(msg)
+	case MsgAppResp:
+		n.handleAppendResp(msg)
+	case MsgSnap:
+		n.handleSnapshot(msg)
+	case MsgSnapResp:
+		n.handleSnapshotResp(msg)
+	}
+}
+
+func (n *Node) campaign() {
+	n.state = StateCandidate
+	n.term++
+	n.votedFor = n.config.ID
+	n.leader = none
+	n.votes = map[uint64]bool{n.config.ID: true}
+	n.resetElectionTimeout()
+	n.failProposals(ErrLeadershipLost)
+
+	if n.quorumReached(n.votes) {
+		n.becomeLeader()
+		return
+	}
+	for _, peer := range n.config.Peers {
+		if peer != n.config.ID {
+			n.send(Message{Type: MsgVote, To: peer, Index: n.log.lastIndex(), LogTerm: n.log.lastTerm()})
+		}
+	}
+}
+
+func (n *Node) handleVote(msg Message) {
+	canVote := n.votedFor == none || n.votedFor == msg.From
+	if canVote && n.log.isUpToDate(msg.Index, msg.LogTerm) {
+		n.votedFor = msg.From
+		n.electionElapsed = 0
+		n.send(Message{Type: MsgVoteResp, To: msg.From})
+		return
+	}
+	n.send(Message{Type: MsgVoteResp, To: msg.From, Reject: true})
+}
+
+func (n *Node) handleVoteResp(msg Message) {
+	if n.state != StateCandidate {
+		return
+	}
+	if !msg.Reject {
+		n.votes[msg.From] = true
+	}
+	if n.quorumReached(n.votes) {
+		n.becomeLeader()
+	}
+}
+
+func (n *Node) handleAppend(msg Message) {
+	n.leader = msg.From
+	n.electionElapsed = 0
+	if n.state != StateFollower {
+		n.becomeFollower(msg.Term, msg.From)
+	}
+
+	// entries already in snapshot are committed, they must match
+	entries := msg.Entries
+	prevIndex, prevTerm := msg.Index, msg.LogTerm
+	if prevIndex < n.log.snapshotIndex() {
+		skip := min(n.log.snapshotIndex()-prevIndex, uint64(len(entries)))
+		entries = entries[skip:]
+		prevIndex, prevTerm = n.log.snapshotIndex(), n.log.snapshotTerm()
+	}
+
+	if !n.log.matchTerm(prevIndex, prevTerm) {
+		n.send(Message{Type: MsgAppResp, To: msg.From, Index: msg.Index, Reject: true, RejectHint: n.log.lastIndex()})
+		return
+	}
+
+	for i, entry := range entries {
+		if entry.Index <= n.log.lastIndex() {
+			if n.log.term(entry.Index) == entry.Term {
+				continue
+			}
+			// conflicting entries are never committed
+			n.log.truncateFrom(entry.Index)
+			n.unstable = min(n.unstable, entry.Index)
+		}
+		n.log.append(entries[i:]...)
+		break
+	}
+
+	lastNewIndex := prevIndex + uint64(len(entries))
+	if commitIndex := min(msg.Commit, lastNewIndex); commitIndex > n.commitIndex {
+		n.commitIndex = commitIndex
+		n.applyCommitted()
+	}
+	n.send(Message{Type: MsgAppResp, To: msg.From, Index: lastNewIndex})
+}
+
+func (n *Node) handleAppendResp(msg Message) {
+	if n.state != StateLeader {
+		return
+	}
+	n.recentActive[msg.From] = true
+
+	if msg.Reject {
+		// go back to the last entry of follower, or one entry before the rejected one
+		next := min(msg.Index, msg.RejectHint+1)
+		n.nextIndex[msg.From] = max(next, 1)
+		n.sendAppend(msg.From)
+		return
+	}
+
+	if msg.Index > n.matchIndex[msg.From] {
+		n.matchIndex[msg.From] = msg.Index
+	}
+	if send, ok := n.snapshotSends[msg.From]; ok && msg.Index >= send.snapshot.Index {
+		n.closeSnapshotSend(msg.From)
+	}
+	n.nextIndex[msg.From] = max(n.nextIndex[msg.From], msg.Index+1)
+	if n.maybeCommit() {
+		n.broadcastAppend()
+	} else if n.nextIndex[msg.From] <= n.log.lastIndex() {
+		n.sendAppend(msg.From)
+	}
+}
+
+// handleSnapshot write the chunk of snapshot, and restore state machine from it once all the chunks are received
+func (n *Node) handleSnapshot(msg Message) {
+	n.leader = msg.From
+	n.electionElapsed = 0
+	if n.state != StateFollower {
+		n.becomeFollower(msg.Term, msg.From)
+	}
+
+	snapshot := *msg.Snapshot
+	if snapshot.Index <= n.commitIndex {
+		n.send(Message{Type: MsgAppResp, To: msg.From, Index: n.commitIndex})
+		return
+	}
+
+	receive := n.snapshotReceive
+	if receive == nil || receive.from != msg.From || receive.snapshot != snapshot {
+		// a different snapshot is received from the beginning
+		if msg.Offset != 0 {
+			n.send(Message{Type: MsgSnapResp, To: msg.From, Index: snapshot.Index})
+			return
+		}
+		n.abortSnapshotReceive()
+		writer, err := n.storage.CreateSnapshot(snapshot)
+		if err != nil {
+			return
+		}
+		receive = &snapshotReceive{from: msg.From, snapshot: snapshot, writer: writer}
+		n.snapshotReceive = receive
+	}
+	if msg.Offset != uint64(receive.offset) {
+		n.send(Message{Type: MsgSnapResp, To: msg.From, Index: snapshot.Index, Offset: uint64(receive.offset)})
+		return
+	}
+
+	if _, err := receive.writer.Write(msg.Data); err != nil {
+		n.abortSnapshotReceive()
+		return
+	}
+	receive.offset += int64(len(msg.Data))
+	if !msg.Done {
+		n.send(Message{Type: MsgSnapResp, To: msg.From, Index: snapshot.Index, Offset: uint64(receive.offset)})
+		return
+	}
+
+	n.snapshotReceive = nil
+	if err := receive.writer.Commit(); err != nil {
+		n.send(Message{Type: MsgAppResp, To: msg.From, Index: msg.Index, Reject: true, RejectHint: n.log.lastIndex()})
+		return
+	}
+	// snapshot saved has to be restored, otherwise state machine doesn't match log
+	_, reader, err := n.storage.OpenSnapshot()
+	if err != nil {
+		n.fail(err)
+		return
+	}
+	err = n.config.StateMachine.Restore(io.NewSectionReader(reader, 0, reader.Size()))
+	_ = reader.Close()
+	if err != nil {
+		n.fail(err)
+		return
+	}
+	n.log.compact(&snapshot)
+	n.unstable = max(n.unstable, snapshot.Index+1)
+	n.commitIndex = snapshot.Index
+	n.appliedIndex = snapshot.Index
+	n.send(Message{Type: MsgAppResp, To: msg.From, Index: n.log.lastIndex()})
+}
+
+// handleSnapshotResp send the chunk follower requests
+func (n *Node) handleSnapshotResp(msg Message) {
+	if n.state != StateLeader {
+		return
+	}
+	n.recentActive[msg.From] = true
+
+	// chunk is sent again by heartbeat, response to the duplicated one is ignored
+	send, ok := n.snapshotSends[msg.From]
+	if !ok || send.snapshot.Index != msg.Index || send.offset == int64(msg.Offset) {
+		return
+	}
+	send.offset = int64(msg.Offset)
+	n.sendSnapshot(msg.From)
+}
+
+func (n *Node) abortSnapshotReceive() {
+	if n.snapshotReceive != nil {
+		_ = n.snapshotReceive.writer.Abort()
+		n.snapshotReceive = nil
+	}
+}
+
+func (n *Node) becomeFollower(term uint64, leader uint64) {
+	if n.state == StateLeader {
+		n.failProposals(ErrLeadershipLost)
+		n.closeSnapshotSends()
+	}
+	n.state = StateFollower
+	if term != n.term {
+		n.term = term
+		n.votedFor = none
+	}
+	n.leader = leader
+	n.resetElectionTimeout()
+}
+
+func (n *Node) becomeLeader() {
+	n.state = StateLeader
+	n.leader = n.config.ID
+	n.heartbeatElapsed = 0
+	n.electionElapsed = 0
+	n.recentActive = make(map[uint64]bool, len(n.config.Peers))
+	n.nextIndex = make(map[uint64]uint64, len(n.config.Peers))
+	n.matchIndex = make(map[uint64]uint64, len(n.config.Peers))
+	n.snapshotSends = make(map[uint64]*snapshotSend)
+	for _, peer := range n.config.Peers {
+		n.nextIndex[peer] = n.log.lastIndex() + 1
+	}
+
+	// entries of previous terms are committed along with the empty entry of current term once it's saved
+	n.log.append(Entry{Index: n.log.lastIndex() + 1, Term: n.term})
+	n.broadcastAppend()
+}
+
+func (n *Node) broadcastAppend() {
+	for _, peer := range n.config.Peers {
+		if peer != n.config.ID {
+			n.sendAppend(peer)
+		}
+	}
+}
+
+// sendAppend send entries from next index of follower, or snapshot if they are compacted
+func (n *Node) sendAppend(to uint64) {
+	next := n.nextIndex[to]
+	if next <= n.log.snapshotIndex() {
+		n.sendSnapshot(to)
+		return
+	}
+
+	last := min(n.log.lastIndex(), next+uint64(n.config.MaxEntriesPerMessage)-1)
+	var entries []Entry
+	if next <= last {
+		entries = n.log.slice(next, last+1)
+	}
+	n.send(Message{
+		Type:    MsgApp,
+		To:      to,
+		Index:   next - 1,
+		LogTerm: n.log.term(next - 1),
+		Entries: entries,
+		Commit:  n.commitIndex,
+	})
+}
+
+// sendSnapshot send the chunk of saved snapshot at the offset follower received
+func (n *Node) sendSnapshot(to uint64) {
+	send, ok := n.snapshotSends[to]
+	if !ok {
+		snapshot, reader, err := n.storage.OpenSnapshot()
+		if err != nil {
+			return
+		}
+		send = &snapshotSend{snapshot: snapshot, reader: reader}
+		n.snapshotSends[to] = send
+	}
+
+	offset := min(send.offset, send.reader.Size())
+	chunk := make([]byte, min(int64(n.config.SnapshotChunkSize), send.reader.Size()-offset))
+	if read, _ := send.reader.ReadAt(chunk, offset); read < len(chunk) {
+		n.closeSnapshotSend(to)
+		return
+	}
+	snapshot := send.snapshot
+	n.send(Message{
+		Type:     MsgSnap,
+		To:       to,
+		Index:    snapshot.Index,
+		Snapshot: &snapshot,
+		Offset:   uint64(offset),
+		Data:     chunk,
+		Done:     offset+int64(len(chunk)) == send.reader.Size(),
+	})
+}
+
+func (n *Node) closeSnapshotSend(to uint64) {
+	if send, ok := n.snapshotSends[to]; ok {
+		_ = send.reader.Close()
+		delete(n.snapshotSends, to)
+	}
+}
+
+// closeSnapshotSends stop sending snapshots, they are opened again with the latest snapshot if needed
+func (n *Node) closeSnapshotSends() {
+	for to := range n.snapshotSends {
+		n.closeSnapshotSend(to)
+	}
+}
+
+// maybeCommit commit the entries of current term replicated on quorum, return true if commit index moves
+func (n *Node) maybeCommit() bool {
+	matched := make([]uint64, 0, len(n.config.Peers))
+	for _, peer := range n.config.Peers {
+		matched = append(matched, n.matchIndex[peer])
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i] > matched[j]
+	})
+
+	index := matched[len(matched)/2]
+	if index <= n.commitIndex || n.log.term(index) != n.term {
+		return false
+	}
+	n.commitIndex = index
+	n.applyCommitted()
+	return true
+}
+
+// applyCommitted apply committed entries to state machine, and take snapshot if there are enough entries
+func (n *Node) applyCommitted() {
+	for n.appliedIndex < n.commitIndex {
+		n.appliedIndex++
+		entry := n.log.entry(n.appliedIndex)
+
+		var err error
+		if entry.Data != nil {
+			err = n.config.StateMachine.Apply(entry.Data)
+		}
+		if p, ok := n.proposals[entry.Index]; ok {
+			delete(n.proposals, entry.Index)
+			if p.term != entry.Term {
+				err = ErrProposalDropped
+			}
+			p.done <- err
+		}
+	}
+
+	if n.config.SnapshotThreshold > 0 && !n.snapshotting &&
+		n.appliedIndex-n.log.snapshotIndex() >= n.config.SnapshotThreshold {
+		n.takeSnapshot()
+	}
+}
+
+// takeSnapshot capture state machine, and save it in background. Log is compacted once it's saved
+func (n *Node) takeSnapshot() {
+	snapshot := Snapshot{Index: n.appliedIndex, Term: n.log.term(n.appliedIndex)}
+	reader, err := n.config.StateMachine.Snapshot()
+	if err != nil {
+		return
+	}
+	writer, err := n.storage.CreateSnapshot(snapshot)
+	if err != nil {
+		_ = reader.Close()
+		return
+	}
+
+	n.snapshotting = true
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		_, err := io.Copy(writer, reader)
+		_ = reader.Close()
+		if err == nil {
+			err = writer.Commit()
+		} else {
+			_ = writer.Abort()
+		}
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.snapshotting = false
+		// snapshot received from leader may be newer
+		if err == nil && snapshot.Index > n.log.snapshotIndex() {
+			n.log.compact(&snapshot)
+			n.unstable = max(n.unstable, snapshot.Index+1)
+			n.closeSnapshotSends()
+		}
+	}()
+}
+
+func (n *Node) failProposals(err error) {
+	for index, p := range n.proposals {
+		p.done <- err
+		delete(n.proposals, index)
+	}
+}
+
+func (n *Node) quorumReached(votes map[uint64]bool) bool {
+	var granted int
+	for _, peer := range n.config.Peers {
+		if votes[peer] {
+			granted++
+		}
+	}
+	return granted > len(n.config.Peers)/2
+}
+
+func (n *Node) resetElectionTimeout() {
+	n.electionElapsed = 0
+	n.randomizedElectionTimeout = n.config.ElectionTick + n.rand.Intn(n.config.ElectionTick)
+}
+
+// send message once the state it depends on is saved
+func (n *Node) send(msg Message) {
+	msg.From = n.config.ID
+	msg.Term = n.term
+	n.msgs = append(n.msgs, msg)
+}
+
+func checkConfig(config Config) error {
+	if config.ID == none {
+		return ErrInvalidNodeId
+	}
+	var found bool
+	for _, peer := range config.Peers {
+		if peer == none {
+			return ErrInvalidNodeId
+		}
+		if peer == config.ID {
+			found = true
+		}
+	}
+	if !found {
+		return ErrInvalidNodeId
+	}
+
+	if config.StateMachine == nil {
+		return ErrNilStateMachine
+	}
+	if config.Transport == nil {
+		return ErrNilTransport
+	}
+	if config.TickInterval <= 0 || config.HeartbeatTick <= 0 || config.ElectionTick <= config.HeartbeatTick {
+		return ErrInvalidTickCount
+	}
+	if config.MaxEntriesPerMessage <= 0 {
+		return errors.New("max entries per message less than or equal to zero")
+	}
+	if config.SnapshotChunkSize <= 0 {
+		return errors.New("snapshot chunk size less than or equal to zero")
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// logStateMachine keep applied data in order
+type logStateMachine struct {
+	mu      *sync.Mutex
+	applied []string
+}
+
+func newLogStateMachine() *logStateMachine {
+	return &logStateMachine{mu: new(sync.Mutex)}
+}
+
+func (sm *logStateMachine) Apply(data []byte) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.applied = append(sm.applied, string(data))
+	return nil
+}
+
+func (sm *logStateMachine) Snapshot() (io.ReadCloser, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	data, err := json.Marshal(sm.applied)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (sm *logStateMachine) Restore(r io.Reader) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return json.NewDecoder(r).Decode(&sm.applied)
+}
+
+func (sm *logStateMachine) getApplied() []string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return append([]string(nil), sm.applied...)
+}
+
+type testCluster struct {
+	transport *InMemoryTransport
+	peers     []uint64
+	nodes     map[uint64]*Node
+	sms       map[uint64]*logStateMachine
+	storages  map[uint64]*MemoryStorage
+	threshold uint64
+}
+
+func newTestCluster(t *testing.T, nodeNum int, snapshotThreshold uint64) *testCluster {
+	c := &testCluster{
+		transport: NewInMemoryTransport(),
+		nodes:     make(map[uint64]*Node),
+		sms:       make(map[uint64]*logStateMachine),
+		storages:  make(map[uint64]*MemoryStorage),
+		threshold: snapshotThreshold,
+	}
+	for i := 1; i <= nodeNum; i++ {
+		c.peers = append(c.peers, uint64(i))
+	}
+	for _, id := range c.peers {
+		c.start(t, id)
+	}
+	t.Cleanup(func() {
+		for _, node := range c.nodes {
+			node.Stop()
+		}
+	})
+	return c
+}
+
+// start node with empty state machine and the state saved in storage of node
+func (c *testCluster) start(t *testing.T, id uint64) {
+	if _, ok := c.storages[id]; !ok {
+		c.storages[id] = NewMemoryStorage()
+	}
+	sm := newLogStateMachine()
+	config := DefaultConfig
+	config.ID = id
+	config.Peers = c.peers
+	config.StateMachine = sm
+	config.Transport = c.transport
+	config.Storage = c.storages[id]
+	config.SnapshotThreshold = c.threshold
+	config.MaxEntriesPerMessage = 8
+	config.SnapshotChunkSize = 16
+	node, err := NewNode(config)
+	assert.Nil(t, err)
+
+	c.nodes[id] = node
+	c.sms[id] = sm
+	c.transport.Register(id, node)
+}
+
+func (c *testCluster) stop(id uint64) {
+	c.transport.Unregister(id)
+	c.nodes[id].Stop()
+}
+
+// waitLeader wait until the given nodes agree on a leader among them
+func (c *testCluster) waitLeader(t *testing.T, ids ...uint64) uint64 {
+	var leader uint64
+	assert.Eventually(t, func() bool {
+		leader = none
+		for _, id := range ids {
+			status := c.nodes[id].Status()
+			if status.Leader == none || (leader != none && status.Leader != leader) {
+				return false
+			}
+			leader = status.Leader
+		}
+		for _, id := range ids {
+			if id == leader {
+				return c.nodes[leader].Status().State == StateLeader
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	return leader
+}
+
+func (c *testCluster) propose(t *testing.T, leader uint64, from, to int) {
+	for i := from; i < to; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := c.nodes[leader].Propose(ctx, []byte(fmt.Sprintf("data-%d", i)))
+		cancel()
+		assert.Nil(t, err)
+	}
+}
+
+func (c *testCluster) waitApplied(t *testing.T, want []string, ids ...uint64) {
+	for _, id := range ids {
+		assert.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual(want, c.sms[id].getApplied())
+		}, 5*time.Second, 10*time.Millisecond, "node %d", id)
+	}
+}
+
+func expectedApplied(n int) []string {
+	want := make([]string, n)
+	for i := range want {
+		want[i] = fmt.Sprintf("data-%d", i)
+	}
+	return want
+}
+
+func TestNode_ElectAndReplicate(t *testing.T) {
+	c := newTestCluster(t, 3, 0)
+	leader := c.waitLeader(t, c.peers...)
+
+	c.propose(t, leader, 0, 50)
+	c.waitApplied(t, expectedApplied(50), c.peers...)
+
+	for _, id := range c.peers {
+		if id != leader {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			assert.Equal(t, ErrNotLeader, c.nodes[id].Propose(ctx, []byte("x")))
+			cancel()
+		}
+	}
+}
+
+func TestNode_Failover(t *testing.T) {
+	c := newTestCluster(t, 3, 0)
+	leader := c.waitLeader(t, c.peers...)
+	c.propose(t, leader, 0, 20)
+
+	var rest []uint64
+	for _, id := range c.peers {
+		if id != leader {
+			rest = append(rest, id)
+		}
+	}
+	c.transport.Disconnect(leader)
+
+	newLeader := c.waitLeader(t, rest...)
+	assert.NotEqual(t, leader, newLeader)
+	c.propose(t, newLeader, 20, 40)
+	c.waitApplied(t, expectedApplied(40), rest...)
+
+	// old leader steps down and can't commit without quorum
+	assert.Eventually(t, func() bool {
+		return c.nodes[leader].Status().State != StateLeader
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// old leader catches up after reconnected
+	c.transport.Connect(leader)
+	c.waitApplied(t, expectedApplied(40), c.peers...)
+}
+
+func TestNode_SnapshotCatchUp(t *testing.T) {
+	c := newTestCluster(t, 3, 10)
+	leader := c.waitLeader(t, c.peers...)
+	c.propose(t, leader, 0, 10)
+
+	var lagging uint64
+	for _, id := range c.peers {
+		if id != leader {
+			lagging = id
+			break
+		}
+	}
+	c.stop(lagging)
+
+	c.propose(t, leader, 10, 60)
+	assert.Eventually(t, func() bool {
+		c.nodes[leader].mu.Lock()
+		defer c.nodes[leader].mu.Unlock()
+		return c.nodes[leader].log.snapshotIndex() > 10
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// state of lagging node is lost, entries it needs are compacted on leader and snapshot is sent in chunks
+	c.storages[lagging] = NewMemoryStorage()
+	c.start(t, lagging)
+	c.waitApplied(t, expectedApplied(60), c.peers...)
+}
+
+func TestNode_Restart(t *testing.T) {
+	c := newTestCluster(t, 3, 10)
+	leader := c.waitLeader(t, c.peers...)
+	c.propose(t, leader, 0, 25)
+	c.waitApplied(t, expectedApplied(25), c.peers...)
+
+	// all nodes restart with saved snapshot and entries, votes and terms are kept
+	terms := make(map[uint64]uint64)
+	for _, id := range c.peers {
+		terms[id] = c.nodes[id].Status().Term
+		c.stop(id)
+	}
+	for _, id := range c.peers {
+		c.start(t, id)
+		assert.Equal(t, terms[id], c.nodes[id].Status().Term)
+	}
+
+	leader = c.waitLeader(t, c.peers...)
+	c.propose(t, leader, 25, 40)
+	c.waitApplied(t, expectedApplied(40), c.peers...)
+}
+
+func TestNewNode_InvalidConfig(t *testing.T) {
+	config := DefaultConfig
+	config.StateMachine = newLogStateMachine()
+	config.Transport = NewInMemoryTransport()
+
+	config.ID = 4
+	config.Peers = []uint64{1, 2, 3}
+	_, err := NewNode(config)
+	assert.Equal(t, ErrInvalidNodeId, err)
+
+	config.ID = 1
+	config.HeartbeatTick = config.ElectionTick
+	_, err = NewNode(config)
+	assert.Equal(t, ErrInvalidTickCount, err)
+}
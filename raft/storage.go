@@ -0,0 +1,157 @@
+package raft
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// HardState state of node which must be persisted before voting or replying to leader
+type HardState struct {
+	Term uint64
+	Vote uint64
+}
+
+// Storage persist raft state. Node saves hard state and entries before sending the messages depending on them,
+// so votes and acknowledged entries survive restart
+type Storage interface {
+	// InitialState state saved before, snapshot is nil if there is none and entries follow the snapshot
+	InitialState() (HardState, *Snapshot, []Entry, error)
+
+	// SaveHardState save term and vote durably
+	SaveHardState(state HardState) error
+
+	// Append save entries durably, the saved entries from the index of the first one on are replaced
+	Append(entries []Entry) error
+
+	// CreateSnapshot return writer of snapshot data. Once committed, the snapshot replaces the saved one and
+	// the entries included in it are dropped
+	CreateSnapshot(snapshot Snapshot) (SnapshotWriter, error)
+
+	// OpenSnapshot open data of the saved snapshot
+	OpenSnapshot() (Snapshot, SnapshotReader, error)
+}
+
+// SnapshotWriter write data of snapshot, it's saved by Commit or dropped by Abort
+type SnapshotWriter interface {
+	io.Writer
+	Commit() error
+	Abort() error
+}
+
+// SnapshotReader read data of snapshot at any offset, so it can be sent in chunks
+type SnapshotReader interface {
+	io.ReaderAt
+	io.Closer
+	Size() int64
+}
+
+// MemoryStorage keep raft state in memory, it's lost once the process exits
+type MemoryStorage struct {
+	mu           *sync.Mutex
+	hardState    HardState
+	snapshot     *Snapshot
+	snapshotData []byte
+	entries      []Entry
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{mu: new(sync.Mutex)}
+}
+
+func (s *MemoryStorage) InitialState() (HardState, *Snapshot, []Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hardState, s.snapshot, append([]Entry(nil), s.entries...), nil
+}
+
+func (s *MemoryStorage) SaveHardState(state HardState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hardState = state
+	return nil
+}
+
+func (s *MemoryStorage) Append(entries []Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// entries included in snapshot are committed and never replaced
+	firstIndex := s.snapshotIndex() + 1
+	for len(entries) > 0 && entries[0].Index < firstIndex {
+		entries = entries[1:]
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if entries[0].Index > firstIndex+uint64(len(s.entries)) {
+		return ErrLogNotContinuous
+	}
+	s.entries = append(s.entries[:entries[0].Index-firstIndex], entries...)
+	return nil
+}
+
+func (s *MemoryStorage) CreateSnapshot(snapshot Snapshot) (SnapshotWriter, error) {
+	return &memorySnapshotWriter{storage: s, snapshot: snapshot, buf: new(bytes.Buffer)}, nil
+}
+
+func (s *MemoryStorage) OpenSnapshot() (Snapshot, SnapshotReader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snapshot == nil {
+		return Snapshot{}, nil, ErrNoSnapshot
+	}
+	return *s.snapshot, memorySnapshotReader{Reader: bytes.NewReader(s.snapshotData)}, nil
+}
+
+func (s *MemoryStorage) snapshotIndex() uint64 {
+	if s.snapshot == nil {
+		return 0
+	}
+	return s.snapshot.Index
+}
+
+type memorySnapshotWriter struct {
+	storage  *MemoryStorage
+	snapshot Snapshot
+	buf      *bytes.Buffer
+}
+
+func (w *memorySnapshotWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *memorySnapshotWriter) Commit() error {
+	s := w.storage
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w.snapshot.Index <= s.snapshotIndex() {
+		return ErrSnapshotOutOfDate
+	}
+	// keep the entries after snapshot if log has the last entry of snapshot, like raftLog.compact
+	firstIndex := s.snapshotIndex() + 1
+	lastIndex := s.snapshotIndex() + uint64(len(s.entries))
+	if w.snapshot.Index <= lastIndex && s.entries[w.snapshot.Index-firstIndex].Term == w.snapshot.Term {
+		s.entries = append([]Entry(nil), s.entries[w.snapshot.Index-firstIndex+1:]...)
+	} else {
+		s.entries = nil
+	}
+	snapshot := w.snapshot
+	s.snapshot = &snapshot
+	s.snapshotData = w.buf.Bytes()
+	return nil
+}
+
+func (w *memorySnapshotWriter) Abort() error {
+	w.buf = nil
+	return nil
+}
+
+type memorySnapshotReader struct {
+	*bytes.Reader
+}
+
+func (memorySnapshotReader) Close() error {
+	return nil
+}
@@ -0,0 +1,115 @@
+package raft
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readSnapshot(t *testing.T, storage Storage) (Snapshot, []byte) {
+	snapshot, reader, err := storage.OpenSnapshot()
+	assert.Nil(t, err)
+	defer func() {
+		_ = reader.Close()
+	}()
+	data, err := io.ReadAll(io.NewSectionReader(reader, 0, reader.Size()))
+	assert.Nil(t, err)
+	return snapshot, data
+}
+
+func testStorage(t *testing.T, storage Storage, reopen func() Storage) {
+	_, _, err := storage.OpenSnapshot()
+	assert.Equal(t, ErrNoSnapshot, err)
+
+	assert.Nil(t, storage.SaveHardState(HardState{Term: 2, Vote: 1}))
+	entries := []Entry{{Index: 1, Term: 1}, {Index: 2, Term: 1, Data: []byte("a")}, {Index: 3, Term: 1, Data: []byte{}}}
+	assert.Nil(t, storage.Append(entries))
+	// conflicting entries are replaced
+	assert.Nil(t, storage.Append([]Entry{{Index: 3, Term: 2, Data: []byte("b")}, {Index: 4, Term: 2, Data: []byte("c")}}))
+	assert.Equal(t, ErrLogNotContinuous, storage.Append([]Entry{{Index: 6, Term: 2}}))
+
+	storage = reopen()
+	state, snapshot, saved, err := storage.InitialState()
+	assert.Nil(t, err)
+	assert.Equal(t, HardState{Term: 2, Vote: 1}, state)
+	assert.Nil(t, snapshot)
+	assert.Equal(t, []Entry{entries[0], entries[1], {Index: 3, Term: 2, Data: []byte("b")}, {Index: 4, Term: 2, Data: []byte("c")}}, saved)
+
+	// entries after snapshot are kept
+	writer, err := storage.CreateSnapshot(Snapshot{Index: 3, Term: 2})
+	assert.Nil(t, err)
+	_, err = writer.Write([]byte("snapshot-3"))
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Commit())
+
+	// older snapshot is rejected, aborted one is dropped
+	writer, err = storage.CreateSnapshot(Snapshot{Index: 2, Term: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, ErrSnapshotOutOfDate, writer.Commit())
+	writer, err = storage.CreateSnapshot(Snapshot{Index: 4, Term: 2})
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Abort())
+
+	assert.Nil(t, storage.Append([]Entry{{Index: 5, Term: 3, Data: []byte("d")}}))
+	storage = reopen()
+	_, snapshot, saved, err = storage.InitialState()
+	assert.Nil(t, err)
+	assert.Equal(t, &Snapshot{Index: 3, Term: 2}, snapshot)
+	assert.Equal(t, []Entry{{Index: 4, Term: 2, Data: []byte("c")}, {Index: 5, Term: 3, Data: []byte("d")}}, saved)
+	snap, data := readSnapshot(t, storage)
+	assert.Equal(t, Snapshot{Index: 3, Term: 2}, snap)
+	assert.Equal(t, []byte("snapshot-3"), data)
+
+	// all the entries are dropped if log doesn't have the last entry of snapshot
+	writer, err = storage.CreateSnapshot(Snapshot{Index: 8, Term: 4})
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Commit())
+	assert.Nil(t, storage.Append([]Entry{{Index: 9, Term: 4}}))
+	storage = reopen()
+	_, snapshot, saved, err = storage.InitialState()
+	assert.Nil(t, err)
+	assert.Equal(t, &Snapshot{Index: 8, Term: 4}, snapshot)
+	assert.Equal(t, []Entry{{Index: 9, Term: 4}}, saved)
+}
+
+func TestMemoryStorage(t *testing.T) {
+	storage := NewMemoryStorage()
+	testStorage(t, storage, func() Storage {
+		return storage
+	})
+}
+
+func TestFileStorage(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "bitcask-go-raft")
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	storage, err := OpenFileStorage(dir)
+	assert.Nil(t, err)
+	testStorage(t, storage, func() Storage {
+		assert.Nil(t, storage.Close())
+		storage, err = OpenFileStorage(dir)
+		assert.Nil(t, err)
+		return storage
+	})
+	defer func() {
+		_ = storage.Close()
+	}()
+
+	// torn entry at the end of log is truncated
+	assert.Nil(t, storage.Append([]Entry{{Index: 10, Term: 4, Data: []byte("torn")}}))
+	assert.Nil(t, storage.Close())
+	stat, err := os.Stat(filepath.Join(dir, logFileName))
+	assert.Nil(t, err)
+	assert.Nil(t, os.Truncate(filepath.Join(dir, logFileName), stat.Size()-2))
+	storage, err = OpenFileStorage(dir)
+	assert.Nil(t, err)
+	_, _, saved, err := storage.InitialState()
+	assert.Nil(t, err)
+	assert.Equal(t, []Entry{{Index: 9, Term: 4}}, saved)
+	assert.Nil(t, storage.Append([]Entry{{Index: 10, Term: 5}}))
+}
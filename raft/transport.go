@@ -0,0 +1,59 @@
+package raft
+
+import "sync"
+
+// InMemoryTransport deliver messages between nodes in the same process, nodes can be disconnected
+// to simulate network partition or crash
+type InMemoryTransport struct {
+	mu           *sync.RWMutex
+	nodes        map[uint64]*Node
+	disconnected map[uint64]bool
+}
+
+func NewInMemoryTransport() *InMemoryTransport {
+	return &InMemoryTransport{
+		mu:           new(sync.RWMutex),
+		nodes:        make(map[uint64]*Node),
+		disconnected: make(map[uint64]bool),
+	}
+}
+
+// Register node to receive messages, node registered with the same id is replaced
+func (t *InMemoryTransport) Register(id uint64, node *Node) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nodes[id] = node
+}
+
+// Unregister node, messages to it are dropped
+func (t *InMemoryTransport) Unregister(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.nodes, id)
+}
+
+// Disconnect node from others, messages from and to it are dropped
+func (t *InMemoryTransport) Disconnect(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.disconnected[id] = true
+}
+
+// Connect node back to others
+func (t *InMemoryTransport) Connect(id uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.disconnected, id)
+}
+
+func (t *InMemoryTransport) Send(msg Message) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if t.disconnected[msg.From] || t.disconnected[msg.To] {
+		return
+	}
+	if node, ok := t.nodes[msg.To]; ok {
+		node.Step(msg)
+	}
+}
@@ -5,11 +5,15 @@ import (
 	"bitcask-go/redis"
 	"github.com/tidwall/redcon"
 	"log"
+	"net/http"
 	"sync"
 )
 
 const addr = "localhost:6380"
 
+// metrics in Prometheus text format are served at http://localhost:9121/metrics
+const metricsAddr = "localhost:9121"
+
 type Server struct {
 	databaseMap  map[int]*redis.RedisDataStruct
 	redconServer *redcon.Server
@@ -23,7 +27,9 @@ func main() {
 	}
 
 	// initiate a redis instance
-	dataStruct, err := redis.NewRedisDataStruct(bitcask.DefaultConfig)
+	config := bitcask.DefaultConfig
+	config.EnableMetrics = true
+	dataStruct, err := redis.NewRedisDataStruct(config)
 	if err != nil {
 		panic(err)
 	}
@@ -31,9 +37,19 @@ func main() {
 
 	server.redconServer = redcon.NewServer(addr, execClientCommand, server.accept, server.close)
 
+	go serveMetrics(dataStruct.MetricsHandler())
 	server.listen()
 }
 
+func serveMetrics(handler http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	log.Println("Serving metrics on: ", metricsAddr)
+	if err := http.ListenAndServe(metricsAddr, mux); err != nil {
+		log.Println("metrics server stopped: ", err)
+	}
+}
+
 func (server *Server) listen() {
 	log.Println("Listening on: ", addr)
 	err := server.redconServer.ListenAndServe()
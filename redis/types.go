@@ -5,6 +5,7 @@ import (
 	"bitcask-go/utils"
 	"encoding/binary"
 	"errors"
+	"net/http"
 	"time"
 )
 
@@ -40,6 +41,11 @@ func (rds *RedisDataStruct) Close() error {
 	return rds.db.Close()
 }
 
+// MetricsHandler serve metrics of database in Prometheus text format
+func (rds *RedisDataStruct) MetricsHandler() http.Handler {
+	return rds.db.MetricsHandler()
+}
+
 // -------------------> Redis String <-----------------------------
 
 // Set encode type+expire+value in Value
@@ -0,0 +1,487 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/storage"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	replicaDialTimeout       = 3 * time.Second
+	replicaReconnectInterval = 100 * time.Millisecond
+)
+
+// Replica follow the log of primary, data files of replica are the same as primary's,
+// records are applied to index as they are received. The database is read only
+type Replica struct {
+	db          *DB
+	primaryAddr string
+	mu          *sync.Mutex
+	conn        net.Conn
+	stopCh      chan struct{}
+	wg          *sync.WaitGroup
+	lag         atomic.Int64
+
+	// state of applying log, only used by following goroutine
+	next          *storage.LogRecordPos // position after the last byte received
+	partial       []byte                // bytes of incomplete record received
+	unwritten     []*replicatedRecord   // records of unfinished transaction, written to data file once it's finished
+	mergeBoundary uint32                // records of files before it are merged, they are applied without transaction finish
+}
+
+// replicatedRecord log record received from primary and its raw bytes
+type replicatedRecord struct {
+	record  *storage.LogRecord
+	pos     *storage.LogRecordPos
+	raw     []byte
+	aborted bool // record of transaction never finished, it's written but not applied to index like primary does
+}
+
+// OpenReplica open database in read only mode and follow the primary at primaryAddr, reconnect if connection is broken
+func OpenReplica(config Config, primaryAddr string) (*Replica, error) {
+	if config.IndexerType == index.BPlusTreeIndexType {
+		return nil, errors.New("replica doesn't support bplus tree index")
+	}
+
+	config.ReadOnly = true
+	db, err := OpenDatabase(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.waitIndexReady(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	// merge finish file of primary is kept since last reset
+	mergeBoundary, err := db.replicationMergeBoundary()
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	replica := &Replica{
+		db:            db,
+		primaryAddr:   primaryAddr,
+		mu:            new(sync.Mutex),
+		stopCh:        make(chan struct{}),
+		wg:            new(sync.WaitGroup),
+		mergeBoundary: mergeBoundary,
+	}
+	if err := db.setReplication(replica); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	replica.wg.Add(1)
+	go replica.follow()
+	return replica, nil
+}
+
+// DB read only database of replica
+func (r *Replica) DB() *DB {
+	return r.db
+}
+
+// Close stop following primary and close database
+func (r *Replica) Close() error {
+	return r.db.Close()
+}
+
+func (r *Replica) lagInBytes() int64 {
+	return r.lag.Load()
+}
+
+func (r *Replica) stop() {
+	r.mu.Lock()
+	select {
+	case <-r.stopCh:
+	default:
+		close(r.stopCh)
+		if r.conn != nil {
+			_ = r.conn.Close()
+		}
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func (r *Replica) follow() {
+	defer r.wg.Done()
+	for {
+		_ = r.followPrimary()
+
+		select {
+		case <-r.stopCh:
+			return
+		case <-time.After(replicaReconnectInterval):
+		}
+	}
+}
+
+// followPrimary subscribe from the end of local log and apply the log shipped until connection is broken
+func (r *Replica) followPrimary() error {
+	conn, err := net.DialTimeout("tcp", r.primaryAddr, replicaDialTimeout)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	select {
+	case <-r.stopCh:
+		r.mu.Unlock()
+		return conn.Close()
+	default:
+	}
+	r.conn = conn
+	r.mu.Unlock()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	// records not written to data file are received again
+	pos := r.db.replicaPosition()
+	r.next = pos
+	if r.next == nil {
+		r.next = &storage.LogRecordPos{Fid: initialDataFileId, Offset: 0}
+	}
+	r.partial = nil
+	r.unwritten = nil
+
+	if err := writeReplicationSubscribe(writer, pos); err != nil {
+		return err
+	}
+
+	header := make([]byte, 24)
+	for {
+		msgType, err := reader.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch msgType {
+		case replicationMsgReset:
+			if err := r.applyReset(reader); err != nil {
+				return err
+			}
+		case replicationMsgData:
+			if _, err := io.ReadFull(reader, header); err != nil {
+				return err
+			}
+			pos := &storage.LogRecordPos{
+				Fid:    binary.BigEndian.Uint32(header),
+				Offset: int64(binary.BigEndian.Uint64(header[4:])),
+			}
+			lag := int64(binary.BigEndian.Uint64(header[12:]))
+			data := make([]byte, binary.BigEndian.Uint32(header[20:]))
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return err
+			}
+
+			if err := r.applyData(pos, data); err != nil {
+				return err
+			}
+			r.lag.Store(lag)
+			if applied := r.db.replicaPosition(); applied != nil {
+				if err := writeReplicationAck(writer, applied); err != nil {
+					return err
+				}
+			}
+		case replicationMsgHeartbeat:
+			if _, err := io.ReadFull(reader, header[:8]); err != nil {
+				return err
+			}
+			r.lag.Store(int64(binary.BigEndian.Uint64(header)))
+		default:
+			return ErrInvalidReplicationMessage
+		}
+	}
+}
+
+// applyReset drop local data and take hint file and merge finish file of primary, log is shipped from start later
+func (r *Replica) applyReset(reader *bufio.Reader) error {
+	hint, err := readReplicationBytes(reader)
+	if err != nil {
+		return err
+	}
+	mergeFinish, err := readReplicationBytes(reader)
+	if err != nil {
+		return err
+	}
+
+	if err := r.db.resetReplicatedData(hint, mergeFinish); err != nil {
+		return err
+	}
+	mergeBoundary, err := r.db.replicationMergeBoundary()
+	if err != nil {
+		return err
+	}
+
+	r.mergeBoundary = mergeBoundary
+	r.next = &storage.LogRecordPos{Fid: initialDataFileId, Offset: 0}
+	r.partial = nil
+	r.unwritten = nil
+	return nil
+}
+
+// applyData decode the complete records of data, write them to data file and update index.
+// Records of a transaction are written once it's finished, so data file never ends with unfinished transaction
+func (r *Replica) applyData(pos *storage.LogRecordPos, data []byte) error {
+	if pos.Fid != r.next.Fid {
+		// new data file of primary starts with a new record
+		if pos.Fid < r.next.Fid || pos.Offset != 0 || len(r.partial) > 0 {
+			return ErrInvalidReplicationMessage
+		}
+	} else if pos.Offset != r.next.Offset {
+		return ErrInvalidReplicationMessage
+	}
+	r.next = &storage.LogRecordPos{Fid: pos.Fid, Offset: pos.Offset + int64(len(data))}
+
+	offset := pos.Offset - int64(len(r.partial))
+	buf := append(r.partial, data...)
+	var decoded int64
+	for decoded < int64(len(buf)) {
+		logRecord, size, err := storage.DecodeLogRecord(buf[decoded:])
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// batch is written at once on primary, records of unfinished batch before are never committed
+		if pos.Fid >= r.mergeBoundary {
+			r.abortUnfinished(logRecord.SequenceNumber)
+		}
+
+		r.unwritten = append(r.unwritten, &replicatedRecord{
+			record: logRecord,
+			pos:    &storage.LogRecordPos{Fid: pos.Fid, Offset: offset + decoded, LogRecordSize: uint32(size)},
+			raw:    buf[decoded : decoded+size],
+		})
+		decoded += size
+
+		// records of merged files are applied like hint file, they have no transaction finish record
+		if logRecord.SequenceNumber == nonTransactionSequenceNumber || logRecord.Type == storage.LogRecordTransactionFinished ||
+			pos.Fid < r.mergeBoundary {
+			if err := r.flushUnwritten(); err != nil {
+				return err
+			}
+		}
+	}
+	r.partial = append([]byte(nil), buf[decoded:]...)
+	return nil
+}
+
+// abortUnfinished mark the unwritten records of transactions other than sequenceNumber as aborted
+func (r *Replica) abortUnfinished(sequenceNumber uint64) {
+	for _, replicated := range r.unwritten {
+		if replicated.record.SequenceNumber != sequenceNumber {
+			replicated.aborted = true
+		}
+	}
+}
+
+// flushUnwritten write the records received to data files at the same positions as primary, then update index
+func (r *Replica) flushUnwritten() error {
+	for start := 0; start < len(r.unwritten); {
+		// records of the same file are written at once
+		end := start + 1
+		size := len(r.unwritten[start].raw)
+		for end < len(r.unwritten) && r.unwritten[end].pos.Fid == r.unwritten[start].pos.Fid {
+			size += len(r.unwritten[end].raw)
+			end++
+		}
+		buf := make([]byte, 0, size)
+		for _, replicated := range r.unwritten[start:end] {
+			buf = append(buf, replicated.raw...)
+		}
+		if err := r.db.appendReplicatedLog(r.unwritten[start].pos, buf); err != nil {
+			return err
+		}
+		start = end
+	}
+
+	for _, replicated := range r.unwritten {
+		r.db.stats.addRecord(replicated.pos.Fid, int64(replicated.pos.LogRecordSize))
+		if replicated.aborted {
+			r.db.updateSequenceNumber(replicated.record.SequenceNumber)
+			continue
+		}
+		if replicated.record.Type == storage.LogRecordTransactionFinished {
+			r.db.reclaim(replicated.pos)
+			continue
+		}
+		if err := r.db.updateLogRecordIndex(replicated.record, replicated.pos); err != nil {
+			return err
+		}
+		r.db.updateSequenceNumber(replicated.record.SequenceNumber)
+	}
+	r.unwritten = nil
+	return nil
+}
+
+// replicaPosition end of local log, nil if there is no data file
+func (db *DB) replicaPosition() *storage.LogRecordPos {
+	db.appendMu.Lock()
+	defer db.appendMu.Unlock()
+
+	if db.activeFile == nil {
+		return nil
+	}
+	return &storage.LogRecordPos{Fid: db.activeFile.FileId, Offset: db.activeFile.WriteOffset}
+}
+
+// appendReplicatedLog write log shipped from primary at the same file and offset as primary
+func (db *DB) appendReplicatedLog(pos *storage.LogRecordPos, data []byte) error {
+	db.appendMu.Lock()
+	defer db.appendMu.Unlock()
+
+	if !db.isOpen.Load() {
+		return ErrDBClosed
+	}
+
+	if db.activeFile == nil || db.activeFile.FileId != pos.Fid {
+		if db.activeFile != nil && pos.Fid < db.activeFile.FileId {
+			return ErrInvalidReplicationMessage
+		}
+		// follow the rotation of primary
+		if err := db.rotateReplicatedDataFile(pos.Fid); err != nil {
+			return err
+		}
+	}
+
+	writeOffset := db.activeFile.WriteOffset
+	if writeOffset != pos.Offset {
+		return ErrInvalidReplicationMessage
+	}
+	if err := db.activeFile.Write(data); err != nil {
+		_ = db.activeFile.Truncate(writeOffset)
+		return err
+	}
+
+	db.totalBytesWritten += uint(len(data))
+	db.stats.bytesWritten.Add(uint64(len(data)))
+	db.notifyAppended()
+	if db.needSyncByPolicy() {
+		return db.syncActiveFile()
+	}
+	return nil
+}
+
+// rotateReplicatedDataFile archive active file and open data file fid as active file
+func (db *DB) rotateReplicatedDataFile(fid uint32) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.activeFile != nil {
+		if err := db.syncDataFile(db.activeFile); err != nil {
+			return err
+		}
+		if err := db.archiveActiveDataFile(); err != nil {
+			return err
+		}
+	}
+
+	dataFile, err := storage.OpenDataFile(db.fs, db.config.DirPath, fid, db.activeFileIOType())
+	if err != nil {
+		return err
+	}
+	db.activeFile = dataFile
+	return nil
+}
+
+// resetReplicatedData remove all the data files and index, then write hint file and merge finish file of primary
+func (db *DB) resetReplicatedData(hint, mergeFinish []byte) error {
+	db.appendMu.Lock()
+	defer db.appendMu.Unlock()
+
+	// index may read keys from data files, so it's cleared before files are removed
+	if err := db.deleteIndexRange(nil, nil, nil); err != nil {
+		return err
+	}
+	db.reclaimSize.Store(0)
+	db.stats.setFileUsages(nil)
+	db.totalBytesWritten = 0
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.activeFile != nil {
+		if err := db.activeFile.Close(); err != nil {
+			return err
+		}
+	}
+	if db.fileCache != nil {
+		if err := db.fileCache.closeAll(); err != nil {
+			return err
+		}
+	} else {
+		for _, inactiveFile := range db.inactiveFiles {
+			if err := inactiveFile.Close(); err != nil {
+				return err
+			}
+		}
+	}
+	db.activeFile = nil
+	db.inactiveFiles = make(map[uint32]*storage.DataFile)
+
+	entries, err := db.fs.ReadDir(db.config.DirPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, storage.DataFileNameSuffix) || name == storage.HintFileName ||
+			name == storage.MergeFinishFileName || name == storage.IndexSnapshotFileName) {
+			continue
+		}
+		if err := db.fs.Remove(filepath.Join(db.config.DirPath, name)); err != nil {
+			return err
+		}
+	}
+
+	if len(hint) > 0 {
+		if err := db.writeFileBytes(storage.GetHintFileName(db.config.DirPath), hint); err != nil {
+			return err
+		}
+	}
+	if len(mergeFinish) > 0 {
+		if err := db.writeFileBytes(filepath.Join(db.config.DirPath, storage.MergeFinishFileName), mergeFinish); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateSequenceNumber keep the max sequence number of records applied
+func (db *DB) updateSequenceNumber(sequenceNumber uint64) {
+	for {
+		current := atomic.LoadUint64(&db.sequenceNumber)
+		if sequenceNumber <= current || atomic.CompareAndSwapUint64(&db.sequenceNumber, current, sequenceNumber) {
+			return
+		}
+	}
+}
+
+func readReplicationBytes(reader *bufio.Reader) ([]byte, error) {
+	sizeBuf := make([]byte, 4)
+	if _, err := io.ReadFull(reader, sizeBuf); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint32(sizeBuf))
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
@@ -0,0 +1,534 @@
+package bitcask_go
+
+import (
+	"bitcask-go/storage"
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// max bytes of log shipped in one message
+	replicationChunkSize = 1024 * 1024
+	// primary sends heartbeat to idle replica, so replica knows it's caught up
+	replicationHeartbeatInterval = time.Second
+)
+
+// messages of replication protocol, each message is type byte followed by big endian fields
+const (
+	// replica -> primary, hasData(1) fid(4) offset(8), position to resume from
+	replicationMsgSubscribe byte = iota + 1
+	// replica -> primary, fid(4) offset(8), position applied by replica
+	replicationMsgAck
+	// primary -> replica, hintSize(4) hint mergeFinishSize(4) mergeFinish, replica drops its data and receives log from start
+	replicationMsgReset
+	// primary -> replica, fid(4) offset(8) lag(8) size(4) data, raw bytes of data file at offset
+	replicationMsgData
+	// primary -> replica, lag(8)
+	replicationMsgHeartbeat
+)
+
+// replicationRole primary or replica side of replication attached to DB
+type replicationRole interface {
+	lagInBytes() int64
+	stop()
+}
+
+// ReplicationServer ship the log appended to primary's data files to replicas over TCP.
+// Replicas resume from the last position they applied, and receive all the data again
+// if the files they are reading from have been rewritten by merge
+type ReplicationServer struct {
+	db       *DB
+	listener net.Listener
+	mu       *sync.Mutex
+	replicas map[net.Conn]*replicaState
+	stopCh   chan struct{}
+	wg       *sync.WaitGroup
+}
+
+// replicaState position acked by a connected replica
+type replicaState struct {
+	acked atomic.Pointer[storage.LogRecordPos]
+}
+
+// StartReplicationServer listen on addr and ship log to the replicas connecting to it
+func (db *DB) StartReplicationServer(addr string) (*ReplicationServer, error) {
+	if !db.isOpen.Load() {
+		return nil, ErrDBClosed
+	}
+	if db.config.ReadOnly {
+		return nil, ErrReadOnlyDatabase
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &ReplicationServer{
+		db:       db,
+		listener: listener,
+		mu:       new(sync.Mutex),
+		replicas: make(map[net.Conn]*replicaState),
+		stopCh:   make(chan struct{}),
+		wg:       new(sync.WaitGroup),
+	}
+	if err := db.setReplication(server); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	server.wg.Add(1)
+	go server.accept()
+	return server, nil
+}
+
+// Addr address the server is listening on
+func (s *ReplicationServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stop listening and disconnect all the replicas
+func (s *ReplicationServer) Close() error {
+	s.stop()
+	s.db.mu.Lock()
+	if s.db.replication == s {
+		s.db.replication = nil
+	}
+	s.db.mu.Unlock()
+	return nil
+}
+
+func (s *ReplicationServer) stop() {
+	s.mu.Lock()
+	select {
+	case <-s.stopCh:
+	default:
+		close(s.stopCh)
+		_ = s.listener.Close()
+		for conn := range s.replicas {
+			_ = conn.Close()
+		}
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// lagInBytes max bytes of log not acked by connected replicas
+func (s *ReplicationServer) lagInBytes() int64 {
+	end, _ := s.db.replicationEnd()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var lag int64
+	for _, state := range s.replicas {
+		lag = max(lag, s.db.replicationDistance(state.acked.Load(), end))
+	}
+	return lag
+}
+
+func (s *ReplicationServer) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		state := &replicaState{}
+		s.mu.Lock()
+		select {
+		case <-s.stopCh:
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		default:
+		}
+		s.replicas[conn] = state
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serve(conn, state)
+	}
+}
+
+// serve ship log to replica until connection is broken or server is closed
+func (s *ReplicationServer) serve(conn net.Conn, state *replicaState) {
+	defer s.wg.Done()
+	defer func() {
+		_ = conn.Close()
+		s.mu.Lock()
+		delete(s.replicas, conn)
+		s.mu.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+
+	pos, err := readReplicationSubscribe(reader)
+	if err != nil {
+		return
+	}
+	state.acked.Store(pos)
+
+	// acks are read in background, connection is closed once shipping stops
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			ackPos, err := readReplicationAck(reader)
+			if err != nil {
+				_ = conn.Close()
+				return
+			}
+			state.acked.Store(ackPos)
+		}
+	}()
+
+	// replica reading the files rewritten by merge has to receive all the data again
+	boundary, err := s.db.replicationMergeBoundary()
+	if err != nil {
+		return
+	}
+	if boundary > 0 && (pos == nil || pos.Fid < boundary) {
+		if err := s.sendReset(writer); err != nil {
+			return
+		}
+		pos = nil
+	}
+	if pos == nil {
+		pos = &storage.LogRecordPos{Fid: initialDataFileId, Offset: 0}
+	}
+
+	_ = s.ship(writer, pos)
+}
+
+// ship log from pos, wait for appending once replica catches up
+func (s *ReplicationServer) ship(writer *bufio.Writer, pos *storage.LogRecordPos) error {
+	heartbeat := time.NewTicker(replicationHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		end, appended := s.db.replicationEnd()
+
+		var size int64
+		switch {
+		case end == nil:
+			if pos.Fid != initialDataFileId || pos.Offset != 0 {
+				return ErrInvalidReplicationMessage
+			}
+		case pos.Fid < end.Fid:
+			fileSize, ok, err := s.db.dataFileSizeOnDisk(pos.Fid)
+			if err != nil {
+				return err
+			}
+			if !ok || pos.Offset >= fileSize {
+				// file is finished or removed by merge, go on with next one
+				pos = &storage.LogRecordPos{Fid: pos.Fid + 1, Offset: 0}
+				continue
+			}
+			size = fileSize - pos.Offset
+		case pos.Fid == end.Fid && pos.Offset <= end.Offset:
+			size = end.Offset - pos.Offset
+		default:
+			// replica is ahead of primary, it isn't following this primary
+			return ErrInvalidReplicationMessage
+		}
+
+		if size > 0 {
+			data, err := s.db.readDataFileBytes(pos.Fid, pos.Offset, min(size, replicationChunkSize))
+			if err != nil {
+				return err
+			}
+			next := &storage.LogRecordPos{Fid: pos.Fid, Offset: pos.Offset + int64(len(data))}
+			if err := writeReplicationData(writer, pos, s.db.replicationDistance(next, end), data); err != nil {
+				return err
+			}
+			pos = next
+			continue
+		}
+
+		select {
+		case <-s.stopCh:
+			return nil
+		case <-appended:
+		case <-heartbeat.C:
+			if err := writeReplicationHeartbeat(writer, 0); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// sendReset send hint file and merge finish file, so replica loads merged files like primary after restart
+func (s *ReplicationServer) sendReset(writer *bufio.Writer) error {
+	hint, err := s.db.readFileBytes(storage.GetHintFileName(s.db.config.DirPath))
+	if err != nil {
+		return err
+	}
+	mergeFinish, err := s.db.readFileBytes(filepath.Join(s.db.config.DirPath, storage.MergeFinishFileName))
+	if err != nil {
+		return err
+	}
+
+	buf := []byte{replicationMsgReset}
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(hint)))
+	buf = append(buf, hint...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(mergeFinish)))
+	buf = append(buf, mergeFinish...)
+	if _, err := writer.Write(buf); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// setReplication attach primary or replica to db, db plays only one role
+func (db *DB) setReplication(role replicationRole) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.replication != nil {
+		return ErrReplicationStarted
+	}
+	db.replication = role
+	return nil
+}
+
+// stopReplication stop replication before closing files
+func (db *DB) stopReplication() {
+	db.mu.RLock()
+	replication := db.replication
+	db.mu.RUnlock()
+
+	if replication != nil {
+		replication.stop()
+	}
+}
+
+// notifyAppended wake up replication waiting for log, appendMu must be held
+func (db *DB) notifyAppended() {
+	if db.appendedCh != nil {
+		close(db.appendedCh)
+		db.appendedCh = make(chan struct{})
+	}
+}
+
+// replicationEnd end of appended log, nil if there is no data file, and channel closed on next append
+func (db *DB) replicationEnd() (*storage.LogRecordPos, chan struct{}) {
+	db.appendMu.Lock()
+	defer db.appendMu.Unlock()
+
+	if db.appendedCh == nil {
+		db.appendedCh = make(chan struct{})
+	}
+	if db.activeFile == nil {
+		return nil, db.appendedCh
+	}
+	return &storage.LogRecordPos{Fid: db.activeFile.FileId, Offset: db.activeFile.WriteOffset}, db.appendedCh
+}
+
+// replicationDistance bytes of log from one position to another, nil from means start of log
+func (db *DB) replicationDistance(from, to *storage.LogRecordPos) int64 {
+	if to == nil {
+		return 0
+	}
+	if from == nil {
+		from = &storage.LogRecordPos{Fid: initialDataFileId, Offset: 0}
+	}
+	if from.Fid == to.Fid {
+		return max(to.Offset-from.Offset, 0)
+	}
+
+	var distance int64
+	for fid := from.Fid; fid < to.Fid; fid++ {
+		size, ok, err := db.dataFileSizeOnDisk(fid)
+		if err != nil || !ok {
+			continue
+		}
+		if fid == from.Fid {
+			size -= from.Offset
+		}
+		distance += max(size, 0)
+	}
+	return distance + to.Offset
+}
+
+// replicationMergeBoundary first file id not merged, the files before it are rewritten by merge, 0 if never merged
+func (db *DB) replicationMergeBoundary() (uint32, error) {
+	buf, err := db.readFileBytes(filepath.Join(db.config.DirPath, storage.MergeFinishFileName))
+	if err != nil || buf == nil {
+		return 0, err
+	}
+
+	finishRecord, _, err := storage.DecodeLogRecord(buf)
+	if err != nil {
+		return 0, err
+	}
+	nonMergeFileId, err := strconv.Atoi(string(finishRecord.Value))
+	if err != nil {
+		return 0, err
+	}
+	return uint32(nonMergeFileId), nil
+}
+
+// dataFileSizeOnDisk size of inactive data file, false if it doesn't exist
+func (db *DB) dataFileSizeOnDisk(fid uint32) (int64, bool, error) {
+	info, err := db.fs.Stat(storage.GetDataFileName(db.config.DirPath, fid))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	return info.Size(), true, nil
+}
+
+// readDataFileBytes read n raw bytes of data file from offset
+func (db *DB) readDataFileBytes(fid uint32, offset int64, n int64) ([]byte, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dataFile, release, err := db.acquireDataFile(fid)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return dataFile.ReadBytes(n, offset)
+}
+
+// readFileBytes read the whole file, nil if it doesn't exist
+func (db *DB) readFileBytes(fileName string) ([]byte, error) {
+	if _, err := db.fs.Stat(fileName); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ioManager, err := db.fs.OpenFile(fileName, db.fileIOType())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = ioManager.Close()
+	}()
+
+	size, err := ioManager.Size()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if _, err := ioManager.Read(buf, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// writeFileBytes replace file with data and flush it
+func (db *DB) writeFileBytes(fileName string, data []byte) error {
+	ioManager, err := db.fs.OpenFile(fileName, db.fileIOType())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ioManager.Close()
+	}()
+
+	if err := ioManager.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := ioManager.Write(data); err != nil {
+		return err
+	}
+	return ioManager.Sync()
+}
+
+func readReplicationSubscribe(reader *bufio.Reader) (*storage.LogRecordPos, error) {
+	buf := make([]byte, 14)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return nil, err
+	}
+	if buf[0] != replicationMsgSubscribe {
+		return nil, ErrInvalidReplicationMessage
+	}
+	if buf[1] == 0 {
+		return nil, nil
+	}
+	return &storage.LogRecordPos{
+		Fid:    binary.BigEndian.Uint32(buf[2:]),
+		Offset: int64(binary.BigEndian.Uint64(buf[6:])),
+	}, nil
+}
+
+func writeReplicationSubscribe(writer *bufio.Writer, pos *storage.LogRecordPos) error {
+	buf := []byte{replicationMsgSubscribe, 0}
+	if pos != nil {
+		buf[1] = 1
+	} else {
+		pos = &storage.LogRecordPos{}
+	}
+	buf = binary.BigEndian.AppendUint32(buf, pos.Fid)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(pos.Offset))
+	if _, err := writer.Write(buf); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func readReplicationAck(reader *bufio.Reader) (*storage.LogRecordPos, error) {
+	buf := make([]byte, 13)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return nil, err
+	}
+	if buf[0] != replicationMsgAck {
+		return nil, ErrInvalidReplicationMessage
+	}
+	return &storage.LogRecordPos{
+		Fid:    binary.BigEndian.Uint32(buf[1:]),
+		Offset: int64(binary.BigEndian.Uint64(buf[5:])),
+	}, nil
+}
+
+func writeReplicationAck(writer *bufio.Writer, pos *storage.LogRecordPos) error {
+	buf := []byte{replicationMsgAck}
+	buf = binary.BigEndian.AppendUint32(buf, pos.Fid)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(pos.Offset))
+	if _, err := writer.Write(buf); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func writeReplicationData(writer *bufio.Writer, pos *storage.LogRecordPos, lag int64, data []byte) error {
+	buf := make([]byte, 0, 25)
+	buf = append(buf, replicationMsgData)
+	buf = binary.BigEndian.AppendUint32(buf, pos.Fid)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(pos.Offset))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(lag))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(data)))
+	if _, err := writer.Write(buf); err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func writeReplicationHeartbeat(writer *bufio.Writer, lag int64) error {
+	buf := []byte{replicationMsgHeartbeat}
+	buf = binary.BigEndian.AppendUint64(buf, uint64(lag))
+	if _, err := writer.Write(buf); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,257 @@
+package bitcask_go
+
+import (
+	"bitcask-go/storage"
+	"bitcask-go/utils"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newReplicationTestConfig(pattern string) Config {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", pattern)
+	configs.DirPath = dir
+	configs.DataFileSize = 32 * 1024
+	configs.MergeRatio = 0
+	return configs
+}
+
+// waitReplicated wait until replica applied all the log of primary
+func waitReplicated(t *testing.T, primary *DB, replica *Replica) {
+	assert.Eventually(t, func() bool {
+		end, _ := primary.replicationEnd()
+		pos := replica.DB().replicaPosition()
+		return end != nil && pos != nil && *end == *pos
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func checkReplicatedValues(t *testing.T, primary *DB, replica *Replica) {
+	primaryKeys := primary.ListKeys()
+	assert.Equal(t, primaryKeys, replica.DB().ListKeys())
+	for _, key := range primaryKeys {
+		expected, err := primary.Get(key)
+		assert.Nil(t, err)
+		value, err := replica.DB().Get(key)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, value)
+	}
+}
+
+func TestReplication_ShipLog(t *testing.T) {
+	primaryConfig := newReplicationTestConfig("bitcask_test_primary")
+	primary, err := OpenDatabase(primaryConfig)
+	defer destroyDatabase(primary)
+	assert.Nil(t, err)
+
+	server, err := primary.StartReplicationServer("127.0.0.1:0")
+	assert.Nil(t, err)
+	defer server.Close()
+
+	replicaConfig := newReplicationTestConfig("bitcask_test_replica")
+	replica, err := OpenReplica(replicaConfig, server.Addr().String())
+	assert.Nil(t, err)
+	defer destroyDatabase(replica.DB())
+
+	// records span several data files
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, primary.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, primary.Delete(utils.GenerateTestKey(i)))
+	}
+	batch := primary.NewWriteBatch(DefaultWriteBatchConfig)
+	for i := 1000; i < 1100; i++ {
+		assert.Nil(t, batch.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, batch.Commit())
+	assert.Nil(t, primary.DeleteRange(utils.GenerateTestKey(500), utils.GenerateTestKey(600)))
+
+	waitReplicated(t, primary, replica)
+	checkReplicatedValues(t, primary, replica)
+	assert.Greater(t, len(replica.DB().inactiveFiles), 0)
+
+	// replica is read only
+	assert.Equal(t, ErrReadOnlyDatabase, replica.DB().Put(utils.GenerateTestKey(1), []byte("value")))
+	assert.Equal(t, ErrReadOnlyDatabase, replica.DB().Delete(utils.GenerateTestKey(1)))
+	assert.Equal(t, ErrReadOnlyDatabase, replica.DB().Merge())
+
+	// lag is zero on both sides once replica catches up
+	assert.Eventually(t, func() bool {
+		primaryStats, err := primary.Stats()
+		assert.Nil(t, err)
+		replicaStats, err := replica.DB().Stats()
+		assert.Nil(t, err)
+		return primaryStats.ReplicationLagInBytes == 0 && replicaStats.ReplicationLagInBytes == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// replica keeps the data after restart
+	assert.Nil(t, replica.Close())
+	replica, err = OpenReplica(replicaConfig, server.Addr().String())
+	assert.Nil(t, err)
+	checkReplicatedValues(t, primary, replica)
+}
+
+func TestReplication_Resume(t *testing.T) {
+	primaryConfig := newReplicationTestConfig("bitcask_test_primary")
+	primary, err := OpenDatabase(primaryConfig)
+	defer destroyDatabase(primary)
+	assert.Nil(t, err)
+
+	server, err := primary.StartReplicationServer("127.0.0.1:0")
+	assert.Nil(t, err)
+	defer server.Close()
+	addr := server.Addr().String()
+
+	replicaConfig := newReplicationTestConfig("bitcask_test_replica")
+	replica, err := OpenReplica(replicaConfig, addr)
+	assert.Nil(t, err)
+	defer destroyDatabase(replica.DB())
+
+	for i := 0; i < 300; i++ {
+		assert.Nil(t, primary.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+	waitReplicated(t, primary, replica)
+	assert.Nil(t, replica.Close())
+
+	// writes while replica is offline, including rotation
+	for i := 300; i < 800; i++ {
+		assert.Nil(t, primary.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+
+	replica, err = OpenReplica(replicaConfig, addr)
+	assert.Nil(t, err)
+	waitReplicated(t, primary, replica)
+	checkReplicatedValues(t, primary, replica)
+
+	// broken connection is reconnected
+	server.mu.Lock()
+	for conn := range server.replicas {
+		_ = conn.Close()
+	}
+	server.mu.Unlock()
+	for i := 800; i < 900; i++ {
+		assert.Nil(t, primary.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+	waitReplicated(t, primary, replica)
+	checkReplicatedValues(t, primary, replica)
+}
+
+func TestReplication_UnfinishedBatch(t *testing.T) {
+	primaryConfig := newReplicationTestConfig("bitcask_test_primary")
+	primary, err := OpenDatabase(primaryConfig)
+	defer destroyDatabase(primary)
+	assert.Nil(t, err)
+
+	server, err := primary.StartReplicationServer("127.0.0.1:0")
+	assert.Nil(t, err)
+	defer server.Close()
+
+	replicaConfig := newReplicationTestConfig("bitcask_test_replica")
+	replica, err := OpenReplica(replicaConfig, server.Addr().String())
+	assert.Nil(t, err)
+	defer destroyDatabase(replica.DB())
+
+	// records of a batch whose commit failed before the finish record
+	primary.appendMu.Lock()
+	sequenceNumber := atomic.AddUint64(&primary.sequenceNumber, 1)
+	for i := 0; i < 10; i++ {
+		_, err := primary.appendLogRecord(&storage.LogRecord{
+			Key:            utils.GenerateTestKey(i),
+			Value:          utils.GenerateTestKey(i),
+			Type:           storage.LogRecordNormal,
+			SequenceNumber: sequenceNumber,
+		})
+		assert.Nil(t, err)
+	}
+	primary.appendMu.Unlock()
+
+	for i := 10; i < 20; i++ {
+		assert.Nil(t, primary.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+	batch := primary.NewWriteBatch(DefaultWriteBatchConfig)
+	assert.Nil(t, batch.Put(utils.GenerateTestKey(20), utils.GenerateTestKey(20)))
+	assert.Nil(t, batch.Commit())
+
+	waitReplicated(t, primary, replica)
+	checkReplicatedValues(t, primary, replica)
+	_, err = replica.DB().Get(utils.GenerateTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// unfinished batch is skipped when loading data files too
+	assert.Nil(t, replica.Close())
+	replica, err = OpenReplica(replicaConfig, server.Addr().String())
+	assert.Nil(t, err)
+	checkReplicatedValues(t, primary, replica)
+}
+
+func TestReplication_Merge(t *testing.T) {
+	primaryConfig := newReplicationTestConfig("bitcask_test_primary")
+	primary, err := OpenDatabase(primaryConfig)
+	assert.Nil(t, err)
+
+	server, err := primary.StartReplicationServer("127.0.0.1:0")
+	assert.Nil(t, err)
+
+	replicaConfig := newReplicationTestConfig("bitcask_test_replica")
+	replica, err := OpenReplica(replicaConfig, server.Addr().String())
+	assert.Nil(t, err)
+	defer destroyDatabase(replica.DB())
+
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 500; i++ {
+			value := []byte(fmt.Sprintf("value-%d-%d", round, i))
+			assert.Nil(t, primary.Put(utils.GenerateTestKey(i), value))
+		}
+	}
+	batch := primary.NewWriteBatch(DefaultWriteBatchConfig)
+	for i := 500; i < 600; i++ {
+		assert.Nil(t, batch.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, batch.Commit())
+
+	// merged files replace the files replica has read after primary restarts
+	assert.Nil(t, primary.Merge())
+	for i := 600; i < 700; i++ {
+		assert.Nil(t, primary.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	waitReplicated(t, primary, replica)
+	assert.Nil(t, replica.Close())
+	assert.Nil(t, primary.Close())
+	primary, err = OpenDatabase(primaryConfig)
+	defer destroyDatabase(primary)
+	assert.Nil(t, err)
+	server, err = primary.StartReplicationServer("127.0.0.1:0")
+	assert.Nil(t, err)
+	defer server.Close()
+
+	// replica behind the merged files receives all the data again
+	replicaConfig2 := newReplicationTestConfig("bitcask_test_replica")
+	replica2, err := OpenReplica(replicaConfig2, server.Addr().String())
+	assert.Nil(t, err)
+	defer destroyDatabase(replica2.DB())
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, primary.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	waitReplicated(t, primary, replica2)
+	checkReplicatedValues(t, primary, replica2)
+	assert.Greater(t, replica2.mergeBoundary, uint32(0))
+
+	// replica restarted after reset loads merged files through hint file like primary
+	assert.Nil(t, replica2.Close())
+	replica2, err = OpenReplica(replicaConfig2, server.Addr().String())
+	assert.Nil(t, err)
+	checkReplicatedValues(t, primary, replica2)
+	assert.Greater(t, replica2.mergeBoundary, uint32(0))
+
+	// replica which has read the log after merged files only receives new log
+	replica, err = OpenReplica(replicaConfig, server.Addr().String())
+	assert.Nil(t, err)
+	waitReplicated(t, primary, replica)
+	checkReplicatedValues(t, primary, replica)
+	assert.Equal(t, uint32(0), replica.mergeBoundary)
+}
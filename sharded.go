@@ -0,0 +1,282 @@
+package bitcask_go
+
+import (
+	"bitcask-go/fio"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const shardDirPrefix = "shard-"
+
+// ShardedDB partition keys by hash across shards, each shard is a DB in its own subdirectory
+// with its own active file and lock, so writes of different shards don't block each other
+type ShardedDB struct {
+	config Config
+	shards []*DB
+}
+
+// OpenShardedDatabase open shardNum shards under config.DirPath, shard number can't be changed
+// once the database is created, since keys are placed by hash
+func OpenShardedDatabase(config Config, shardNum int) (*ShardedDB, error) {
+	if shardNum <= 0 {
+		return nil, ErrInvalidShardNum
+	}
+	if err := checkDbConfig(config); err != nil {
+		return nil, err
+	}
+
+	// shards are opened by the same file system
+	fs := config.FileSystem
+	if fs == nil {
+		fs = fio.NewFileSystem(fio.StandardFileIOType)
+		// every in memory shard has its own files, nothing is left by earlier opening
+		if config.InMemory {
+			fs = fio.NewMemoryFileSystem()
+		}
+	}
+	existShardNum, err := countShardDirs(fs, config.DirPath)
+	if err != nil {
+		return nil, err
+	}
+	if existShardNum > 0 && existShardNum != shardNum {
+		return nil, ErrShardNumMismatch
+	}
+
+	// shards load index in parallel
+	shards := make([]*DB, shardNum)
+	errs := make([]error, shardNum)
+	wg := new(sync.WaitGroup)
+	for i := range shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			shardConfig := config
+			shardConfig.DirPath = getShardDirPath(config.DirPath, i)
+			shards[i], errs[i] = OpenDatabase(shardConfig)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		for _, shard := range shards {
+			if shard != nil {
+				_ = shard.Close()
+			}
+		}
+		return nil, err
+	}
+
+	return &ShardedDB{config: config, shards: shards}, nil
+}
+
+// Put key/value into the shard of key
+func (sdb *ShardedDB) Put(key []byte, value []byte) error {
+	return sdb.PutWithOptions(key, value, DefaultWriteOptions)
+}
+
+func (sdb *ShardedDB) PutWithOptions(key []byte, value []byte, opts WriteOptions) error {
+	return sdb.getShard(key).PutWithOptions(key, value, opts)
+}
+
+func (sdb *ShardedDB) Get(key []byte) ([]byte, error) {
+	return sdb.getShard(key).Get(key)
+}
+
+func (sdb *ShardedDB) Delete(key []byte) error {
+	return sdb.DeleteWithOptions(key, DefaultWriteOptions)
+}
+
+func (sdb *ShardedDB) DeleteWithOptions(key []byte, opts WriteOptions) error {
+	return sdb.getShard(key).DeleteWithOptions(key, opts)
+}
+
+// Merge all the shards in parallel, ErrMergeRatioNotSatisfied is only returned if no shard satisfies merge ratio
+func (sdb *ShardedDB) Merge() error {
+	errs := make([]error, len(sdb.shards))
+	wg := new(sync.WaitGroup)
+	for i, shard := range sdb.shards {
+		wg.Add(1)
+		go func(i int, shard *DB) {
+			defer wg.Done()
+			errs[i] = shard.Merge()
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var notSatisfied int
+	for i, err := range errs {
+		if err == ErrMergeRatioNotSatisfied {
+			notSatisfied++
+			errs[i] = nil
+		}
+	}
+	if notSatisfied == len(sdb.shards) {
+		return ErrMergeRatioNotSatisfied
+	}
+	return errors.Join(errs...)
+}
+
+// Stats sum up the stats of all shards, file ids of shards overlap so stats of data files are not reported
+func (sdb *ShardedDB) Stats() (Stats, error) {
+	stats := Stats{IndexReady: true}
+	for _, shard := range sdb.shards {
+		shardStats, err := shard.Stats()
+		if err != nil {
+			return Stats{}, err
+		}
+		stats.KeyNum += shardStats.KeyNum
+		stats.DataFileNum += shardStats.DataFileNum
+		stats.ReclaimableSizeInBytes += shardStats.ReclaimableSizeInBytes
+		stats.TotalFileSizeInBytes += shardStats.TotalFileSizeInBytes
+		stats.IndexReady = stats.IndexReady && shardStats.IndexReady
+		stats.IndexMemoryInBytes += shardStats.IndexMemoryInBytes
+		stats.PutCount += shardStats.PutCount
+		stats.GetCount += shardStats.GetCount
+		stats.DeleteCount += shardStats.DeleteCount
+		stats.BatchCommitCount += shardStats.BatchCommitCount
+		stats.MergeCount += shardStats.MergeCount
+		stats.SyncCount += shardStats.SyncCount
+		stats.BytesRead += shardStats.BytesRead
+		stats.BytesWritten += shardStats.BytesWritten
+		if shardStats.LastMergeTime.After(stats.LastMergeTime) {
+			stats.LastMergeTime = shardStats.LastMergeTime
+		}
+	}
+	if stats.KeyNum > 0 {
+		stats.IndexBytesPerKey = float64(stats.IndexMemoryInBytes) / float64(stats.KeyNum)
+	}
+	return stats, nil
+}
+
+// Sync all the shards
+func (sdb *ShardedDB) Sync() error {
+	for _, shard := range sdb.shards {
+		if err := shard.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close all the shards, the first error is returned after all the shards are closed
+func (sdb *ShardedDB) Close() error {
+	var firstErr error
+	for _, shard := range sdb.shards {
+		if err := shard.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ShardNum number of shards
+func (sdb *ShardedDB) ShardNum() int {
+	return len(sdb.shards)
+}
+
+func (sdb *ShardedDB) getShard(key []byte) *DB {
+	return sdb.shards[shardIndex(key, len(sdb.shards))]
+}
+
+// shardIndex hash of key must be stable across restarts, so fnv is used instead of seeded maphash
+func shardIndex(key []byte, shardNum int) int {
+	hash := fnv.New64a()
+	_, _ = hash.Write(key)
+	return int(hash.Sum64() % uint64(shardNum))
+}
+
+func getShardDirPath(dirPath string, shard int) string {
+	return filepath.Join(dirPath, fmt.Sprintf("%s%03d", shardDirPrefix, shard))
+}
+
+// countShardDirs number of shard directories under dirPath, 0 if dirPath doesn't exist
+func countShardDirs(fs fio.FileSystem, dirPath string) (int, error) {
+	entries, err := fs.ReadDir(dirPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var count int
+	for _, entry := range entries {
+		// merge directories of shards are next to them, e.g. shard-000-merge
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), shardDirPrefix) {
+			continue
+		}
+		if _, err := strconv.Atoi(strings.TrimPrefix(entry.Name(), shardDirPrefix)); err == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ShardedWriteBatch split writes into a WriteBatch per shard, each shard commits atomically,
+// but a crash during Commit may leave the batches of some shards committed
+type ShardedWriteBatch struct {
+	sdb     *ShardedDB
+	config  WriteBatchConfig
+	batches map[int]*WriteBatch
+	mu      *sync.Mutex
+}
+
+func (sdb *ShardedDB) NewWriteBatch(config WriteBatchConfig) *ShardedWriteBatch {
+	return &ShardedWriteBatch{
+		sdb:     sdb,
+		config:  config,
+		batches: make(map[int]*WriteBatch),
+		mu:      new(sync.Mutex),
+	}
+}
+
+func (batch *ShardedWriteBatch) Put(key, value []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+	return batch.getBatch(key).Put(key, value)
+}
+
+func (batch *ShardedWriteBatch) Delete(key []byte) error {
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+	return batch.getBatch(key).Delete(key)
+}
+
+// Commit the batches of shards in shard order
+func (batch *ShardedWriteBatch) Commit() error {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+
+	for i := range batch.sdb.shards {
+		shardBatch, ok := batch.batches[i]
+		if !ok {
+			continue
+		}
+		if err := shardBatch.Commit(); err != nil {
+			return err
+		}
+		delete(batch.batches, i)
+	}
+	return nil
+}
+
+func (batch *ShardedWriteBatch) getBatch(key []byte) *WriteBatch {
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+
+	idx := shardIndex(key, len(batch.sdb.shards))
+	shardBatch, ok := batch.batches[idx]
+	if !ok {
+		shardBatch = batch.sdb.shards[idx].NewWriteBatch(batch.config)
+		batch.batches[idx] = shardBatch
+	}
+	return shardBatch
+}
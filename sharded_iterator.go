@@ -0,0 +1,133 @@
+package bitcask_go
+
+import (
+	"bytes"
+	"container/heap"
+)
+
+// ShardedIterator merge the iterators of shards in key order, keys are unique across shards
+type ShardedIterator struct {
+	iterators []*Iterator
+	heap      *shardIteratorHeap
+	config    IteratorConfig
+	count     int // number of keys iterated since rewind or seek
+}
+
+// NewIterator iterate all the shards in order, limit is applied to the merged keys
+func (sdb *ShardedDB) NewIterator(config IteratorConfig) *ShardedIterator {
+	shardConfig := config
+	shardConfig.Limit = 0
+
+	iterators := make([]*Iterator, len(sdb.shards))
+	for i, shard := range sdb.shards {
+		iterators[i] = shard.NewIterator(shardConfig)
+	}
+
+	it := &ShardedIterator{
+		iterators: iterators,
+		heap:      &shardIteratorHeap{reverse: config.Reverse},
+		config:    config,
+	}
+	it.rebuild()
+	return it
+}
+
+// Rewind set iterator to first element in range
+func (it *ShardedIterator) Rewind() {
+	for _, iter := range it.iterators {
+		iter.Rewind()
+	}
+	it.rebuild()
+}
+
+// Seek the first element less/greater than key byte[]
+func (it *ShardedIterator) Seek(key []byte) {
+	for _, iter := range it.iterators {
+		iter.Seek(key)
+	}
+	it.rebuild()
+}
+
+// Next go to next element
+func (it *ShardedIterator) Next() {
+	if it.heap.Len() == 0 {
+		return
+	}
+	top := it.heap.iterators[0]
+	top.Next()
+	if top.Valid() {
+		heap.Fix(it.heap, 0)
+	} else {
+		heap.Pop(it.heap)
+	}
+	it.count++
+}
+
+// Valid check if has next element in range and limit
+func (it *ShardedIterator) Valid() bool {
+	if it.config.Limit > 0 && it.count >= it.config.Limit {
+		return false
+	}
+	return it.heap.Len() > 0
+}
+
+// Key current element key
+func (it *ShardedIterator) Key() []byte {
+	return it.heap.iterators[0].Key()
+}
+
+// Value current element value, always nil for key only iterator
+func (it *ShardedIterator) Value() ([]byte, error) {
+	return it.heap.iterators[0].Value()
+}
+
+// Close iterators of all shards
+func (it *ShardedIterator) Close() {
+	for _, iter := range it.iterators {
+		iter.Close()
+	}
+}
+
+// rebuild heap from the valid shard iterators
+func (it *ShardedIterator) rebuild() {
+	it.count = 0
+	it.heap.iterators = it.heap.iterators[:0]
+	for _, iter := range it.iterators {
+		if iter.Valid() {
+			it.heap.iterators = append(it.heap.iterators, iter)
+		}
+	}
+	heap.Init(it.heap)
+}
+
+// shardIteratorHeap keep the shard iterator of the smallest key on top, or the largest one in reverse order
+type shardIteratorHeap struct {
+	iterators []*Iterator
+	reverse   bool
+}
+
+func (h *shardIteratorHeap) Len() int {
+	return len(h.iterators)
+}
+
+func (h *shardIteratorHeap) Less(i, j int) bool {
+	cmp := bytes.Compare(h.iterators[i].Key(), h.iterators[j].Key())
+	if h.reverse {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func (h *shardIteratorHeap) Swap(i, j int) {
+	h.iterators[i], h.iterators[j] = h.iterators[j], h.iterators[i]
+}
+
+func (h *shardIteratorHeap) Push(x any) {
+	h.iterators = append(h.iterators, x.(*Iterator))
+}
+
+func (h *shardIteratorHeap) Pop() any {
+	last := h.iterators[len(h.iterators)-1]
+	h.iterators = h.iterators[:len(h.iterators)-1]
+	return last
+}
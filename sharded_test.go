@@ -0,0 +1,210 @@
+package bitcask_go
+
+import (
+	"bitcask-go/fio"
+	"bitcask-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openShardedTestDatabase(t *testing.T, shardNum int) (*ShardedDB, Config) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_sharded")
+	configs.DirPath = dir
+	configs.DataFileSize = 64 * 1024
+	configs.MergeRatio = 0
+
+	sdb, err := OpenShardedDatabase(configs, shardNum)
+	assert.Nil(t, err)
+	assert.NotNil(t, sdb)
+	return sdb, configs
+}
+
+func destroyShardedDatabase(sdb *ShardedDB) {
+	_ = sdb.Close()
+	_ = os.RemoveAll(sdb.config.DirPath)
+}
+
+func TestShardedDB_PutGetDelete(t *testing.T) {
+	sdb, configs := openShardedTestDatabase(t, 4)
+	defer destroyShardedDatabase(sdb)
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, sdb.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	for i := 0; i < 1000; i += 2 {
+		assert.Nil(t, sdb.Delete(utils.GenerateTestKey(i)))
+	}
+
+	// keys are spread over all the shards
+	for _, shard := range sdb.shards {
+		assert.Greater(t, shard.index.Size(), 0)
+	}
+
+	stats, err := sdb.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(500), stats.KeyNum)
+	assert.Equal(t, 4, sdb.ShardNum())
+
+	// reopen with the same shard number
+	assert.Nil(t, sdb.Close())
+	sdb, err = OpenShardedDatabase(configs, 4)
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		value, err := sdb.Get(utils.GenerateTestKey(i))
+		if i%2 == 0 {
+			assert.Equal(t, ErrKeyNotFound, err)
+		} else {
+			assert.Nil(t, err)
+			assert.Equal(t, utils.GenerateTestKey(i), value)
+		}
+	}
+
+	// shard number can't be changed
+	_, err = OpenShardedDatabase(configs, 8)
+	assert.Equal(t, ErrShardNumMismatch, err)
+	_, err = OpenShardedDatabase(configs, 0)
+	assert.Equal(t, ErrInvalidShardNum, err)
+}
+
+func TestShardedDB_Iterator(t *testing.T) {
+	sdb, _ := openShardedTestDatabase(t, 3)
+	defer destroyShardedDatabase(sdb)
+
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, sdb.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+
+	// keys are merged in order
+	iter := sdb.NewIterator(DefaultIteratorConfig)
+	var i int
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		assert.Equal(t, utils.GenerateTestKey(i), iter.Key())
+		value, err := iter.Value()
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GenerateTestKey(i), value)
+		i++
+	}
+	assert.Equal(t, 100, i)
+
+	iter.Seek(utils.GenerateTestKey(90))
+	assert.True(t, iter.Valid())
+	assert.Equal(t, utils.GenerateTestKey(90), iter.Key())
+	iter.Close()
+
+	// reverse with limit
+	config := DefaultIteratorConfig
+	config.Reverse = true
+	config.Limit = 5
+	iter = sdb.NewIterator(config)
+	var keys [][]byte
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	assert.Equal(t, [][]byte{
+		utils.GenerateTestKey(99), utils.GenerateTestKey(98), utils.GenerateTestKey(97),
+		utils.GenerateTestKey(96), utils.GenerateTestKey(95),
+	}, keys)
+
+	// range bounds are applied to each shard
+	config = DefaultIteratorConfig
+	config.Start = utils.GenerateTestKey(10)
+	config.End = utils.GenerateTestKey(20)
+	iter = sdb.NewIterator(config)
+	i = 10
+	for ; iter.Valid(); iter.Next() {
+		assert.Equal(t, utils.GenerateTestKey(i), iter.Key())
+		i++
+	}
+	iter.Close()
+	assert.Equal(t, 20, i)
+}
+
+func TestShardedDB_WriteBatch(t *testing.T) {
+	sdb, _ := openShardedTestDatabase(t, 4)
+	defer destroyShardedDatabase(sdb)
+
+	assert.Nil(t, sdb.Put(utils.GenerateTestKey(0), utils.GenerateTestKey(0)))
+
+	batch := sdb.NewWriteBatch(DefaultWriteBatchConfig)
+	for i := 1; i < 100; i++ {
+		assert.Nil(t, batch.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, batch.Delete(utils.GenerateTestKey(0)))
+	assert.Equal(t, ErrKeyIsEmpty, batch.Put(nil, nil))
+
+	// not visible before commit
+	_, err := sdb.Get(utils.GenerateTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	assert.Nil(t, batch.Commit())
+	_, err = sdb.Get(utils.GenerateTestKey(0))
+	assert.Equal(t, ErrKeyNotFound, err)
+	for i := 1; i < 100; i++ {
+		value, err := sdb.Get(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GenerateTestKey(i), value)
+	}
+}
+
+func TestShardedDB_Merge(t *testing.T) {
+	sdb, configs := openShardedTestDatabase(t, 2)
+	defer destroyShardedDatabase(sdb)
+
+	for i := 0; i < 2000; i++ {
+		assert.Nil(t, sdb.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+	for i := 0; i < 2000; i++ {
+		assert.Nil(t, sdb.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, sdb.Merge())
+
+	assert.Nil(t, sdb.Close())
+	sdb, err := OpenShardedDatabase(configs, 2)
+	assert.Nil(t, err)
+	stats, err := sdb.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2000), stats.KeyNum)
+	assert.Equal(t, int64(0), stats.ReclaimableSizeInBytes)
+	for i := 0; i < 2000; i++ {
+		value, err := sdb.Get(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GenerateTestKey(i), value)
+	}
+
+	// merge ratio of each shard
+	sdb.shards[0].config.MergeRatio = 1
+	sdb.shards[1].config.MergeRatio = 1
+	assert.Equal(t, ErrMergeRatioNotSatisfied, sdb.Merge())
+}
+
+func TestShardedDB_FileSystem(t *testing.T) {
+	configs := DefaultConfig
+	configs.DirPath = "/memory/bitcask_test_sharded"
+	configs.InMemory = true
+	configs.FileSystem = fio.NewMemoryFileSystem()
+
+	sdb, err := OpenShardedDatabase(configs, 4)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, sdb.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, sdb.Close())
+
+	// shards are kept in the given file system
+	entries, err := configs.FileSystem.ReadDir(configs.DirPath)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(entries))
+	_, err = OpenShardedDatabase(configs, 2)
+	assert.Equal(t, ErrShardNumMismatch, err)
+
+	sdb, err = OpenShardedDatabase(configs, 4)
+	assert.Nil(t, err)
+	defer sdb.Close()
+	value, err := sdb.Get(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GenerateTestKey(1), value)
+}
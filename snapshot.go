@@ -0,0 +1,229 @@
+package bitcask_go
+
+import (
+	"bitcask-go/fio"
+	"bitcask-go/index"
+	"bitcask-go/storage"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+const indexSnapshotTmpSuffix = ".tmp"
+
+// flush the encoded snapshot records to disk every 4MB instead of once per key
+const indexSnapshotBufferSize = 4 * 1024 * 1024
+
+// writeIndexSnapshot dump the whole in-memory index into snapshot file on clean close,
+// the first record holds the high-water mark (active file id and write offset), sequence number, reclaim size
+// and usage of data files, the following records are the same as hint file, key -> encoded log record position
+func (db *DB) writeIndexSnapshot() error {
+	if !supportIndexSnapshot(db.config.IndexerType) || db.activeFile == nil {
+		return nil
+	}
+
+	// write to a temp file first and rename it, so a crash while writing never leaves a partial snapshot
+	fileName := storage.GetIndexSnapshotFileName(db.config.DirPath)
+	tmpFileName := fileName + indexSnapshotTmpSuffix
+	if err := db.fs.RemoveAll(tmpFileName); err != nil {
+		return err
+	}
+
+	snapshotFile, err := storage.OpenIndexSnapshotFile(db.fs, tmpFileName, db.fileIOType())
+	if err != nil {
+		return err
+	}
+
+	headerRecord, _ := storage.EncodeLogRecord(&storage.LogRecord{
+		Key:            indexSnapshotKey,
+		Value:          encodeIndexSnapshotHeader(db.activeFile.FileId, db.activeFile.WriteOffset, db.reclaimSize.Load(), db.stats.fileUsages()),
+		Type:           storage.LogRecordNormal,
+		SequenceNumber: db.sequenceNumber,
+	})
+
+	buf := make([]byte, 0, indexSnapshotBufferSize)
+	buf = append(buf, headerRecord...)
+
+	iter, err := index.CheckedIterator(db.index, false)
+	if err != nil {
+		_ = snapshotFile.Close()
+		return err
+	}
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		buf = append(buf, getEncodeLogRecordForPosition(iter.Key(), iter.Value())...)
+		if len(buf) >= indexSnapshotBufferSize {
+			if err := snapshotFile.Write(buf); err != nil {
+				iter.Close()
+				_ = snapshotFile.Close()
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	iter.Close()
+
+	if err := snapshotFile.Write(buf); err != nil {
+		_ = snapshotFile.Close()
+		return err
+	}
+	if err := snapshotFile.Sync(); err != nil {
+		_ = snapshotFile.Close()
+		return err
+	}
+	if err := snapshotFile.Close(); err != nil {
+		return err
+	}
+
+	return db.fs.Rename(tmpFileName, fileName)
+}
+
+// loadIndexSnapshot load index from snapshot file, return false if snapshot is missing or stale,
+// then we should fall back to load the index from hint file and data files.
+// The snapshot is removed after loading, so it will never be reused if the process crashes later
+func (db *DB) loadIndexSnapshot(dataFiles map[uint32]*storage.DataFile) (bool, error) {
+	if !supportIndexSnapshot(db.config.IndexerType) {
+		return false, nil
+	}
+
+	fileName := storage.GetIndexSnapshotFileName(db.config.DirPath)
+	if _, err := db.fs.Stat(fileName); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer func() {
+		_ = db.fs.Remove(fileName)
+	}()
+
+	ioType := fio.MMapIOType
+	if !db.config.EnableMMapAtStart {
+		ioType = fio.StandardFileIOType
+	}
+	if db.config.InMemory {
+		ioType = fio.MemoryIOType
+	}
+	snapshotFile, err := storage.OpenIndexSnapshotFile(db.fs, fileName, ioType)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = snapshotFile.Close()
+	}()
+
+	headerRecord, offset, err := snapshotFile.ReadLogRecord(0)
+	if err != nil || !bytes.Equal(headerRecord.Key, indexSnapshotKey) {
+		return false, nil
+	}
+	// snapshot written by earlier version has no usage of data files, it's replayed as well
+	snapshotPos, reclaimSize, fileUsages, ok := decodeIndexSnapshotHeader(headerRecord.Value)
+	if !ok || !db.isIndexSnapshotValid(dataFiles, snapshotPos) {
+		return false, nil
+	}
+
+	for {
+		logRecord, size, err := snapshotFile.ReadLogRecord(offset)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			// snapshot is corrupted, drop what we have loaded and replay all the data files
+			db.index = db.newIndexer()
+			return false, nil
+		}
+
+		logRecordPos, _ := storage.DecodeLogRecordPosition(logRecord.Value)
+		if _, err := index.CheckedPut(db.index, logRecord.Key, logRecordPos); err != nil {
+			return false, err
+		}
+		offset += size
+	}
+
+	db.indexSnapshotPos = snapshotPos
+	db.sequenceNumber = headerRecord.SequenceNumber
+	db.reclaimSize.Store(reclaimSize)
+	db.stats.setFileUsages(fileUsages)
+
+	return true, nil
+}
+
+// isIndexSnapshotValid the data file of high-water mark should still exist and not be truncated
+func (db *DB) isIndexSnapshotValid(dataFiles map[uint32]*storage.DataFile, snapshotPos *storage.LogRecordPos) bool {
+	dataFile, ok := dataFiles[snapshotPos.Fid]
+	if !ok {
+		return false
+	}
+
+	release, err := db.openDataFile(dataFile)
+	if err != nil {
+		return false
+	}
+	defer release()
+
+	size, err := dataFile.IOManager.Size()
+	if err != nil {
+		return false
+	}
+	return size >= snapshotPos.Offset
+}
+
+func supportIndexSnapshot(typ index.IndexerType) bool {
+	return typ != index.BPlusTreeIndexType
+}
+
+// fid + offset + reclaim size + number of data files + (fid + bytes + records + dead bytes + dead records) of each data file
+func encodeIndexSnapshotHeader(fid uint32, offset int64, reclaimSize int64, fileUsages map[uint32]fileUsage) []byte {
+	buf := make([]byte, 0, binary.MaxVarintLen64*(4+len(fileUsages)*5))
+	buf = binary.AppendVarint(buf, int64(fid))
+	buf = binary.AppendVarint(buf, offset)
+	buf = binary.AppendVarint(buf, reclaimSize)
+	buf = binary.AppendVarint(buf, int64(len(fileUsages)))
+	for fileId, usage := range fileUsages {
+		buf = binary.AppendVarint(buf, int64(fileId))
+		buf = binary.AppendVarint(buf, usage.bytes)
+		buf = binary.AppendVarint(buf, usage.records)
+		buf = binary.AppendVarint(buf, usage.deadBytes)
+		buf = binary.AppendVarint(buf, usage.deadRecords)
+	}
+	return buf
+}
+
+// decodeIndexSnapshotHeader return false if header is incomplete
+func decodeIndexSnapshotHeader(buf []byte) (*storage.LogRecordPos, int64, map[uint32]fileUsage, bool) {
+	var index = 0
+	var ok = true
+	readVarint := func() int64 {
+		v, n := binary.Varint(buf[index:])
+		if n <= 0 {
+			ok = false
+			return 0
+		}
+		index += n
+		return v
+	}
+
+	fid := readVarint()
+	offset := readVarint()
+	reclaimSize := readVarint()
+	fileNum := readVarint()
+	if !ok || fileNum < 0 || fileNum > int64(len(buf)) {
+		return nil, 0, nil, false
+	}
+
+	fileUsages := make(map[uint32]fileUsage, fileNum)
+	for i := int64(0); i < fileNum && ok; i++ {
+		fileId := uint32(readVarint())
+		fileUsages[fileId] = fileUsage{
+			bytes:       readVarint(),
+			records:     readVarint(),
+			deadBytes:   readVarint(),
+			deadRecords: readVarint(),
+		}
+	}
+	if !ok {
+		return nil, 0, nil, false
+	}
+
+	return &storage.LogRecordPos{Fid: uint32(fid), Offset: offset}, reclaimSize, fileUsages, true
+}
@@ -0,0 +1,184 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/storage"
+	"bitcask-go/utils"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDB_IndexSnapshot_LoadAfterClose(t *testing.T) {
+	for _, indexType := range []index.IndexerType{index.BTreeIndexType, index.ARTIndexType, index.ShardedHashIndexType} {
+		configs := DefaultConfig
+		dir, _ := os.MkdirTemp("", "bitcask_test_snapshot")
+		configs.DirPath = dir
+		configs.IndexerType = indexType
+
+		database, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+		assert.NotNil(t, database)
+
+		n := 100
+		for i := 0; i < n; i++ {
+			err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+			assert.Nil(t, err)
+		}
+		for i := 0; i < 10; i++ {
+			err = database.Delete(utils.GenerateTestKey(i))
+			assert.Nil(t, err)
+		}
+		statsBefore, err := database.Stats()
+		assert.Nil(t, err)
+
+		err = database.Close()
+		assert.Nil(t, err)
+		_, err = os.Stat(storage.GetIndexSnapshotFileName(dir))
+		assert.Nil(t, err)
+
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+		assert.NotNil(t, database.indexSnapshotPos)
+		// snapshot is consumed while opening
+		_, err = os.Stat(storage.GetIndexSnapshotFileName(dir))
+		assert.True(t, os.IsNotExist(err))
+
+		statsAfter, err := database.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, statsBefore.KeyNum, statsAfter.KeyNum)
+		assert.Equal(t, statsBefore.ReclaimableSizeInBytes, statsAfter.ReclaimableSizeInBytes)
+
+		for i := 0; i < n; i++ {
+			val, err := database.Get(utils.GenerateTestKey(i))
+			if i < 10 {
+				assert.Equal(t, ErrKeyNotFound, err)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, utils.GenerateTestKey(i), val)
+			}
+		}
+
+		// write after the snapshot, and crash without close
+		err = database.Put(utils.GenerateTestKey(n), utils.GenerateTestKey(n))
+		assert.Nil(t, err)
+		err = database.Delete(utils.GenerateTestKey(n - 1))
+		assert.Nil(t, err)
+		err = database.fileLock.Unlock()
+		assert.Nil(t, err)
+
+		database2, err := OpenDatabase(configs)
+		assert.Nil(t, err)
+		assert.Nil(t, database2.indexSnapshotPos)
+		assert.Equal(t, n-10, len(database2.ListKeys()))
+		_, err = database2.Get(utils.GenerateTestKey(n - 1))
+		assert.Equal(t, ErrKeyNotFound, err)
+		val, err := database2.Get(utils.GenerateTestKey(n))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GenerateTestKey(n), val)
+
+		destroyDatabase(database2)
+	}
+}
+
+func TestDB_IndexSnapshot_ReplayRecordsAfterSnapshot(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_snapshot")
+	configs.DirPath = dir
+	configs.DataFileSize = 32 * 1024
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+	for i := 0; i < 500; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64))
+		assert.Nil(t, err)
+	}
+	err = database.Close()
+	assert.Nil(t, err)
+
+	// keep the snapshot file, and append more records after it
+	snapshotBuf, err := os.ReadFile(storage.GetIndexSnapshotFileName(dir))
+	assert.Nil(t, err)
+
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	for i := 500; i < 1000; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64))
+		assert.Nil(t, err)
+	}
+	err = database.Delete(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	err = database.Close()
+	assert.Nil(t, err)
+
+	err = os.WriteFile(storage.GetIndexSnapshotFileName(dir), snapshotBuf, os.ModePerm)
+	assert.Nil(t, err)
+
+	database, err = OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+	assert.NotNil(t, database.indexSnapshotPos)
+	assert.Equal(t, 999, len(database.ListKeys()))
+	_, err = database.Get(utils.GenerateTestKey(1))
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = database.Get(utils.GenerateTestKey(999))
+	assert.Nil(t, err)
+}
+
+func TestDB_IndexSnapshot_StaleSnapshot(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_snapshot")
+	configs.DirPath = dir
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = database.Close()
+	assert.Nil(t, err)
+
+	// truncate data file, high-water mark is beyond the end of file
+	err = os.Truncate(storage.GetDataFileName(dir, initialDataFileId), 0)
+	assert.Nil(t, err)
+
+	database, err = OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+	assert.Nil(t, database.indexSnapshotPos)
+	assert.Equal(t, 0, len(database.ListKeys()))
+}
+
+func TestDB_IndexSnapshot_AfterMerge(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_snapshot")
+	configs.DirPath = dir
+	configs.MergeRatio = 0
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	for i := 0; i < 50; i++ {
+		err = database.Delete(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	err = database.Merge()
+	assert.Nil(t, err)
+	err = database.Close()
+	assert.Nil(t, err)
+
+	database, err = OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+	assert.Nil(t, database.indexSnapshotPos)
+	assert.Equal(t, 50, len(database.ListKeys()))
+	for i := 50; i < 100; i++ {
+		val, err := database.Get(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+		assert.Equal(t, utils.GenerateTestKey(i), val)
+	}
+}
@@ -0,0 +1,167 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/storage"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DataFileStats space usage of a data file, dead records are overwritten or deleted records, tombstones
+// and transaction finish records, which are reclaimed by merging
+type DataFileStats struct {
+	FileId      uint32 `json:"fileId"`
+	LiveBytes   int64  `json:"liveBytes"`
+	DeadBytes   int64  `json:"deadBytes"`
+	LiveRecords int64  `json:"liveRecords"`
+	DeadRecords int64  `json:"deadRecords"`
+}
+
+// fileUsage bytes and records of a data file, dead ones included
+type fileUsage struct {
+	bytes       int64
+	records     int64
+	deadBytes   int64
+	deadRecords int64
+}
+
+// statsCollector counters of database maintained as operations are done, so Stats is cheap to call frequently
+type statsCollector struct {
+	puts          atomic.Uint64
+	gets          atomic.Uint64
+	deletes       atomic.Uint64
+	batchCommits  atomic.Uint64
+	merges        atomic.Uint64
+	syncs         atomic.Uint64
+	bytesRead     atomic.Uint64
+	bytesWritten  atomic.Uint64
+	lastMergeTime atomic.Int64 // unix nano, 0 if never merged
+
+	mu    *sync.Mutex
+	files map[uint32]*fileUsage // <fid, usage>
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{
+		mu:    new(sync.Mutex),
+		files: make(map[uint32]*fileUsage),
+	}
+}
+
+// addRecord count record of size appended to or loaded from data file fid
+func (s *statsCollector) addRecord(fid uint32, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage := s.fileUsage(fid)
+	usage.bytes += size
+	usage.records++
+}
+
+// addDeadRecord count record of size in data file fid as dead
+func (s *statsCollector) addDeadRecord(fid uint32, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usage := s.fileUsage(fid)
+	usage.deadBytes += size
+	usage.deadRecords++
+}
+
+// fileUsage usage of data file fid, mu must be held
+func (s *statsCollector) fileUsage(fid uint32) *fileUsage {
+	usage, ok := s.files[fid]
+	if !ok {
+		usage = new(fileUsage)
+		s.files[fid] = usage
+	}
+	return usage
+}
+
+// fileUsages copy usage of all the data files
+func (s *statsCollector) fileUsages() map[uint32]fileUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	usages := make(map[uint32]fileUsage, len(s.files))
+	for fid, usage := range s.files {
+		usages[fid] = *usage
+	}
+	return usages
+}
+
+// setFileUsages replace usage of all the data files, it's used by loading index snapshot and resetting replica
+func (s *statsCollector) setFileUsages(usages map[uint32]fileUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files = make(map[uint32]*fileUsage, len(usages))
+	for fid, usage := range usages {
+		s.files[fid] = &usage
+	}
+}
+
+// dataFileStats stats of each data file ordered by file id, and total bytes of data files
+func (s *statsCollector) dataFileStats() ([]DataFileStats, int64) {
+	s.mu.Lock()
+	stats := make([]DataFileStats, 0, len(s.files))
+	var totalBytes int64
+	for fid, usage := range s.files {
+		stats = append(stats, DataFileStats{
+			FileId:      fid,
+			LiveBytes:   usage.bytes - usage.deadBytes,
+			DeadBytes:   usage.deadBytes,
+			LiveRecords: usage.records - usage.deadRecords,
+			DeadRecords: usage.deadRecords,
+		})
+		totalBytes += usage.bytes
+	}
+	s.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].FileId < stats[j].FileId
+	})
+	return stats, totalBytes
+}
+
+// lastMerge time of last merge finished, zero if database is never merged
+func (s *statsCollector) lastMerge() time.Time {
+	nanos := s.lastMergeTime.Load()
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// reclaim count the record at pos as reclaimable by merging
+func (db *DB) reclaim(pos *storage.LogRecordPos) {
+	db.reclaimSize.Add(int64(pos.LogRecordSize))
+	db.stats.addDeadRecord(pos.Fid, int64(pos.LogRecordSize))
+}
+
+// auxiliaryFileSize size of the files besides data files, there are a few of them so they're checked on each call
+func (db *DB) auxiliaryFileSize() int64 {
+	fileNames := []string{storage.HintFileName, storage.MergeFinishFileName, storage.SequenceNumberFileName}
+	if db.config.IndexerType == index.BPlusTreeIndexType {
+		fileNames = append(fileNames, index.BPlusTreeFileName)
+	}
+
+	var size int64
+	for _, fileName := range fileNames {
+		if info, err := db.fs.Stat(filepath.Join(db.config.DirPath, fileName)); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// loadLastMergeTime the merge finish file is moved into data directory once merge is applied
+func (db *DB) loadLastMergeTime() {
+	info, err := db.fs.Stat(filepath.Join(db.config.DirPath, storage.MergeFinishFileName))
+	if err == nil && !info.ModTime().IsZero() {
+		db.stats.lastMergeTime.Store(info.ModTime().UnixNano())
+	}
+}
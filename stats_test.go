@@ -0,0 +1,212 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/storage"
+	"bitcask-go/utils"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertDataFileStats live records are the keys in index, dead bytes are reclaimable
+func assertDataFileStats(t *testing.T, stats Stats) {
+	var liveRecords, deadBytes int64
+	for _, file := range stats.DataFiles {
+		liveRecords += file.LiveRecords
+		deadBytes += file.DeadBytes
+	}
+	assert.Equal(t, int64(stats.KeyNum), liveRecords)
+	assert.Equal(t, stats.ReclaimableSizeInBytes, deadBytes)
+}
+
+func TestDB_Stats_Counters(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_stats")
+	configs.DirPath = dir
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, database.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64)))
+	}
+	// overwrite
+	assert.Nil(t, database.Put(utils.GenerateTestKey(0), utils.GenerateRandomValue(64)))
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, database.Delete(utils.GenerateTestKey(i)))
+	}
+	for i := 3; i < 5; i++ {
+		_, err := database.Get(utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	_, err = database.Get(utils.GenerateTestKey(0))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	batch := database.NewWriteBatch(DefaultWriteBatchConfig)
+	assert.Nil(t, batch.Put(utils.GenerateTestKey(3), utils.GenerateRandomValue(64)))
+	assert.Nil(t, batch.Delete(utils.GenerateTestKey(4)))
+	assert.Nil(t, batch.Commit())
+	assert.Nil(t, database.Sync())
+
+	stats, err := database.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(11), stats.PutCount)
+	assert.Equal(t, uint64(3), stats.DeleteCount)
+	assert.Equal(t, uint64(2), stats.GetCount)
+	assert.Equal(t, uint64(1), stats.BatchCommitCount)
+	// batch is synced by default
+	assert.Equal(t, uint64(2), stats.SyncCount)
+	assert.Equal(t, uint64(0), stats.MergeCount)
+	assert.True(t, stats.LastMergeTime.IsZero())
+	assert.Greater(t, stats.BytesRead, uint64(2*64))
+	assert.Equal(t, uint(6), stats.KeyNum)
+
+	assert.Equal(t, 1, len(stats.DataFiles))
+	file := stats.DataFiles[0]
+	// 11 puts, 3 deletes, 2 records and finish record of batch
+	assert.Equal(t, int64(17), file.LiveRecords+file.DeadRecords)
+	assert.Equal(t, int64(6), file.LiveRecords)
+	assert.Equal(t, stats.TotalFileSizeInBytes, file.LiveBytes+file.DeadBytes)
+	assert.Equal(t, uint64(stats.TotalFileSizeInBytes), stats.BytesWritten)
+	assertDataFileStats(t, stats)
+}
+
+func TestDB_Stats_DataFilesAfterReopen(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_stats")
+	configs.DirPath = dir
+	configs.DataFileSize = 16 * 1024
+	configs.MergeRatio = 0
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, database.Put(utils.GenerateTestKey(i%300), utils.GenerateRandomValue(64)))
+	}
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, database.Delete(utils.GenerateTestKey(i)))
+	}
+	batch := database.NewWriteBatch(DefaultWriteBatchConfig)
+	for i := 100; i < 120; i++ {
+		assert.Nil(t, batch.Delete(utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, batch.Commit())
+	assert.Nil(t, database.DeletePrefix([]byte("bitcask-key-00000029")))
+
+	stats, err := database.Stats()
+	assert.Nil(t, err)
+	assert.Greater(t, len(stats.DataFiles), 1)
+	assertDataFileStats(t, stats)
+
+	// index snapshot
+	assert.Nil(t, database.Close())
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	assert.NotNil(t, database.indexSnapshotPos)
+	reopened, err := database.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, stats.DataFiles, reopened.DataFiles)
+	assert.Equal(t, stats.TotalFileSizeInBytes, reopened.TotalFileSizeInBytes)
+
+	// replay all the data files
+	assert.Nil(t, database.Close())
+	assert.Nil(t, os.Remove(storage.GetIndexSnapshotFileName(dir)))
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	assert.Nil(t, database.indexSnapshotPos)
+	reopened, err = database.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, stats.DataFiles, reopened.DataFiles)
+
+	// merged data files are loaded from hint file
+	assert.Nil(t, database.Merge())
+	merged, err := database.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), merged.MergeCount)
+	assert.False(t, merged.LastMergeTime.IsZero())
+
+	assert.Nil(t, database.Close())
+	assert.Nil(t, os.Remove(storage.GetIndexSnapshotFileName(dir)))
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+	defer destroyDatabase(database)
+
+	reopened, err = database.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, stats.KeyNum, reopened.KeyNum)
+	assert.False(t, reopened.LastMergeTime.IsZero())
+	assert.Less(t, reopened.TotalFileSizeInBytes, stats.TotalFileSizeInBytes)
+	for _, file := range reopened.DataFiles {
+		assert.Equal(t, int64(0), file.DeadBytes)
+		assert.Equal(t, int64(0), file.DeadRecords)
+	}
+	assertDataFileStats(t, reopened)
+}
+
+func TestDB_Stats_BPlusTreeReopen(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_stats")
+	configs.DirPath = dir
+	configs.DataFileSize = 16 * 1024
+	configs.IndexerType = index.BPlusTreeIndexType
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	for i := 0; i < 1000; i++ {
+		assert.Nil(t, database.Put(utils.GenerateTestKey(i%300), utils.GenerateRandomValue(64)))
+	}
+	for i := 0; i < 100; i++ {
+		assert.Nil(t, database.Delete(utils.GenerateTestKey(i)))
+	}
+	batch := database.NewWriteBatch(DefaultWriteBatchConfig)
+	for i := 100; i < 120; i++ {
+		assert.Nil(t, batch.Delete(utils.GenerateTestKey(i)))
+	}
+	assert.Nil(t, batch.Put(utils.GenerateTestKey(0), utils.GenerateRandomValue(64)))
+	assert.Nil(t, batch.Commit())
+	assert.Nil(t, database.DeletePrefix([]byte("bitcask-key-00000029")))
+	// key in range written only after range tombstone
+	assert.Nil(t, database.Put([]byte("bitcask-key-00000029-new"), utils.GenerateRandomValue(64)))
+
+	stats, err := database.Stats()
+	assert.Nil(t, err)
+	assert.Greater(t, len(stats.DataFiles), 1)
+	assertDataFileStats(t, stats)
+
+	// data files are replayed on the persisted index, records are not counted dead twice
+	for i := 0; i < 2; i++ {
+		assert.Nil(t, database.Close())
+		database, err = OpenDatabase(configs)
+		assert.Nil(t, err)
+		reopened, err := database.Stats()
+		assert.Nil(t, err)
+		assert.Equal(t, stats.DataFiles, reopened.DataFiles)
+		assert.Equal(t, stats.ReclaimableSizeInBytes, reopened.ReclaimableSizeInBytes)
+		assertDataFileStats(t, reopened)
+	}
+
+	destroyDatabase(database)
+}
+
+func TestIndexSnapshotHeader(t *testing.T) {
+	fileUsages := map[uint32]fileUsage{
+		1: {bytes: 1024, records: 10, deadBytes: 512, deadRecords: 5},
+		2: {bytes: 100, records: 1},
+	}
+	buf := encodeIndexSnapshotHeader(2, 100, 512, fileUsages)
+	pos, reclaimSize, decoded, ok := decodeIndexSnapshotHeader(buf)
+	assert.True(t, ok)
+	assert.Equal(t, &storage.LogRecordPos{Fid: 2, Offset: 100}, pos)
+	assert.Equal(t, int64(512), reclaimSize)
+	assert.Equal(t, fileUsages, decoded)
+
+	// header without usage of data files
+	_, _, _, ok = decodeIndexSnapshotHeader(buf[:3])
+	assert.False(t, ok)
+	_, _, _, ok = decodeIndexSnapshotHeader(buf[:len(buf)-1])
+	assert.False(t, ok)
+}
@@ -4,6 +4,7 @@ import (
 	"bitcask-go/fio"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"path/filepath"
 )
@@ -17,6 +18,7 @@ const (
 	HintFileName           = "hint-index"
 	MergeFinishFileName    = "merge-finish"
 	SequenceNumberFileName = "sequence-number"
+	IndexSnapshotFileName  = "index-snapshot"
 )
 
 type DataFile struct {
@@ -25,24 +27,28 @@ type DataFile struct {
 	IOManager   fio.IOManager
 }
 
-func OpenDataFile(dirPath string, fileId uint32, ioType fio.IOType) (*DataFile, error) {
+func OpenDataFile(fs fio.FileSystem, dirPath string, fileId uint32, ioType fio.IOType) (*DataFile, error) {
 	fileName := GetDataFileName(dirPath, fileId)
-	return newDataFile(fileName, fileId, ioType)
+	return newDataFile(fs, fileName, fileId, ioType)
 }
 
-func OpenHintFile(dirPath string) (*DataFile, error) {
+func OpenHintFile(fs fio.FileSystem, dirPath string, ioType fio.IOType) (*DataFile, error) {
 	fileName := GetHintFileName(dirPath)
-	return newDataFile(fileName, 0, fio.StandardFileIOType)
+	return newDataFile(fs, fileName, 0, ioType)
 }
 
-func OpenMergeFinishFile(dirPath string) (*DataFile, error) {
+func OpenMergeFinishFile(fs fio.FileSystem, dirPath string, ioType fio.IOType) (*DataFile, error) {
 	fileName := filepath.Join(dirPath, MergeFinishFileName)
-	return newDataFile(fileName, 0, fio.StandardFileIOType)
+	return newDataFile(fs, fileName, 0, ioType)
 }
 
-func OpenSequenceNumberFile(dirPath string) (*DataFile, error) {
+func OpenSequenceNumberFile(fs fio.FileSystem, dirPath string, ioType fio.IOType) (*DataFile, error) {
 	fileName := filepath.Join(dirPath, SequenceNumberFileName)
-	return newDataFile(fileName, 0, fio.StandardFileIOType)
+	return newDataFile(fs, fileName, 0, ioType)
+}
+
+func OpenIndexSnapshotFile(fs fio.FileSystem, fileName string, ioType fio.IOType) (*DataFile, error) {
+	return newDataFile(fs, fileName, 0, ioType)
 }
 
 // ReadLogRecord read log record from read offset
@@ -95,6 +101,103 @@ func (df *DataFile) ReadLogRecord(offset int64) (*LogRecord, int64, error) {
 	return logRecord, headerSize + keySize + valueSize, nil
 }
 
+// ReadBytes read n bytes of raw log records from offset
+func (df *DataFile) ReadBytes(n int64, offset int64) ([]byte, error) {
+	return df.readNBytes(n, offset)
+}
+
+// ViewBytes n bytes from offset without copying if data file is memory mapped, otherwise bytes are read into a new buffer,
+// bytes are valid until release is called even if data file is closed
+func (df *DataFile) ViewBytes(n int64, offset int64) ([]byte, func(), error) {
+	if viewer, ok := df.IOManager.(fio.Viewer); ok {
+		return viewer.View(int(n), offset)
+	}
+	buf, err := df.readNBytes(n, offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf, func() {}, nil
+}
+
+// LogRecordValueReader stream value of log record from data file, crc is verified once the whole value is read
+type LogRecordValueReader struct {
+	Key       []byte
+	Type      LogRecordType
+	ValueSize int64
+
+	dataFile    *DataFile
+	offset      int64 // offset of next read
+	remain      int64 // bytes of value not read yet
+	crc         uint32
+	expectedCRC uint32
+}
+
+// OpenLogRecordValueReader read header and key of log record at offset, value is read by the returned reader
+func (df *DataFile) OpenLogRecordValueReader(offset int64) (*LogRecordValueReader, error) {
+	fileSize, err := df.IOManager.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	headerBuf, err := df.readNBytes(min(maxLogRecordHeaderSize, fileSize-offset), offset)
+	if err != nil {
+		return nil, err
+	}
+
+	header, headerSize := decodeLogRecordHeader(headerBuf)
+	if header == nil {
+		return nil, io.EOF
+	}
+
+	key, err := df.readNBytes(int64(header.keySize), offset+headerSize)
+	if err != nil {
+		return nil, err
+	}
+
+	crc := crc32.ChecksumIEEE(headerBuf[crcSizeInByte:headerSize])
+	crc = crc32.Update(crc, crc32.IEEETable, key)
+
+	return &LogRecordValueReader{
+		Key:         key,
+		Type:        header.recordType,
+		ValueSize:   int64(header.valueSize),
+		dataFile:    df,
+		offset:      offset + headerSize + int64(header.keySize),
+		remain:      int64(header.valueSize),
+		crc:         crc,
+		expectedCRC: header.crc,
+	}, nil
+}
+
+// Read value bytes, return ErrInvalidCRC instead of io.EOF if value is corrupted
+func (r *LogRecordValueReader) Read(p []byte) (int, error) {
+	if r.remain == 0 {
+		if r.crc != r.expectedCRC {
+			return 0, ErrInvalidCRC
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > r.remain {
+		p = p[:r.remain]
+	}
+	n, err := r.dataFile.IOManager.Read(p, r.offset)
+	r.crc = crc32.Update(r.crc, crc32.IEEETable, p[:n])
+	r.offset += int64(n)
+	r.remain -= int64(n)
+
+	if err == io.EOF && r.remain > 0 {
+		return n, io.ErrUnexpectedEOF
+	}
+	if r.remain == 0 && r.crc != r.expectedCRC {
+		return n, ErrInvalidCRC
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
 func (df *DataFile) Write(buf []byte) error {
 	n, err := df.IOManager.Write(buf)
 	if err != nil {
@@ -108,17 +211,26 @@ func (df *DataFile) Sync() error {
 	return df.IOManager.Sync()
 }
 
+// Truncate data file to size, records after size are dropped
+func (df *DataFile) Truncate(size int64) error {
+	if err := df.IOManager.Truncate(size); err != nil {
+		return err
+	}
+	df.WriteOffset = size
+	return nil
+}
+
 func (df *DataFile) Close() error {
 	return df.IOManager.Close()
 }
 
-func (df *DataFile) SetIOType(dirPath string, ioType fio.IOType) error {
+func (df *DataFile) SetIOType(fs fio.FileSystem, dirPath string, ioType fio.IOType) error {
 	if err := df.IOManager.Close(); err != nil {
 		return err
 	}
 
 	fileName := GetDataFileName(dirPath, df.FileId)
-	IOManager, err := fio.NewIOManager(fileName, ioType)
+	IOManager, err := fs.OpenFile(fileName, ioType)
 	if err != nil {
 		return err
 	}
@@ -144,9 +256,13 @@ func GetHintFileName(dirPath string) string {
 	return filepath.Join(dirPath, HintFileName)
 }
 
-func newDataFile(fileName string, fileId uint32, ioType fio.IOType) (*DataFile, error) {
+func GetIndexSnapshotFileName(dirPath string) string {
+	return filepath.Join(dirPath, IndexSnapshotFileName)
+}
+
+func newDataFile(fs fio.FileSystem, fileName string, fileId uint32, ioType fio.IOType) (*DataFile, error) {
 	// Construct IO Manager
-	ioManager, err := fio.NewIOManager(fileName, ioType)
+	ioManager, err := fs.OpenFile(fileName, ioType)
 	if err != nil {
 		return nil, err
 	}
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"bitcask-go/fio"
+	"bytes"
+	"encoding/binary"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"os"
 	"testing"
 )
@@ -16,7 +19,7 @@ func destroyDataFile(dirPath string) {
 
 func TestOpenDataFile(t *testing.T) {
 	dir, _ := os.MkdirTemp("", "datafile")
-	dataFile, err := OpenDataFile(dir, 1, fio.StandardFileIOType)
+	dataFile, err := OpenDataFile(fio.NewFileSystem(fio.StandardFileIOType), dir, 1, fio.StandardFileIOType)
 	defer destroyDataFile(dir)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
@@ -24,7 +27,7 @@ func TestOpenDataFile(t *testing.T) {
 
 func TestDataFile_Close(t *testing.T) {
 	dir, _ := os.MkdirTemp("", "datafile")
-	dataFile, err := OpenDataFile(dir, 1, fio.StandardFileIOType)
+	dataFile, err := OpenDataFile(fio.NewFileSystem(fio.StandardFileIOType), dir, 1, fio.StandardFileIOType)
 	defer destroyDataFile(dir)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
@@ -35,7 +38,7 @@ func TestDataFile_Close(t *testing.T) {
 
 func TestDataFile_Write(t *testing.T) {
 	dir, _ := os.MkdirTemp("", "datafile")
-	dataFile, err := OpenDataFile(dir, 1, fio.StandardFileIOType)
+	dataFile, err := OpenDataFile(fio.NewFileSystem(fio.StandardFileIOType), dir, 1, fio.StandardFileIOType)
 	defer destroyDataFile(dir)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
@@ -46,7 +49,7 @@ func TestDataFile_Write(t *testing.T) {
 
 func TestDataFile_Sync(t *testing.T) {
 	dir, _ := os.MkdirTemp("", "datafile")
-	dataFile, err := OpenDataFile(dir, 1, fio.StandardFileIOType)
+	dataFile, err := OpenDataFile(fio.NewFileSystem(fio.StandardFileIOType), dir, 1, fio.StandardFileIOType)
 	defer destroyDataFile(dir)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
@@ -60,7 +63,7 @@ func TestDataFile_Sync(t *testing.T) {
 
 func TestDataFile_ReadLogRecord(t *testing.T) {
 	dir, _ := os.MkdirTemp("", "datafile")
-	dataFile, err := OpenDataFile(dir, 1, fio.StandardFileIOType)
+	dataFile, err := OpenDataFile(fio.NewFileSystem(fio.StandardFileIOType), dir, 1, fio.StandardFileIOType)
 	defer destroyDataFile(dir)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
@@ -103,7 +106,7 @@ func TestDataFile_ReadLogRecord(t *testing.T) {
 
 func TestDataFile_ReadLogRecord_Deleted(t *testing.T) {
 	dir, _ := os.MkdirTemp("", "datafile")
-	dataFile, err := OpenDataFile(dir, 1, fio.StandardFileIOType)
+	dataFile, err := OpenDataFile(fio.NewFileSystem(fio.StandardFileIOType), dir, 1, fio.StandardFileIOType)
 	defer destroyDataFile(dir)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
@@ -129,7 +132,7 @@ func TestDataFile_ReadLogRecord_Deleted(t *testing.T) {
 
 func TestDataFile_ReadLogRecord_WithSequenceNumber(t *testing.T) {
 	dir, _ := os.MkdirTemp("", "datafile")
-	dataFile, err := OpenDataFile(dir, 1, fio.StandardFileIOType)
+	dataFile, err := OpenDataFile(fio.NewFileSystem(fio.StandardFileIOType), dir, 1, fio.StandardFileIOType)
 	defer destroyDataFile(dir)
 	assert.Nil(t, err)
 	assert.NotNil(t, dataFile)
@@ -152,3 +155,41 @@ func TestDataFile_ReadLogRecord_WithSequenceNumber(t *testing.T) {
 	assert.Equal(t, i, size)
 	assert.Equal(t, logRecord, readLogRecord)
 }
+
+func TestDataFile_OpenLogRecordValueReader(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "datafile")
+	dataFile, err := OpenDataFile(fio.NewFileSystem(fio.StandardFileIOType), dir, 1, fio.StandardFileIOType)
+	defer destroyDataFile(dir)
+	assert.Nil(t, err)
+
+	logRecord := &LogRecord{
+		Key:   []byte("hello"),
+		Value: bytes.Repeat([]byte("world"), 1000),
+		Type:  LogRecordNormal,
+	}
+	recordBytes, size := EncodeLogRecord(logRecord)
+	err = dataFile.Write(recordBytes)
+	assert.Nil(t, err)
+
+	// header written separately matches the encoded record
+	header := EncodeLogRecordHeader(logRecord, int64(len(logRecord.Value)))
+	SetLogRecordCRC(header, binary.LittleEndian.Uint32(recordBytes))
+	assert.Equal(t, recordBytes[:len(header)], header)
+
+	reader, err := dataFile.OpenLogRecordValueReader(0)
+	assert.Nil(t, err)
+	assert.Equal(t, logRecord.Key, reader.Key)
+	assert.Equal(t, int64(len(logRecord.Value)), reader.ValueSize)
+	value, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, logRecord.Value, value)
+
+	// corrupt the last byte of value
+	recordBytes[size-1] ^= 0xff
+	err = dataFile.Write(recordBytes)
+	assert.Nil(t, err)
+	reader, err = dataFile.OpenLogRecordValueReader(size)
+	assert.Nil(t, err)
+	_, err = io.ReadAll(reader)
+	assert.Equal(t, ErrInvalidCRC, err)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrTruncatedLogRecord     = errors.New("log record is truncated")
+	ErrInvalidLogRecordHeader = errors.New("invalid log record header")
+)
+
+// InspectedRecord log record decoded by InspectFile, fields are decoded from header even if crc doesn't match
+type InspectedRecord struct {
+	Offset         int64
+	Size           int64
+	CRCValid       bool
+	Type           LogRecordType
+	SequenceNumber uint64
+	Key            []byte
+	Value          []byte
+	Position       *LogRecordPos // value decoded as position, only for records of hint file
+}
+
+// InspectFile decode data, hint, merge finish or sequence number file record by record, and call fn with each record
+// until fn returns false. Record with invalid crc is reported and inspecting goes on with the next one,
+// ErrTruncatedLogRecord is returned if the last record is incomplete. Zero bytes pre-allocated after the last record are skipped
+func InspectFile(path string, fn func(record *InspectedRecord) bool) error {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	isHintFile := filepath.Base(path) == HintFileName
+
+	var offset int64
+	for offset < int64(len(buf)) {
+		header, headerSize := decodeLogRecordHeader(buf[offset:])
+		if header == nil {
+			return ErrTruncatedLogRecord
+		}
+		if headerSize < invariantSize {
+			return ErrInvalidLogRecordHeader
+		}
+		if header.crc == 0 && header.keySize == 0 && header.valueSize == 0 {
+			return nil
+		}
+
+		keySize, valueSize := int64(header.keySize), int64(header.valueSize)
+		size := headerSize + keySize + valueSize
+		if offset+size > int64(len(buf)) {
+			return ErrTruncatedLogRecord
+		}
+
+		recordBuf := buf[offset : offset+size]
+		record := &InspectedRecord{
+			Offset:         offset,
+			Size:           size,
+			Type:           header.recordType,
+			SequenceNumber: header.sequenceNumber,
+			Key:            recordBuf[headerSize : headerSize+keySize],
+			Value:          recordBuf[headerSize+keySize:],
+		}
+		_, _, err := DecodeLogRecord(recordBuf)
+		record.CRCValid = err == nil
+		if isHintFile {
+			record.Position, _ = DecodeLogRecordPosition(record.Value)
+		}
+
+		if !fn(record) {
+			return nil
+		}
+		offset += size
+	}
+	return nil
+}
+
+// RecordTypeName name of log record type
+func RecordTypeName(typ LogRecordType) string {
+	switch typ {
+	case LogRecordNormal:
+		return "LogRecordNormal"
+	case LogRecordDeleted:
+		return "LogRecordDeleted"
+	case LogRecordTransactionFinished:
+		return "LogRecordTransactionFinished"
+	case LogRecordRangeDeleted:
+		return "LogRecordRangeDeleted"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectFile(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "inspect")
+	defer destroyDataFile(dir)
+
+	records := []*LogRecord{
+		{Key: []byte("key-1"), Value: []byte("value-1"), Type: LogRecordNormal},
+		{Key: []byte("key-2"), Type: LogRecordDeleted, SequenceNumber: 3},
+		{Key: []byte("txn-fin"), Type: LogRecordTransactionFinished, SequenceNumber: 3},
+	}
+	var buf []byte
+	for _, record := range records {
+		encoded, _ := EncodeLogRecord(record)
+		buf = append(buf, encoded...)
+	}
+	// corrupt value of the first record, and truncate the tail
+	first, _ := EncodeLogRecord(records[0])
+	buf[len(first)-1] ^= 0xff
+	last, _ := EncodeLogRecord(records[0])
+	buf = append(buf, last[:len(last)-2]...)
+
+	path := filepath.Join(dir, "000000001.data")
+	assert.Nil(t, os.WriteFile(path, buf, 0644))
+
+	var inspected []*InspectedRecord
+	err := InspectFile(path, func(record *InspectedRecord) bool {
+		inspected = append(inspected, record)
+		return true
+	})
+	assert.Equal(t, ErrTruncatedLogRecord, err)
+	assert.Equal(t, 3, len(inspected))
+
+	assert.False(t, inspected[0].CRCValid)
+	assert.Equal(t, int64(0), inspected[0].Offset)
+	assert.Equal(t, []byte("key-1"), inspected[0].Key)
+	for i, record := range inspected[1:] {
+		assert.True(t, record.CRCValid)
+		assert.Equal(t, records[i+1].Type, record.Type)
+		assert.Equal(t, uint64(3), record.SequenceNumber)
+		assert.Equal(t, records[i+1].Key, record.Key)
+	}
+	assert.Equal(t, inspected[0].Size, inspected[1].Offset)
+	assert.Equal(t, "LogRecordTransactionFinished", RecordTypeName(inspected[2].Type))
+}
+
+func TestInspectFile_HintFile(t *testing.T) {
+	dir, _ := os.MkdirTemp("", "inspect")
+	defer destroyDataFile(dir)
+
+	pos := &LogRecordPos{Fid: 2, Offset: 100, LogRecordSize: 20}
+	encodedPos, _ := EncodeLogRecordPosition(pos)
+	encoded, _ := EncodeLogRecord(&LogRecord{Key: []byte("key"), Value: encodedPos})
+	// pre-allocated zero bytes after the last record
+	buf := append(encoded, make([]byte, 64)...)
+	assert.Nil(t, os.WriteFile(GetHintFileName(dir), buf, 0644))
+
+	var inspected []*InspectedRecord
+	err := InspectFile(GetHintFileName(dir), func(record *InspectedRecord) bool {
+		inspected = append(inspected, record)
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(inspected))
+	assert.Equal(t, pos, inspected[0].Position)
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"encoding/binary"
 	"hash/crc32"
+	"io"
 )
 
 type LogRecordType = byte
@@ -11,6 +12,7 @@ const (
 	LogRecordNormal LogRecordType = iota
 	LogRecordDeleted
 	LogRecordTransactionFinished
+	LogRecordRangeDeleted // range tombstone, key is inclusive start and value is exclusive end of range
 )
 
 const crcSizeInByte = crc32.Size
@@ -82,6 +84,26 @@ func EncodeLogRecord(logRecord *LogRecord) ([]byte, int64) {
 	return encodedBytes, int64(size)
 }
 
+// EncodeLogRecordHeader encode header and key of log record whose value is streamed separately,
+// crc is left empty and should be set by SetLogRecordCRC after the whole value is hashed
+func EncodeLogRecordHeader(logRecord *LogRecord, valueSize int64) []byte {
+	header := make([]byte, maxLogRecordHeaderSize+len(logRecord.Key))
+	header[4] = logRecord.Type
+
+	var index = invariantSize
+	index += binary.PutUvarint(header[index:], logRecord.SequenceNumber)
+	index += binary.PutVarint(header[index:], int64(len(logRecord.Key)))
+	index += binary.PutVarint(header[index:], valueSize)
+	index += copy(header[index:], logRecord.Key)
+
+	return header[:index]
+}
+
+// SetLogRecordCRC set crc of header encoded by EncodeLogRecordHeader
+func SetLogRecordCRC(header []byte, crc uint32) {
+	binary.LittleEndian.PutUint32(header[:crcSizeInByte], crc)
+}
+
 func decodeLogRecordHeader(buf []byte) (*LogRecordHeader, int64) {
 	if len(buf) <= crcSizeInByte {
 		return nil, 0
@@ -111,6 +133,34 @@ func decodeLogRecordHeader(buf []byte) (*LogRecordHeader, int64) {
 	return header, int64(index)
 }
 
+// DecodeLogRecord decode a whole log record from buf, return the record and size of it
+func DecodeLogRecord(buf []byte) (*LogRecord, int64, error) {
+	header, headerSize := decodeLogRecordHeader(buf)
+	if header == nil {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	keySize, valueSize := int64(header.keySize), int64(header.valueSize)
+	recordSize := headerSize + keySize + valueSize
+	if int64(len(buf)) < recordSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	logRecord := &LogRecord{
+		Key:            buf[headerSize : headerSize+keySize : headerSize+keySize],
+		Value:          buf[headerSize+keySize : recordSize : recordSize],
+		Type:           header.recordType,
+		SequenceNumber: header.sequenceNumber,
+	}
+
+	crc := getLogRecordCRC(logRecord, buf[crcSizeInByte:headerSize])
+	if crc != header.crc {
+		return nil, 0, ErrInvalidCRC
+	}
+
+	return logRecord, recordSize, nil
+}
+
 func getLogRecordCRC(logRecord *LogRecord, headerWithoutCRC []byte) uint32 {
 	if logRecord == nil {
 		return 0
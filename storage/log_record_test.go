@@ -3,6 +3,7 @@ package storage
 import (
 	"github.com/stretchr/testify/assert"
 	"hash/crc32"
+	"io"
 	"testing"
 )
 
@@ -320,3 +321,27 @@ func TestDecodeLogRecordPosition(t *testing.T) {
 	assert.Equal(t, uint32(10), pos.LogRecordSize)
 	assert.Equal(t, 5, size)
 }
+
+func TestDecodeLogRecord(t *testing.T) {
+	record := &LogRecord{
+		Key:            []byte("key"),
+		Value:          []byte("value"),
+		Type:           LogRecordNormal,
+		SequenceNumber: uint64(1),
+	}
+	enc, size := EncodeLogRecord(record)
+
+	decoded, decodedSize, err := DecodeLogRecord(enc)
+	assert.Nil(t, err)
+	assert.Equal(t, size, decodedSize)
+	assert.Equal(t, record, decoded)
+
+	// truncated buffer
+	_, _, err = DecodeLogRecord(enc[:size-1])
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// corrupted buffer
+	enc[size-1] = 'x'
+	_, _, err = DecodeLogRecord(enc)
+	assert.Equal(t, ErrInvalidCRC, err)
+}
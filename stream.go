@@ -0,0 +1,186 @@
+package bitcask_go
+
+import (
+	"bitcask-go/index"
+	"bitcask-go/storage"
+	"bytes"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+const (
+	// chunk size of copying streamed value into data file
+	streamCopyBufferSize     = 64 * 1024
+	putReaderTempFilePattern = "put-reader-*.tmp"
+)
+
+// PutReader put value of size bytes read from r without holding the whole value in memory.
+// Value is spooled to a temp file in os temp directory first to compute crc, then appended to data file
+func (db *DB) PutReader(key []byte, r io.Reader, size int64) error {
+	if !db.isOpen.Load() {
+		return ErrDBClosed
+	}
+
+	if db.config.ReadOnly {
+		return ErrReadOnlyDatabase
+	}
+
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	logRecord := &storage.LogRecord{
+		Key:            key,
+		Type:           storage.LogRecordNormal,
+		SequenceNumber: nonTransactionSequenceNumber,
+	}
+	header := storage.EncodeLogRecordHeader(logRecord, size)
+	recordSize := int64(len(header)) + size
+	if size < 0 || recordSize > math.MaxUint32 || recordSize > db.config.DataFileSize {
+		return ErrValueTooLarge
+	}
+
+	// 1. spool value to temp file and compute crc, in memory database spools to memory
+	var spool io.ReadWriter
+	if db.config.InMemory {
+		spool = new(bytes.Buffer)
+	} else {
+		// not in db directory, so it's never taken by backup
+		tempFile, err := os.CreateTemp("", putReaderTempFilePattern)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = tempFile.Close()
+			_ = os.Remove(tempFile.Name())
+		}()
+		spool = tempFile
+	}
+
+	hash := crc32.NewIEEE()
+	_, _ = hash.Write(header[4:])
+	n, err := io.CopyN(io.MultiWriter(spool, hash), r, size)
+	if err != nil {
+		if err == io.EOF && n < size {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	storage.SetLogRecordCRC(header, hash.Sum32())
+
+	if tempFile, ok := spool.(*os.File); ok {
+		if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	// 2. append header and value in chunks
+	unlock := db.keyLocks.lock(key)
+	defer unlock()
+
+	db.appendMu.Lock()
+	pos, err := db.appendRawLogRecord(recordSize, func(dataFile *storage.DataFile) error {
+		if err := dataFile.Write(header); err != nil {
+			return err
+		}
+		buf := make([]byte, streamCopyBufferSize)
+		for remain := size; remain > 0; {
+			n, err := spool.Read(buf[:min(int64(len(buf)), remain)])
+			if n > 0 {
+				if err := dataFile.Write(buf[:n]); err != nil {
+					return err
+				}
+				remain -= int64(n)
+			}
+			if err != nil && (err != io.EOF || remain > 0) {
+				return err
+			}
+		}
+		return nil
+	})
+	db.appendMu.Unlock()
+	if err != nil {
+		return err
+	}
+	db.stats.puts.Add(1)
+
+	// 3. update index, it's pended while index is loading in background
+	if db.pendIndexUpdate(logRecord, pos) {
+		return nil
+	}
+	oldPos, err := index.CheckedPut(db.index, key, pos)
+	if err != nil {
+		return err
+	}
+	if oldPos != nil {
+		db.reclaim(oldPos)
+	}
+
+	return nil
+}
+
+// GetReader get value of key as a stream and its size, crc is verified when the value is read to the end,
+// ErrInvalidCRC is returned instead of io.EOF if value is corrupted.
+// Reader reads its own handle of data file, so it's not affected by io type switches, file cache and merge
+func (db *DB) GetReader(key []byte) (io.ReadCloser, int64, error) {
+	if !db.isOpen.Load() {
+		return nil, 0, ErrDBClosed
+	}
+
+	if len(key) == 0 {
+		return nil, 0, ErrKeyIsEmpty
+	}
+
+	pos, err := db.getIndexPosition(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if pos == nil {
+		return nil, 0, ErrKeyNotFound
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// io manager of data file may be closed and replaced by others while value is read,
+	// so it's opened again for the reader, file can't be removed by merge under read lock
+	if (db.activeFile == nil || db.activeFile.FileId != pos.Fid) && db.inactiveFiles[pos.Fid] == nil {
+		return nil, 0, ErrDataFileNotFound
+	}
+	dataFile, err := storage.OpenDataFile(db.fs, db.config.DirPath, pos.Fid, db.fileIOType())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	reader, err := dataFile.OpenLogRecordValueReader(pos.Offset)
+	if err != nil {
+		_ = dataFile.Close()
+		return nil, 0, err
+	}
+	if reader.Type == storage.LogRecordDeleted {
+		_ = dataFile.Close()
+		return nil, 0, ErrKeyNotFound
+	}
+	db.stats.gets.Add(1)
+	db.stats.bytesRead.Add(uint64(pos.LogRecordSize))
+
+	return &valueReadCloser{LogRecordValueReader: reader, dataFile: dataFile}, reader.ValueSize, nil
+}
+
+// valueReadCloser close its own data file once closed
+type valueReadCloser struct {
+	*storage.LogRecordValueReader
+	dataFile *storage.DataFile
+	once     sync.Once
+	err      error
+}
+
+func (r *valueReadCloser) Close() error {
+	r.once.Do(func() {
+		r.err = r.dataFile.Close()
+	})
+	return r.err
+}
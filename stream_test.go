@@ -0,0 +1,111 @@
+package bitcask_go
+
+import (
+	"bitcask-go/utils"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_PutReaderGetReader(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_stream")
+	configs.DirPath = dir
+	configs.DataFileSize = 4 * 1024 * 1024
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	value := bytes.Repeat(utils.GenerateRandomValue(1024), 1024)
+	err = database.PutReader(utils.GenerateTestKey(1), bytes.NewReader(value), int64(len(value)))
+	assert.Nil(t, err)
+
+	// temp file is removed
+	tempFiles, _ := filepath.Glob(filepath.Join(dir, "put-reader-*"))
+	assert.Empty(t, tempFiles)
+
+	reader, size, err := database.GetReader(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(value)), size)
+	res, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, value, res)
+	assert.Nil(t, reader.Close())
+
+	// value put by reader is readable by Get
+	res, err = database.Get(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, value, res)
+
+	// value put by Put is readable by GetReader
+	err = database.Put(utils.GenerateTestKey(2), []byte("small"))
+	assert.Nil(t, err)
+	reader, _, err = database.GetReader(utils.GenerateTestKey(2))
+	assert.Nil(t, err)
+	res, err = io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("small"), res)
+
+	// short reader
+	err = database.PutReader(utils.GenerateTestKey(3), bytes.NewReader([]byte("abc")), 10)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	_, _, err = database.GetReader(utils.GenerateTestKey(3))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	err = database.PutReader(utils.GenerateTestKey(4), bytes.NewReader(nil), configs.DataFileSize)
+	assert.Equal(t, ErrValueTooLarge, err)
+
+	err = database.PutReader(nil, bytes.NewReader(nil), 0)
+	assert.Equal(t, ErrKeyIsEmpty, err)
+
+	// restart
+	err = database.Close()
+	assert.Nil(t, err)
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	reader, _, err = database.GetReader(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	res, err = io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, value, res)
+}
+
+func TestDB_GetReader_ActiveFileArchived(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_stream")
+	configs.DirPath = dir
+	configs.DataFileSize = 256 * 1024
+	configs.EnableMMapWrites = true
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	value := utils.GenerateRandomValue(128 * 1024)
+	err = database.PutReader(utils.GenerateTestKey(1), bytes.NewReader(value), int64(len(value)))
+	assert.Nil(t, err)
+
+	reader, _, err := database.GetReader(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	res := make([]byte, 1024)
+	_, err = io.ReadFull(reader, res)
+	assert.Nil(t, err)
+
+	// reader has its own handle of data file, it's not affected when mmap io manager of active file
+	// is replaced after archived, or closed with database
+	for i := 2; i < 10; i++ {
+		assert.Nil(t, database.Put(utils.GenerateTestKey(i), utils.GenerateRandomValue(64*1024)))
+	}
+	assert.Nil(t, database.Close())
+
+	rest, err := io.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, value, append(res, rest...))
+	assert.Nil(t, reader.Close())
+	assert.Nil(t, reader.Close())
+}
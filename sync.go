@@ -0,0 +1,80 @@
+package bitcask_go
+
+import (
+	"bitcask-go/storage"
+	"time"
+)
+
+// needSyncByPolicy check if active file should be flushed after a write
+func (db *DB) needSyncByPolicy() bool {
+	switch db.config.SyncPolicy.Type {
+	case SyncEveryWrite:
+		return true
+	case SyncEveryNBytes:
+		return db.totalBytesWritten >= db.config.SyncPolicy.Bytes
+	default:
+		return false
+	}
+}
+
+// syncActiveFile flush active file if there are bytes not synced, appendMu must be held
+func (db *DB) syncActiveFile() error {
+	if db.activeFile == nil || db.totalBytesWritten == 0 {
+		return nil
+	}
+
+	if err := db.syncDataFile(db.activeFile); err != nil {
+		return err
+	}
+	db.totalBytesWritten = 0
+	return nil
+}
+
+// syncDataFile flush data file, every real sync of data file goes through it to be counted and timed
+func (db *DB) syncDataFile(dataFile *storage.DataFile) error {
+	if db.metrics != nil {
+		defer db.metrics.observe(metricsSync, time.Now())
+	}
+
+	if err := dataFile.Sync(); err != nil {
+		return err
+	}
+	db.stats.syncs.Add(1)
+	return nil
+}
+
+// startBackgroundSync flush active file every interval for SyncEveryInterval, so the last writes of idle db are durable
+func (db *DB) startBackgroundSync() {
+	if db.config.SyncPolicy.Type != SyncEveryInterval {
+		return
+	}
+
+	db.syncWg.Add(1)
+	go func() {
+		defer db.syncWg.Done()
+
+		ticker := time.NewTicker(db.config.SyncPolicy.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-db.syncStopCh:
+				return
+			case <-ticker.C:
+				db.appendMu.Lock()
+				_ = db.syncActiveFile()
+				db.appendMu.Unlock()
+			}
+		}
+	}()
+}
+
+// stopBackgroundSync stop background sync and wait for it to exit, it's safe to call more than once
+func (db *DB) stopBackgroundSync() {
+	select {
+	case <-db.syncStopCh:
+	default:
+		close(db.syncStopCh)
+	}
+	db.syncWg.Wait()
+}
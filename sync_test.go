@@ -0,0 +1,108 @@
+package bitcask_go
+
+import (
+	"bitcask-go/fio"
+	"bitcask-go/utils"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDB_SyncPolicy(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_sync_policy")
+	configs.DirPath = dir
+	configs.SyncPolicy = SyncPolicy{Type: SyncEveryNBytes, Bytes: 1024}
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	err = database.Put(utils.GenerateTestKey(1), utils.GenerateRandomValue(128))
+	assert.Nil(t, err)
+	assert.Greater(t, database.totalBytesWritten, uint(0))
+
+	err = database.Put(utils.GenerateTestKey(2), utils.GenerateRandomValue(1024))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), database.totalBytesWritten)
+
+	// sync option flushes regardless of policy
+	err = database.PutWithOptions(utils.GenerateTestKey(3), utils.GenerateRandomValue(128), WriteOptions{Sync: true})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), database.totalBytesWritten)
+	err = database.DeleteWithOptions(utils.GenerateTestKey(3), WriteOptions{Sync: true})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), database.totalBytesWritten)
+
+	for _, policy := range []SyncPolicy{
+		{Type: SyncEveryNBytes},
+		{Type: SyncEveryInterval},
+		{Type: SyncEveryInterval + 1},
+	} {
+		configs.SyncPolicy = policy
+		_, err = OpenDatabase(configs)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestDB_SyncEveryInterval(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_sync_interval")
+	configs.DirPath = dir
+	configs.SyncPolicy = SyncPolicy{Type: SyncEveryInterval, Interval: 10 * time.Millisecond}
+
+	injector := fio.NewFaultInjector(dir)
+	configs.FileSystem = injector.FileSystem(fio.NewFileSystem(fio.StandardFileIOType))
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	err = database.Put(utils.GenerateTestKey(1), []byte("value"))
+	assert.Nil(t, err)
+
+	// idle db is synced in background
+	assert.Eventually(t, func() bool {
+		database.appendMu.Lock()
+		defer database.appendMu.Unlock()
+		return database.totalBytesWritten == 0
+	}, time.Second, 5*time.Millisecond)
+
+	crashDatabase(t, database, injector)
+	database, err = OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	val, err := database.Get(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value"), val)
+}
+
+func TestDB_WriteOptionsSync(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_write_options")
+	configs.DirPath = dir
+
+	injector := fio.NewFaultInjector(dir)
+	configs.FileSystem = injector.FileSystem(fio.NewFileSystem(fio.StandardFileIOType))
+
+	database, err := OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	err = database.PutWithOptions(utils.GenerateTestKey(1), []byte("durable"), WriteOptions{Sync: true})
+	assert.Nil(t, err)
+	err = database.Put(utils.GenerateTestKey(2), []byte("lost"))
+	assert.Nil(t, err)
+
+	// write not synced is lost by crash
+	crashDatabase(t, database, injector)
+	database, err = OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	val, err := database.Get(utils.GenerateTestKey(1))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("durable"), val)
+	_, err = database.Get(utils.GenerateTestKey(2))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
@@ -0,0 +1,70 @@
+package bitcask_go
+
+import (
+	"bitcask-go/storage"
+)
+
+// GetView call fn with value of key, value is read without copying if data file is memory mapped
+// (see Config.MMapInactiveFiles). Value is only valid inside fn, it must not be modified or retained.
+// No lock of database is held while fn runs, so fn is free to read and write database
+func (db *DB) GetView(key []byte, fn func(value []byte) error) error {
+	if !db.isOpen.Load() {
+		return ErrDBClosed
+	}
+
+	if len(key) == 0 {
+		return ErrKeyIsEmpty
+	}
+
+	pos, err := db.getIndexPosition(key)
+	if err != nil {
+		return err
+	}
+	if pos == nil {
+		return ErrKeyNotFound
+	}
+
+	value, release, err := db.viewValue(pos)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// lock is not held while fn runs, so fn can write to database, the view keeps data file mapped
+	return fn(value)
+}
+
+// viewValue value of log record at position, it's valid until release is called
+func (db *DB) viewValue(pos *storage.LogRecordPos) ([]byte, func(), error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	dataFile, releaseFile, err := db.acquireDataFile(pos.Fid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buf, releaseView, err := dataFile.ViewBytes(int64(pos.LogRecordSize), pos.Offset)
+	if err != nil {
+		releaseFile()
+		return nil, nil, err
+	}
+	release := func() {
+		releaseView()
+		releaseFile()
+	}
+
+	logRecord, _, err := storage.DecodeLogRecord(buf)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	if logRecord.Type == storage.LogRecordDeleted {
+		release()
+		return nil, nil, ErrKeyNotFound
+	}
+	db.stats.gets.Add(1)
+	db.stats.bytesRead.Add(uint64(len(buf)))
+
+	return logRecord.Value, release, nil
+}
@@ -0,0 +1,110 @@
+package bitcask_go
+
+import (
+	"bitcask-go/utils"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+)
+
+func TestDB_GetView(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_get_view")
+	configs.DirPath = dir
+	configs.DataFileSize = 8 * 1024
+	configs.MMapInactiveFiles = true
+	configs.MergeRatio = 0
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	n := 1000
+	for i := 0; i < n; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+	assert.Greater(t, len(database.inactiveFiles), 1)
+
+	// value in mmapped inactive file and in active file
+	for _, i := range []int{0, n - 1} {
+		err = database.GetView(utils.GenerateTestKey(i), func(value []byte) error {
+			assert.Equal(t, utils.GenerateTestKey(i), value)
+			return nil
+		})
+		assert.Nil(t, err)
+	}
+
+	errStop := errors.New("stop")
+	err = database.GetView(utils.GenerateTestKey(1), func(value []byte) error {
+		return errStop
+	})
+	assert.Equal(t, errStop, err)
+
+	err = database.Delete(utils.GenerateTestKey(2))
+	assert.Nil(t, err)
+	err = database.GetView(utils.GenerateTestKey(2), func(value []byte) error { return nil })
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	// restart, inactive files stay mapped
+	err = database.Close()
+	assert.Nil(t, err)
+	database, err = OpenDatabase(configs)
+	assert.Nil(t, err)
+
+	err = database.GetView(utils.GenerateTestKey(10), func(value []byte) error {
+		assert.Equal(t, utils.GenerateTestKey(10), value)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	val, err := database.Get(utils.GenerateTestKey(500))
+	assert.Nil(t, err)
+	assert.Equal(t, utils.GenerateTestKey(500), val)
+
+	err = database.Merge()
+	assert.Nil(t, err)
+	err = database.GetView(utils.GenerateTestKey(20), func(value []byte) error {
+		assert.Equal(t, utils.GenerateTestKey(20), value)
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestDB_GetView_WriteInCallback(t *testing.T) {
+	configs := DefaultConfig
+	dir, _ := os.MkdirTemp("", "bitcask_test_get_view")
+	configs.DirPath = dir
+	configs.DataFileSize = 8 * 1024
+	configs.MMapInactiveFiles = true
+	configs.MergeRatio = 0
+
+	database, err := OpenDatabase(configs)
+	defer destroyDatabase(database)
+	assert.Nil(t, err)
+
+	for i := 0; i < 500; i++ {
+		err = database.Put(utils.GenerateTestKey(i), utils.GenerateTestKey(i))
+		assert.Nil(t, err)
+	}
+
+	// data files are rotated and removed by merge while value of the mapped file is viewed
+	err = database.GetView(utils.GenerateTestKey(0), func(value []byte) error {
+		for i := 0; i < 500; i++ {
+			if err := database.Put(utils.GenerateTestKey(i), []byte("new")); err != nil {
+				return err
+			}
+		}
+		if err := database.Merge(); err != nil {
+			return err
+		}
+		assert.Equal(t, utils.GenerateTestKey(0), value)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	val, err := database.Get(utils.GenerateTestKey(0))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("new"), val)
+}